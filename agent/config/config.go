@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -16,8 +17,9 @@ type Config struct {
 	RunInBackground     bool   `json:"run_in_background"`
 	MaxBackups          int    `json:"max_backups"`
 	GUIPort             int    `json:"gui_port"`
-	GUIBindAddr         string `json:"gui_bind_addr"`  // Bind address for GUI server (default: "127.0.0.1", use "0.0.0.0" for remote access)
-	UpdateChannel       string `json:"update_channel"` // "release" or "pre-release"
+	GUIBindAddr         string `json:"gui_bind_addr"`       // Bind address for GUI server (default: "127.0.0.1", use "0.0.0.0" for remote access)
+	UpdateChannel       string `json:"update_channel"`      // "release" or "pre-release"
+	APIToken            string `json:"api_token,omitempty"` // Bearer token for DevProxy APIs with authentication enabled
 }
 
 var (
@@ -101,7 +103,8 @@ func Save() error {
 		return err
 	}
 
-	return os.WriteFile(cfgPath, data, 0644)
+	// The file may hold an API token.
+	return os.WriteFile(cfgPath, data, 0600)
 }
 
 // Get returns a copy of the current config.
@@ -123,3 +126,10 @@ func Update(cfg Config) error {
 func ConfigDir() string {
 	return filepath.Dir(cfgPath)
 }
+
+// Authorize adds the configured API token to a request to the DevProxy API.
+func Authorize(req *http.Request) {
+	if token := Get().APIToken; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
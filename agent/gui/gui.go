@@ -3,18 +3,31 @@ package gui
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"path/filepath"
 
 	"devproxy-agent/autostart"
 	"devproxy-agent/config"
 	"devproxy-agent/hosts"
 	agentsync "devproxy-agent/sync"
+	"devproxy-agent/trust"
 	"devproxy-agent/version"
 )
 
+// maskedToken replaces the API token in config responses.
+const maskedToken = "********"
+
+// configRequest is the body of config updates. An empty or masked token
+// keeps the current one; RemoveToken clears it.
+type configRequest struct {
+	config.Config
+	RemoveToken bool `json:"remove_token"`
+}
+
 //go:embed static/index.html
 var staticFiles embed.FS
 
@@ -36,17 +49,23 @@ func Start(port int, bindAddr string) {
 		w.Write(data)
 	})
 
-	// API endpoints (with CORS for DevProxy WebUI)
+	// Read-only endpoints the DevProxy WebUI polls from its own origin
 	mux.HandleFunc("/api/status", cors(handleStatus))
-	mux.HandleFunc("/api/config", cors(handleConfig))
-	mux.HandleFunc("/api/sync", cors(handleSync))
-	mux.HandleFunc("/api/pause", cors(handlePause))
-	mux.HandleFunc("/api/entries", cors(handleEntries))
-	mux.HandleFunc("/api/backups", cors(handleBackups))
-	mux.HandleFunc("/api/restore", cors(handleRestore))
 	mux.HandleFunc("/api/version", cors(handleVersion))
 	mux.HandleFunc("/api/updates/check", cors(handleUpdateCheck))
 
+	// Endpoints that change the machine are only for the agent GUI and
+	// local clients such as curl, never for other web pages
+	mux.HandleFunc("/api/config", sameOrigin(handleConfig))
+	mux.HandleFunc("/api/sync", sameOrigin(handleSync))
+	mux.HandleFunc("/api/pause", sameOrigin(handlePause))
+	mux.HandleFunc("/api/entries", sameOrigin(handleEntries))
+	mux.HandleFunc("/api/backups", sameOrigin(handleBackups))
+	mux.HandleFunc("/api/restore", sameOrigin(handleRestore))
+	mux.HandleFunc("/api/trust", sameOrigin(handleTrust))
+	mux.HandleFunc("/api/trust/install", sameOrigin(handleTrustInstall))
+	mux.HandleFunc("/api/trust/remove", sameOrigin(handleTrustRemove))
+
 	addr := fmt.Sprintf("%s:%d", bindAddr, port)
 	log.Printf("Agent config GUI available at http://%s", addr)
 	if bindAddr == "0.0.0.0" {
@@ -61,19 +80,35 @@ func Start(port int, bindAddr string) {
 }
 
 func handleStatus(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, agentsync.GetStatus())
+	writeJSON(w, struct {
+		agentsync.Status
+		Trust trust.Status `json:"trust"`
+	}{agentsync.GetStatus(), trust.GetStatus()})
 }
 
 func handleConfig(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		writeJSON(w, config.Get())
+		// Never hand the API token to the browser; the GUI may be reachable
+		// from the network.
+		cfg := config.Get()
+		if cfg.APIToken != "" {
+			cfg.APIToken = maskedToken
+		}
+		writeJSON(w, cfg)
 	case http.MethodPut:
-		var cfg config.Config
-		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		var req configRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
+		cfg := req.Config
+		switch {
+		case req.RemoveToken:
+			cfg.APIToken = ""
+		case cfg.APIToken == "" || cfg.APIToken == maskedToken:
+			cfg.APIToken = config.Get().APIToken
+		}
 
 		// Handle autostart toggle
 		oldCfg := config.Get()
@@ -173,6 +208,75 @@ func handleRestore(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]string{"message": "restored"})
 }
 
+func handleTrust(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, trust.Check(config.Get().APIURL))
+}
+
+func handleTrustInstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// The CA is fetched over plain HTTP, so it is only installed once the
+	// user confirmed its fingerprint against the DevProxy UI.
+	var req struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Fingerprint == "" {
+		status := trust.Check(config.Get().APIURL)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":          "confirm the CA fingerprint: compare ca_fingerprint with fingerprint_sha256 from /api/tls/ca/info and send it as fingerprint",
+			"ca_fingerprint": status.CAFingerprint,
+		})
+		return
+	}
+	if err := trust.Install(config.Get().APIURL, req.Fingerprint); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, trust.ErrFingerprintMismatch) {
+			code = http.StatusConflict
+		}
+		writeError(w, code, err.Error())
+		return
+	}
+	writeJSON(w, trust.GetStatus())
+}
+
+func handleTrustRemove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := trust.Remove(); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, trust.GetStatus())
+}
+
+// sameOrigin rejects state-changing requests that browsers send from other
+// origins, so web pages cannot drive the agent. Browsers send Origin or
+// Sec-Fetch-Site with such requests; clients like curl send neither and
+// are allowed.
+func sameOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+					writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+					return
+				}
+			} else if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+				writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
 func cors(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -22,6 +22,7 @@ import (
 func main() {
 	configDir := flag.String("config-dir", "", "Config directory (default: platform-specific)")
 	apiURL := flag.String("api-url", "", "DevProxy API URL (overrides config)")
+	apiToken := flag.String("api-token", "", "DevProxy API token, needed when the API requires authentication (overrides config)")
 	bindAddr := flag.String("bind-addr", "", "GUI server bind address (default: 127.0.0.1, use 0.0.0.0 for remote access)")
 	noTray := flag.Bool("no-tray", false, "Disable system tray icon")
 	showVersion := flag.Bool("version", false, "Print version and exit")
@@ -48,6 +49,13 @@ func main() {
 		config.Update(cfg)
 	}
 
+	// Override API token if specified
+	if *apiToken != "" {
+		cfg := config.Get()
+		cfg.APIToken = *apiToken
+		config.Update(cfg)
+	}
+
 	// Override bind address if specified
 	if *bindAddr != "" {
 		cfg := config.Get()
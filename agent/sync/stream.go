@@ -0,0 +1,199 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"devproxy-agent/config"
+)
+
+const (
+	// streamIdleTimeout drops the stream when nothing, not even a heartbeat,
+	// arrives for this long. The server sends a heartbeat every 15 seconds.
+	streamIdleTimeout = 45 * time.Second
+	streamMaxBackoff  = 30 * time.Second
+)
+
+// streamClient has no timeout because the event stream stays open.
+var streamClient = &http.Client{}
+
+// streamEvent is a single Server-Sent Event.
+type streamEvent struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// streamLoop keeps a connection to the DevProxy event stream open and syncs
+// whenever routes change. While the stream is down, syncLoop polls instead.
+func streamLoop() {
+	var lastID string
+	backoff := time.Second
+
+	for {
+		connected, err := streamEvents(&lastID)
+		setStreamConnected(false)
+		if connected {
+			backoff = time.Second
+		}
+		if err != nil {
+			log.Printf("Event stream: %v (polling, retrying in %s)", err, backoff)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(backoff):
+		}
+		if !connected {
+			if backoff *= 2; backoff > streamMaxBackoff {
+				backoff = streamMaxBackoff
+			}
+		}
+	}
+}
+
+// streamEvents reads the event stream until it ends. lastID is updated as
+// events arrive so that the next connection resumes where this one stopped.
+// It reports whether the connection was established.
+func streamEvents(lastID *string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Get().APIURL+"/api/events", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+	config.Authorize(req)
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("connect to DevProxy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("DevProxy returned status %d", resp.StatusCode)
+	}
+
+	setStreamConnected(true)
+	// Catch up on anything that changed while the stream was down.
+	SyncNow()
+
+	watchdog := time.AfterFunc(streamIdleTimeout, cancel)
+	defer watchdog.Stop()
+
+	err = readEvents(&idleReader{r: resp.Body, timer: watchdog}, func(e streamEvent) {
+		if e.ID != "" {
+			*lastID = e.ID
+		}
+		if syncOnEvent(e.Type) {
+			SyncNow()
+		}
+	})
+	if ctx.Err() != nil {
+		select {
+		case <-stopCh:
+			return true, nil
+		default:
+			return true, fmt.Errorf("no events for %s", streamIdleTimeout)
+		}
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	return true, fmt.Errorf("stream closed: %w", err)
+}
+
+// syncOnEvent reports whether an event type can change the hosts entries.
+func syncOnEvent(eventType string) bool {
+	switch {
+	case strings.HasPrefix(eventType, "route."), strings.HasPrefix(eventType, "routes."):
+		return true
+	case eventType == "apply.succeeded", eventType == "reset":
+		return true
+	}
+	return false
+}
+
+// readEvents parses a Server-Sent Events stream and calls fn for each event.
+// Comments such as heartbeats are skipped.
+func readEvents(r io.Reader, fn func(streamEvent)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var e streamEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if e.Type != "" || len(data) > 0 {
+				if e.Type == "" {
+					e.Type = "message"
+				}
+				e.Data = strings.Join(data, "\n")
+				fn(e)
+			}
+			e, data = streamEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// idleReader resets timer whenever data arrives.
+type idleReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(streamIdleTimeout)
+	}
+	return n, err
+}
+
+func setStreamConnected(connected bool) {
+	statusMu.Lock()
+	status.StreamConnected = connected
+	statusMu.Unlock()
+}
+
+func streamConnected() bool {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	return status.StreamConnected
+}
@@ -16,11 +16,12 @@ import (
 
 // Route matches the DevProxy API route structure.
 type Route struct {
-	ID      int64  `json:"id"`
-	Name    string `json:"name"`
-	Domain  string `json:"domain"`
-	Target  string `json:"target"`
-	Enabled bool   `json:"enabled"`
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Domain  string   `json:"domain"`
+	Aliases []string `json:"aliases"`
+	Target  string   `json:"target"`
+	Enabled bool     `json:"enabled"`
 }
 
 // Status represents the current sync state.
@@ -31,6 +32,12 @@ type Status struct {
 	RouteCount    int       `json:"route_count"`
 	Paused        bool      `json:"paused"`
 	HasPermission bool      `json:"has_permission"`
+	// StreamConnected is true while the agent receives route changes over
+	// the event stream; interval polling only runs while it is false.
+	StreamConnected bool `json:"stream_connected"`
+	// Wildcards lists wildcard hostnames that cannot be written to the hosts
+	// file. They need a local DNS resolver such as dnsmasq instead.
+	Wildcards []string `json:"unsupported_wildcards,omitempty"`
 }
 
 var (
@@ -69,6 +76,7 @@ func Start() {
 	}
 
 	go syncLoop()
+	go streamLoop()
 }
 
 // Stop stops the sync loop.
@@ -124,7 +132,9 @@ func syncLoop() {
 		case <-syncNowCh:
 			doSync()
 		case <-time.After(interval):
-			doSync()
+			if !streamConnected() {
+				doSync()
+			}
 		}
 	}
 }
@@ -148,14 +158,14 @@ func doSync() {
 		return
 	}
 
-	// Build desired entries from enabled routes
-	var entries []string
-	for _, r := range routes {
-		if r.Enabled && r.Domain != "" {
-			entries = append(entries, fmt.Sprintf("127.0.0.1 %s", r.Domain))
-		}
+	entries, wildcards := buildEntries(routes)
+
+	statusMu.Lock()
+	if len(wildcards) > 0 && strings.Join(wildcards, ",") != strings.Join(status.Wildcards, ",") {
+		log.Printf("Skipping wildcard domains the hosts file cannot express: %s", strings.Join(wildcards, ", "))
 	}
-	sort.Strings(entries)
+	status.Wildcards = wildcards
+	statusMu.Unlock()
 
 	// Check if anything changed
 	entriesKey := strings.Join(entries, "\n")
@@ -196,8 +206,40 @@ func doSync() {
 	log.Printf("Synced %d entries to hosts file", len(entries))
 }
 
+// buildEntries returns the sorted hosts entries for all enabled routes and
+// the wildcard hostnames that had to be left out. Routes sharing a domain or
+// alias produce a single entry.
+func buildEntries(routes []Route) (entries []string, wildcards []string) {
+	seen := make(map[string]bool)
+	for _, r := range routes {
+		if !r.Enabled {
+			continue
+		}
+		for _, h := range append([]string{r.Domain}, r.Aliases...) {
+			if h == "" || seen[h] {
+				continue
+			}
+			seen[h] = true
+			if strings.Contains(h, "*") {
+				wildcards = append(wildcards, h)
+				continue
+			}
+			entries = append(entries, fmt.Sprintf("127.0.0.1 %s", h))
+		}
+	}
+	sort.Strings(entries)
+	sort.Strings(wildcards)
+	return entries, wildcards
+}
+
 func fetchRoutes(apiURL string) ([]Route, error) {
-	resp, err := client.Get(apiURL + "/api/routes")
+	req, err := http.NewRequest(http.MethodGet, apiURL+"/api/routes", nil)
+	if err != nil {
+		return nil, err
+	}
+	config.Authorize(req)
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("connect to DevProxy: %w", err)
 	}
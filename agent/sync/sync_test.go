@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildEntries(t *testing.T) {
+	routes := []Route{
+		{Domain: "shop.test", Aliases: []string{"*.shop.test", "store.test"}, Enabled: true},
+		{Domain: "shop.test", Aliases: []string{"store.test"}, Enabled: true},
+		{Domain: "*.tenant.test", Enabled: true},
+		{Domain: "off.test", Aliases: []string{"also-off.test"}, Enabled: false},
+	}
+
+	entries, wildcards := buildEntries(routes)
+
+	expectedEntries := []string{"127.0.0.1 shop.test", "127.0.0.1 store.test"}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("buildEntries() entries = %v; want %v", entries, expectedEntries)
+	}
+
+	expectedWildcards := []string{"*.shop.test", "*.tenant.test"}
+	if !reflect.DeepEqual(wildcards, expectedWildcards) {
+		t.Errorf("buildEntries() wildcards = %v; want %v", wildcards, expectedWildcards)
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := "retry: 3000\n\n" +
+		": ping\n\n" +
+		"id: 7\nevent: route.created\ndata: {\"id\":1}\n\n" +
+		"id: 8\nevent: health.changed\ndata: {\"a\":1}\ndata: {\"b\":2}\n\n" +
+		"data: plain\n\n"
+
+	var got []streamEvent
+	if err := readEvents(strings.NewReader(stream), func(e streamEvent) {
+		got = append(got, e)
+	}); err != nil {
+		t.Fatalf("readEvents() error = %v", err)
+	}
+
+	expected := []streamEvent{
+		{ID: "7", Type: "route.created", Data: `{"id":1}`},
+		{ID: "8", Type: "health.changed", Data: "{\"a\":1}\n{\"b\":2}"},
+		{Type: "message", Data: "plain"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("readEvents() = %+v; want %+v", got, expected)
+	}
+}
+
+func TestSyncOnEvent(t *testing.T) {
+	tests := map[string]bool{
+		"route.created":   true,
+		"routes.changed":  true,
+		"apply.succeeded": true,
+		"reset":           true,
+		"apply.failed":    false,
+		"health.changed":  false,
+	}
+	for eventType, expected := range tests {
+		if got := syncOnEvent(eventType); got != expected {
+			t.Errorf("syncOnEvent(%q) = %v; want %v", eventType, got, expected)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package trust installs the DevProxy root CA into the system trust store so
+// that routes served with internal TLS are trusted by browsers and tools.
+package trust
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status describes whether the DevProxy root CA is trusted on this machine.
+type Status struct {
+	Supported     bool   `json:"supported"`
+	Installed     bool   `json:"installed"`
+	UpToDate      bool   `json:"up_to_date"`
+	Store         string `json:"store,omitempty"`
+	Path          string `json:"path,omitempty"`
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	CAFingerprint string `json:"ca_fingerprint,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+var (
+	mu                sync.Mutex
+	lastCAFingerprint string // fingerprint of the CA last fetched from DevProxy
+	client            = &http.Client{Timeout: 5 * time.Second}
+)
+
+// GetStatus reports the local trust state without contacting DevProxy. The
+// CA fingerprint is the one seen by the last Check or Install.
+func GetStatus() Status {
+	mu.Lock()
+	defer mu.Unlock()
+	return localStatus()
+}
+
+// Check fetches the current root CA from DevProxy and compares it with the
+// installed certificate.
+func Check(apiURL string) Status {
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, cert, err := fetchCA(apiURL)
+	if err != nil {
+		status := localStatus()
+		status.Error = err.Error()
+		return status
+	}
+
+	lastCAFingerprint = fingerprint(cert)
+	return localStatus()
+}
+
+// ErrFingerprintMismatch is returned by Install when the CA served by
+// DevProxy is not the one the user confirmed.
+var ErrFingerprintMismatch = errors.New("CA fingerprint does not match")
+
+// Install downloads the root CA from DevProxy and adds it to the system
+// trust store, replacing any previously installed DevProxy CA. The CA is
+// fetched over plain HTTP, so it is only installed if its SHA-256
+// fingerprint matches want, the fingerprint the user confirmed.
+func Install(apiURL, want string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, cert, err := fetchCA(apiURL)
+	if err != nil {
+		return err
+	}
+	got := fingerprint(cert)
+	lastCAFingerprint = got
+	if normalizeFingerprint(want) != got {
+		return fmt.Errorf("%w: DevProxy served %s", ErrFingerprintMismatch, got)
+	}
+	return install(data)
+}
+
+// Remove deletes the DevProxy root CA from the system trust store.
+func Remove() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return remove()
+}
+
+// localStatus must be called with mu held.
+func localStatus() Status {
+	status := Status{CAFingerprint: lastCAFingerprint}
+
+	st, err := detectStore()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Supported = true
+	status.Store = st.name
+	status.Path = st.path()
+
+	cert, err := readCertFile(st.path())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			status.Error = err.Error()
+		}
+		return status
+	}
+
+	status.Installed = true
+	status.Fingerprint = fingerprint(cert)
+	status.UpToDate = lastCAFingerprint != "" && status.Fingerprint == lastCAFingerprint
+	return status
+}
+
+// fetchCA downloads and validates the root CA served by the DevProxy API.
+func fetchCA(apiURL string) ([]byte, *x509.Certificate, error) {
+	resp, err := client.Get(apiURL + "/api/tls/ca")
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to DevProxy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("DevProxy returned status %d (is TLS enabled on any route?)", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read CA: %w", err)
+	}
+
+	cert, err := parseCert(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cert.IsCA {
+		return nil, nil, fmt.Errorf("certificate %q is not a CA", cert.Subject.CommonName)
+	}
+	return data, cert, nil
+}
+
+func readCertFile(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCert(data)
+}
+
+func parseCert(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// normalizeFingerprint accepts hex fingerprints in either case and with
+// colons or spaces, as shown by openssl and browsers.
+func normalizeFingerprint(s string) string {
+	return strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(s)))
+}
+
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package trust
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// store is a system CA anchor directory and the command that rebuilds the
+// trust bundle after it changes.
+type store struct {
+	name    string
+	dir     string
+	file    string
+	refresh []string
+}
+
+func (s *store) path() string {
+	return filepath.Join(s.dir, s.file)
+}
+
+// stores lists the supported layouts in detection order: Debian/Ubuntu,
+// Fedora/RHEL, then p11-kit based systems such as Arch.
+var stores = []store{
+	{
+		name:    "update-ca-certificates",
+		dir:     "/usr/local/share/ca-certificates",
+		file:    "devproxy-root-ca.crt",
+		refresh: []string{"update-ca-certificates"},
+	},
+	{
+		name:    "update-ca-trust",
+		dir:     "/etc/pki/ca-trust/source/anchors",
+		file:    "devproxy-root-ca.pem",
+		refresh: []string{"update-ca-trust", "extract"},
+	},
+	{
+		name:    "p11-kit",
+		dir:     "/etc/ca-certificates/trust-source/anchors",
+		file:    "devproxy-root-ca.pem",
+		refresh: []string{"trust", "extract-compat"},
+	},
+}
+
+func detectStore() (*store, error) {
+	for i := range stores {
+		s := &stores[i]
+		if _, err := exec.LookPath(s.refresh[0]); err != nil {
+			continue
+		}
+		if info, err := os.Stat(s.dir); err != nil || !info.IsDir() {
+			continue
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("no supported trust store found (install ca-certificates or p11-kit)")
+}
+
+func install(data []byte) error {
+	s, err := detectStore()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(s.path(), data, 0644); err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("permission denied: run the agent with sudo")
+		}
+		return fmt.Errorf("write CA: %w", err)
+	}
+	return refresh(s)
+}
+
+func remove() error {
+	s, err := detectStore()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.path()); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if os.IsPermission(err) {
+			return fmt.Errorf("permission denied: run the agent with sudo")
+		}
+		return fmt.Errorf("remove CA: %w", err)
+	}
+	return refresh(s)
+}
+
+func refresh(s *store) error {
+	out, err := exec.Command(s.refresh[0], s.refresh[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(s.refresh, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package trust
+
+import "fmt"
+
+// store is only implemented on Linux.
+type store struct {
+	name string
+}
+
+func (s *store) path() string {
+	return ""
+}
+
+func detectStore() (*store, error) {
+	return nil, fmt.Errorf("installing the root CA is only supported on Linux; import /api/tls/ca manually")
+}
+
+func install(data []byte) error {
+	_, err := detectStore()
+	return err
+}
+
+func remove() error {
+	_, err := detectStore()
+	return err
+}
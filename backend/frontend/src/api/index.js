@@ -16,14 +16,30 @@ async function request(endpoint, options = {}) {
     ...options,
   })
 
+  if (response.status === 401 && !endpoint.startsWith('/auth/')) {
+    window.location.href = '/login'
+    throw new Error('Authentication required')
+  }
+
   if (!response.ok) {
     const error = await response.json().catch(() => ({ error: 'Request failed' }))
-    throw new Error(error.error || 'Request failed')
+    const err = new Error(error.error || 'Request failed')
+    err.details = error.errors || []
+    throw err
   }
 
   return response.json()
 }
 
+// Auth API
+export const authApi = {
+  status: () => request('/auth/status'),
+  logout: () => request('/auth/logout', { method: 'POST' }),
+  getTokens: () => request('/tokens'),
+  createToken: (name, scope) => request('/tokens', { method: 'POST', body: JSON.stringify({ name, scope }) }),
+  deleteToken: (id) => request(`/tokens/${id}`, { method: 'DELETE' }),
+}
+
 // Routes API
 export const routesApi = {
   getAll: () => request('/routes'),
@@ -32,11 +48,38 @@ export const routesApi = {
   update: (id, route) => request(`/routes/${id}`, { method: 'PUT', body: JSON.stringify(route) }),
   delete: (id) => request(`/routes/${id}`, { method: 'DELETE' }),
   toggle: (id) => request(`/routes/${id}/toggle`, { method: 'POST' }),
+  setMaintenance: (id, maintenance) => request(`/routes/${id}/maintenance`, { method: 'PUT', body: JSON.stringify({ maintenance }) }),
+  compose: (id, req) => request(`/routes/${id}/compose`, { method: 'POST', body: JSON.stringify(req) }),
+  getUsers: (id) => request(`/routes/${id}/users`),
+  setUser: (id, username, password) => request(`/routes/${id}/users/${encodeURIComponent(username)}`, { method: 'PUT', body: JSON.stringify({ password }) }),
+  deleteUser: (id, username) => request(`/routes/${id}/users/${encodeURIComponent(username)}`, { method: 'DELETE' }),
+}
+
+// Request composer results
+export const composeApi = {
+  getResult: (id) => request(`/compose/${id}`),
+  harUrl: (id) => `${BASE_URL}/compose/${id}/har`,
+}
+
+// Projects API
+export const projectsApi = {
+  getAll: () => request('/projects'),
+  create: (project) => request('/projects', { method: 'POST', body: JSON.stringify(project) }),
+  update: (id, project) => request(`/projects/${id}`, { method: 'PUT', body: JSON.stringify(project) }),
+  delete: (id) => request(`/projects/${id}`, { method: 'DELETE' }),
+  assignRoutes: (id, routeIds) => request(`/projects/${id}/routes`, { method: 'POST', body: JSON.stringify({ route_ids: routeIds }) }),
+  deleteRoutes: (id) => request(`/projects/${id}/routes`, { method: 'DELETE' }),
+  enable: (id, exclusive = false) => request(`/projects/${id}/enable${exclusive ? '?exclusive=true' : ''}`, { method: 'POST' }),
+  disable: (id) => request(`/projects/${id}/disable`, { method: 'POST' }),
+  export: (id) => request(`/projects/${id}/export`),
 }
 
 // Health API
 export const healthApi = {
   getStatuses: () => request('/health'),
+  getHistory: (window = '24h') => request(`/health/history?window=${window}`),
+  getRouteHistory: (id, window = '24h', step = '') => request(`/health/history/${id}?window=${window}${step ? `&step=${step}` : ''}`),
+  getTransitions: (window = '24h', routeId = null) => request(`/health/transitions?window=${window}${routeId ? `&route=${routeId}` : ''}`),
 }
 
 // Proxy API
@@ -45,10 +88,77 @@ export const proxyApi = {
   getAppliedState: () => request('/applied-state'),
 }
 
+// Revision history API
+export const revisionsApi = {
+  getAll: (limit = 50) => request(`/revisions?limit=${limit}`),
+  getById: (id) => request(`/revisions/${id}`),
+  diff: (id, from = null) => request(`/revisions/${id}/diff${from ? `?from=${from}` : ''}`),
+  rollback: (id) => request(`/revisions/${id}/rollback`, { method: 'POST' }),
+}
+
+// Event stream API
+export const eventsApi = {
+  /**
+   * Subscribe to route, apply and health events. handlers maps event types
+   * (e.g. 'route.created', 'apply.failed') to callbacks receiving the parsed
+   * data. The browser reconnects and resumes automatically; call close() on
+   * the returned EventSource to stop.
+   */
+  subscribe: (handlers) => {
+    const source = new EventSource(`${BASE_URL}/events`)
+    for (const [type, handler] of Object.entries(handlers)) {
+      source.addEventListener(type, (e) => handler(JSON.parse(e.data)))
+    }
+    return source
+  },
+}
+
+// Traffic API
+const trafficQuery = ({ route = null, status = '', path = '' } = {}) => {
+  const params = new URLSearchParams()
+  if (route !== null) params.set('route', route)
+  if (status) params.set('status', status)
+  if (path) params.set('path', path)
+  return params.toString()
+}
+
+export const trafficApi = {
+  getAll: (filters = {}, limit = 100) => request(`/traffic?${trafficQuery(filters)}&limit=${limit}`),
+  getById: (id) => request(`/traffic/${id}`),
+  clear: (route = null) => request(`/traffic${route !== null ? `?route=${route}` : ''}`, { method: 'DELETE' }),
+  // Live tail; onRequest receives each captured request without headers
+  subscribe: (onRequest, filters = {}) => {
+    const source = new EventSource(`${BASE_URL}/traffic/stream?${trafficQuery(filters)}`)
+    source.addEventListener('request', (e) => onRequest(JSON.parse(e.data)))
+    return source
+  },
+}
+
+// Webhooks API
+export const webhooksApi = {
+  getAll: () => request('/webhooks'),
+  create: (hook) => request('/webhooks', { method: 'POST', body: JSON.stringify(hook) }),
+  update: (id, hook) => request(`/webhooks/${id}`, { method: 'PUT', body: JSON.stringify(hook) }),
+  delete: (id) => request(`/webhooks/${id}`, { method: 'DELETE' }),
+  test: (id) => request(`/webhooks/${id}/test`, { method: 'POST' }),
+  getDeliveries: (id, limit = 50) => request(`/webhooks/${id}/deliveries?limit=${limit}`),
+}
+
 // Config API
 export const configApi = {
   export: () => request('/export'),
-  import: (routes) => request('/import', { method: 'POST', body: JSON.stringify(routes) }),
+  import: (routes, { mode = 'skip', dryRun = false } = {}) =>
+    request(`/import?mode=${encodeURIComponent(mode)}${dryRun ? '&dry_run=true' : ''}`, { method: 'POST', body: JSON.stringify(routes) }),
+  previewCompose: (content, { project = '', path = '' } = {}) => {
+    const params = new URLSearchParams()
+    if (project) params.set('project', project)
+    if (path) params.set('path', path)
+    return request(`/import/compose?${params}`, { method: 'POST', headers: { 'Content-Type': 'application/yaml' }, body: content || '' })
+  },
+  previewNginx: (content) =>
+    request('/import/nginx', { method: 'POST', headers: { 'Content-Type': 'text/plain' }, body: content }),
+  previewTraefik: (content, format = '') =>
+    request(`/import/traefik${format ? `?format=${encodeURIComponent(format)}` : ''}`, { method: 'POST', headers: { 'Content-Type': 'text/plain' }, body: content }),
 }
 
 // Agent API
@@ -72,6 +182,10 @@ export default {
   routes: routesApi,
   health: healthApi,
   proxy: proxyApi,
+  events: eventsApi,
+  compose: composeApi,
+  traffic: trafficApi,
+  webhooks: webhooksApi,
   config: configApi,
   agent: agentApi,
   backend: backendApi,
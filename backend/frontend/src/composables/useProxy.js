@@ -8,6 +8,16 @@ const appliedState = ref([])
 const reloading = ref(false)
 const { showToast } = useToast()
 
+// Route fields that end up in the Caddy config; a change to any of them
+// needs a reload.
+const appliedFields = [
+  'name', 'domain', 'aliases', 'path', 'priority', 'strip_prefix', 'kind', 'target',
+  'redirect', 'static', 'file_server', 'tls_mode', 'enabled', 'maintenance', 'headers', 'allow_cidrs',
+]
+
+// sameValue compares field values, treating null and empty lists alike.
+const sameValue = (a, b) => JSON.stringify(a ?? []) === JSON.stringify(b ?? [])
+
 export function useProxy() {
   const { routes, fetchRoutes } = useRoutes()
   const { fetchHealth } = useHealth()
@@ -29,12 +39,7 @@ export function useProxy() {
       const applied = appliedMap.get(route.id)
       if (!applied) {
         changed.add(route.id)
-      } else if (
-        applied.name !== route.name ||
-        applied.domain !== route.domain ||
-        applied.target !== route.target ||
-        applied.enabled !== route.enabled
-      ) {
+      } else if (appliedFields.some((field) => !sameValue(applied[field], route[field]))) {
         changed.add(route.id)
       }
     }
@@ -65,7 +70,9 @@ export function useProxy() {
       showToast(data.message || 'Proxy reloaded', 'success')
       await fetchHealth()
     } catch (e) {
-      showToast('Failed to reload proxy', 'error')
+      const detail = e.details?.[0]
+      const reason = detail ? `${detail.domain ? detail.domain + ': ' : ''}${detail.message}` : e.message
+      showToast(`Failed to reload proxy: ${reason}`, 'error')
     }
     reloading.value = false
   }
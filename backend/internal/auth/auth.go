@@ -0,0 +1,248 @@
+// Package auth implements the optional authentication of the REST API: an
+// admin password with session cookies for the UI, and scoped bearer tokens
+// for scripts and the host agent.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"devproxy/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token scopes, from least to most privileged. Each scope includes the
+// permissions of the ones before it.
+const (
+	ScopeReadOnly    = "read-only"
+	ScopeRoutesWrite = "routes:write"
+	ScopeAdmin       = "admin"
+)
+
+// SessionCookie is the name of the UI session cookie.
+const SessionCookie = "devproxy_session"
+
+// SessionTTL is how long a login session stays valid.
+const SessionTTL = 7 * 24 * time.Hour
+
+// TokenPrefix starts every API token so they are easy to recognise in
+// scripts and secret scanners.
+const TokenPrefix = "dpx_"
+
+const principalKey = "auth.principal"
+
+var scopeRank = map[string]int{
+	ScopeReadOnly:    1,
+	ScopeRoutesWrite: 2,
+	ScopeAdmin:       3,
+}
+
+var adminHash []byte
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Name  string
+	Scope string
+	// Session is true for the admin logged in through the UI.
+	Session bool
+}
+
+// Allows reports whether the principal's scope includes scope.
+func (p *Principal) Allows(scope string) bool {
+	return scopeRank[p.Scope] >= scopeRank[scope]
+}
+
+// Init enables authentication if an admin password is configured. hash is
+// a bcrypt hash and takes precedence over the plain password. With neither,
+// the API stays open as before.
+func Init(password, hash string) error {
+	adminHash = nil
+	switch {
+	case hash != "":
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return errors.New("ADMIN_PASSWORD_HASH is not a bcrypt hash")
+		}
+		adminHash = []byte(hash)
+	case password != "":
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		adminHash = h
+	}
+	return nil
+}
+
+// Enabled reports whether the API requires authentication.
+func Enabled() bool {
+	return adminHash != nil
+}
+
+// ValidScope reports whether s is a known token scope.
+func ValidScope(s string) bool {
+	_, ok := scopeRank[s]
+	return ok
+}
+
+// CheckPassword compares password with the admin password.
+func CheckPassword(password string) bool {
+	return adminHash != nil && bcrypt.CompareHashAndPassword(adminHash, []byte(password)) == nil
+}
+
+// NewToken generates a new API token and returns it together with the hash
+// to store.
+func NewToken() (token, hash string, err error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	token = TokenPrefix + secret
+	return token, HashSecret(token), nil
+}
+
+// HashSecret hashes a token or session ID for storage. Both are long random
+// strings, so a fast hash is sufficient.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSession starts a login session and returns its ID for the cookie.
+func NewSession() (string, error) {
+	id, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	database.DeleteExpiredSessions()
+	if err := database.CreateSession(HashSecret(id), time.Now().Add(SessionTTL)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Middleware authenticates API requests when authentication is enabled.
+// Requests carry either a bearer token or the session cookie. Safe methods
+// need the read-only scope, everything else routes:write; use RequireScope
+// for endpoints that need more.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Enabled() {
+			c.Next()
+			return
+		}
+
+		p, err := authenticate(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if p.Session && !safeMethod(c.Request.Method) && !sameOrigin(c.Request) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Cross-origin request rejected"})
+			return
+		}
+
+		scope := ScopeRoutesWrite
+		if safeMethod(c.Request.Method) {
+			scope = ScopeReadOnly
+		}
+		if !p.Allows(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token scope " + p.Scope + " does not allow this request"})
+			return
+		}
+
+		c.Set(principalKey, p)
+		c.Next()
+	}
+}
+
+// RequireScope rejects requests whose principal lacks scope. It must run
+// after Middleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFrom(c)
+		if Enabled() && (!ok || !p.Allows(scope)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This request requires the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// PrincipalFrom returns the authenticated caller of a request, if any.
+func PrincipalFrom(c *gin.Context) (*Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok
+}
+
+// Authenticated reports whether the request carries a valid session or
+// token, regardless of scope.
+func Authenticated(c *gin.Context) bool {
+	_, err := authenticate(c)
+	return err == nil
+}
+
+func authenticate(c *gin.Context) (*Principal, error) {
+	if header := c.GetHeader("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return nil, errors.New("Authorization header must be a bearer token")
+		}
+		t, err := database.GetAPITokenByHash(HashSecret(strings.TrimSpace(token)))
+		if err == sql.ErrNoRows {
+			return nil, errors.New("Invalid API token")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{Name: "token:" + t.Name, Scope: t.Scope}, nil
+	}
+
+	if id, err := c.Cookie(SessionCookie); err == nil && id != "" {
+		valid, err := database.SessionValid(HashSecret(id))
+		if err != nil {
+			return nil, err
+		}
+		if valid {
+			return &Principal{Name: "admin", Scope: ScopeAdmin, Session: true}, nil
+		}
+	}
+	return nil, errors.New("Authentication required")
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// sameOrigin guards cookie-authenticated writes against cross-site
+// requests. Browsers send Origin on such requests; clients that send none
+// are not browsers.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && subtle.ConstantTimeCompare([]byte(u.Host), []byte(r.Host)) == 1
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareScopes(t *testing.T) {
+	if err := database.Init(filepath.Join(t.TempDir(), "devproxy.db")); err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+
+	if err := Init("secret", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer Init("", "")
+
+	tokens := make(map[string]string)
+	for _, scope := range []string{ScopeReadOnly, ScopeRoutesWrite, ScopeAdmin} {
+		token, hash, err := NewToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := database.CreateAPIToken(&models.APIToken{Name: scope, Scope: scope}, hash); err != nil {
+			t.Fatal(err)
+		}
+		tokens[scope] = token
+	}
+	session, err := NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	api := r.Group("/api", Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/routes", ok)
+	api.POST("/routes", ok)
+	api.GET("/tokens", RequireScope(ScopeAdmin), ok)
+
+	tests := []struct {
+		name, method, path, token, cookie, origin string
+		want                                      int
+	}{
+		{"anonymous", "GET", "/api/routes", "", "", "", http.StatusUnauthorized},
+		{"bad token", "GET", "/api/routes", "dpx_nope", "", "", http.StatusUnauthorized},
+		{"read-only reads", "GET", "/api/routes", tokens[ScopeReadOnly], "", "", http.StatusOK},
+		{"read-only writes", "POST", "/api/routes", tokens[ScopeReadOnly], "", "", http.StatusForbidden},
+		{"routes:write writes", "POST", "/api/routes", tokens[ScopeRoutesWrite], "", "", http.StatusOK},
+		{"routes:write lists tokens", "GET", "/api/tokens", tokens[ScopeRoutesWrite], "", "", http.StatusForbidden},
+		{"admin lists tokens", "GET", "/api/tokens", tokens[ScopeAdmin], "", "", http.StatusOK},
+		{"session writes", "POST", "/api/routes", "", session, "http://example.com", http.StatusOK},
+		{"cross-site session write", "POST", "/api/routes", "", session, "http://evil.test", http.StatusForbidden},
+		{"expired session", "GET", "/api/routes", "", "unknown", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d; want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+// routeUsersSchema holds the basic auth accounts of routes. Accounts are
+// kept when their route is deleted, so rolling back to a revision that
+// still has the route restores its protection; route IDs are never reused.
+const routeUsersSchema = `
+	CREATE TABLE IF NOT EXISTS route_users (
+		route_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (route_id, username)
+	)
+`
+
+// GetRouteUsers lists the basic auth accounts of a route without their
+// password hashes.
+func GetRouteUsers(routeID int64) ([]models.BasicAuthUser, error) {
+	rows, err := DB.Query("SELECT username, created_at FROM route_users WHERE route_id = ? ORDER BY username", routeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.BasicAuthUser{}
+	for rows.Next() {
+		var u models.BasicAuthUser
+		if err := rows.Scan(&u.Username, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// getRouteCredentials returns the basic auth accounts, with hashes, of all
+// routes by route ID.
+func getRouteCredentials() (map[int64][]models.BasicAuthUser, error) {
+	rows, err := DB.Query("SELECT route_id, username, password_hash, created_at FROM route_users ORDER BY route_id, username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creds := make(map[int64][]models.BasicAuthUser)
+	for rows.Next() {
+		var id int64
+		var u models.BasicAuthUser
+		if err := rows.Scan(&id, &u.Username, &u.PasswordHash, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		creds[id] = append(creds[id], u)
+	}
+	return creds, rows.Err()
+}
+
+// SetRouteUser creates or replaces a basic auth account. It reports whether
+// the account is new.
+func SetRouteUser(routeID int64, username, passwordHash string) (bool, error) {
+	result, err := DB.Exec("UPDATE route_users SET password_hash = ? WHERE route_id = ? AND username = ?", passwordHash, routeID, username)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return false, nil
+	}
+	_, err = DB.Exec("INSERT INTO route_users (route_id, username, password_hash, created_at) VALUES (?, ?, ?, ?)",
+		routeID, username, passwordHash, time.Now().UTC())
+	return err == nil, err
+}
+
+// DeleteRouteUser removes a basic auth account.
+func DeleteRouteUser(routeID int64, username string) error {
+	result, err := DB.Exec("DELETE FROM route_users WHERE route_id = ? AND username = ?", routeID, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// setRouteCredentials replaces the basic auth accounts of a route.
+func setRouteCredentials(tx *sql.Tx, routeID int64, users []models.BasicAuthUser) error {
+	if _, err := tx.Exec("DELETE FROM route_users WHERE route_id = ?", routeID); err != nil {
+		return err
+	}
+	for _, u := range users {
+		if _, err := tx.Exec("INSERT INTO route_users (route_id, username, password_hash, created_at) VALUES (?, ?, ?, ?)",
+			routeID, u.Username, u.PasswordHash, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
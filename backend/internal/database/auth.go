@@ -0,0 +1,119 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+const apiTokensSchema = `
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME
+	)
+`
+
+const sessionsSchema = `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id_hash TEXT PRIMARY KEY,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL
+	)
+`
+
+// CreateAPIToken stores a token by its hash.
+func CreateAPIToken(t *models.APIToken, hash string) error {
+	t.CreatedAt = time.Now().UTC()
+	result, err := DB.Exec("INSERT INTO api_tokens (name, scope, prefix, token_hash, created_at) VALUES (?, ?, ?, ?, ?)",
+		t.Name, t.Scope, t.Prefix, hash, t.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	t.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetAPITokens lists all tokens, oldest first.
+func GetAPITokens() ([]models.APIToken, error) {
+	rows, err := DB.Query("SELECT id, name, scope, prefix, created_at, last_used_at FROM api_tokens ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetAPITokenByHash looks up a token by its hash and records that it was
+// used.
+func GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	t, err := scanAPIToken(DB.QueryRow("SELECT id, name, scope, prefix, created_at, last_used_at FROM api_tokens WHERE token_hash = ?", hash))
+	if err != nil {
+		return nil, err
+	}
+	DB.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", time.Now().UTC(), t.ID)
+	return &t, nil
+}
+
+// DeleteAPIToken revokes a token. It returns sql.ErrNoRows if the token
+// does not exist.
+func DeleteAPIToken(id string) error {
+	result, err := DB.Exec("DELETE FROM api_tokens WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanAPIToken(s rowScanner) (models.APIToken, error) {
+	var t models.APIToken
+	var lastUsed sql.NullTime
+	err := s.Scan(&t.ID, &t.Name, &t.Scope, &t.Prefix, &t.CreatedAt, &lastUsed)
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return t, err
+}
+
+// CreateSession stores a login session by the hash of its ID.
+func CreateSession(hash string, expires time.Time) error {
+	_, err := DB.Exec("INSERT INTO sessions (id_hash, expires_at) VALUES (?, ?)", hash, expires.UTC())
+	return err
+}
+
+// SessionValid reports whether a session exists and has not expired.
+func SessionValid(hash string) (bool, error) {
+	var n int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sessions WHERE id_hash = ? AND expires_at > ?", hash, time.Now().UTC()).Scan(&n)
+	return n > 0, err
+}
+
+// DeleteSession ends a session.
+func DeleteSession(hash string) error {
+	_, err := DB.Exec("DELETE FROM sessions WHERE id_hash = ?", hash)
+	return err
+}
+
+// DeleteExpiredSessions removes sessions past their expiry.
+func DeleteExpiredSessions() error {
+	_, err := DB.Exec("DELETE FROM sessions WHERE expires_at <= ?", time.Now().UTC())
+	return err
+}
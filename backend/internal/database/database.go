@@ -3,17 +3,189 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"devproxy/internal/models"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 // DB is the database connection pool.
 var DB *sql.DB
 
+// routesSchema is the CREATE TABLE statement for routes; %s is the table name
+// so migrations can build a replacement table with the same layout.
+const routesSchema = `
+	CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		domain TEXT NOT NULL,
+		aliases TEXT NOT NULL DEFAULT '[]',
+		path TEXT NOT NULL DEFAULT '',
+		priority INTEGER NOT NULL DEFAULT 0,
+		strip_prefix INTEGER NOT NULL DEFAULT 0,
+		target TEXT NOT NULL,
+		tls_mode TEXT NOT NULL DEFAULT '',
+		enabled INTEGER DEFAULT 1,
+		source TEXT NOT NULL DEFAULT '',
+		source_ref TEXT NOT NULL DEFAULT '',
+		project_id INTEGER NOT NULL DEFAULT 0,
+		health_check TEXT NOT NULL DEFAULT '{}',
+		headers TEXT NOT NULL DEFAULT '{}',
+		allow_cidrs TEXT NOT NULL DEFAULT '[]',
+		kind TEXT NOT NULL DEFAULT 'proxy',
+		kind_options TEXT NOT NULL DEFAULT '{}',
+		maintenance INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// routeColumns lists the columns read by scanRoute, in scan order.
+const routeColumns = "id, name, domain, aliases, path, priority, strip_prefix, target, tls_mode, enabled, source, source_ref, project_id, health_check, headers, allow_cidrs, kind, kind_options, maintenance, created_at, updated_at"
+
+// ErrDuplicateRoute is returned when a route would repeat the domain and
+// path of another route.
+var ErrDuplicateRoute = errors.New("another route already uses this domain and path")
+
+// routeWriteError translates a unique constraint violation on routes into
+// ErrDuplicateRoute.
+func routeWriteError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrDuplicateRoute
+	}
+	return err
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanRoute reads a route selected with routeColumns.
+func scanRoute(s rowScanner) (models.Route, error) {
+	var r models.Route
+	var aliases, healthCheck, headers, allowCIDRs, kindOptions string
+	var enabled, stripPrefix, maintenance int
+	err := s.Scan(&r.ID, &r.Name, &r.Domain, &aliases, &r.Path, &r.Priority, &stripPrefix, &r.Target, &r.TLSMode, &enabled, &r.Source, &r.SourceRef, &r.ProjectID, &healthCheck, &headers, &allowCIDRs, &r.Kind, &kindOptions, &maintenance, &r.CreatedAt, &r.UpdatedAt)
+	r.Aliases = decodeAliases(aliases)
+	r.AllowCIDRs = decodeAliases(allowCIDRs)
+	r.HealthCheck = decodeHealthCheck(healthCheck)
+	r.Headers = decodeHeaderRules(headers)
+	r.Redirect, r.Static, r.FileServer = decodeKindOptions(kindOptions)
+	r.Enabled = enabled == 1
+	r.StripPrefix = stripPrefix == 1
+	r.Maintenance = maintenance == 1
+	return r, err
+}
+
+// encodeAliases stores route aliases, or any other string list such as
+// allowed CIDRs, as a JSON array.
+func encodeAliases(aliases []string) string {
+	if len(aliases) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(aliases)
+	return string(data)
+}
+
+func decodeAliases(s string) []string {
+	aliases := []string{}
+	json.Unmarshal([]byte(s), &aliases)
+	return aliases
+}
+
+// encodeHealthCheck stores a route health check as a JSON object.
+func encodeHealthCheck(hc models.HealthCheck) string {
+	data, _ := json.Marshal(hc)
+	return string(data)
+}
+
+func decodeHealthCheck(s string) models.HealthCheck {
+	var hc models.HealthCheck
+	json.Unmarshal([]byte(s), &hc)
+	return hc
+}
+
+// encodeHeaderRules stores route header rules as a JSON object; routes
+// without rules store "{}".
+func encodeHeaderRules(h models.HeaderRules) string {
+	if len(h.Request.Set)+len(h.Request.Add)+len(h.Request.Delete)+
+		len(h.Response.Set)+len(h.Response.Add)+len(h.Response.Delete) == 0 {
+		return "{}"
+	}
+	data, _ := json.Marshal(h)
+	return string(data)
+}
+
+func decodeHeaderRules(s string) models.HeaderRules {
+	var h models.HeaderRules
+	json.Unmarshal([]byte(s), &h)
+	return h
+}
+
+// routeKindOptions holds the options of a route's kind in the kind_options
+// column. Only the options of the route's own kind are stored.
+type routeKindOptions struct {
+	Redirect   *models.RedirectOptions   `json:"redirect,omitempty"`
+	Static     *models.StaticResponse    `json:"static,omitempty"`
+	FileServer *models.FileServerOptions `json:"file_server,omitempty"`
+}
+
+// encodeRouteKind stores an empty kind, as in routes saved before kinds
+// existed, as a proxy route.
+func encodeRouteKind(kind string) string {
+	if kind == "" {
+		return models.RouteKindProxy
+	}
+	return kind
+}
+
+func encodeKindOptions(kind string, redirect models.RedirectOptions, static models.StaticResponse, fileServer models.FileServerOptions) string {
+	var opts routeKindOptions
+	switch kind {
+	case models.RouteKindRedirect:
+		opts.Redirect = &redirect
+	case models.RouteKindStatic:
+		opts.Static = &static
+	case models.RouteKindFileServer:
+		opts.FileServer = &fileServer
+	}
+	data, _ := json.Marshal(opts)
+	return string(data)
+}
+
+func decodeKindOptions(s string) (models.RedirectOptions, models.StaticResponse, models.FileServerOptions) {
+	var opts routeKindOptions
+	json.Unmarshal([]byte(s), &opts)
+	var redirect models.RedirectOptions
+	var static models.StaticResponse
+	var fileServer models.FileServerOptions
+	if opts.Redirect != nil {
+		redirect = *opts.Redirect
+	}
+	if opts.Static != nil {
+		static = *opts.Static
+	}
+	if opts.FileServer != nil {
+		fileServer = *opts.FileServer
+	}
+	return redirect, static, fileServer
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // Init opens the database connection and creates tables if needed.
 func Init(dbPath string) error {
 	var err error
@@ -22,21 +194,21 @@ func Init(dbPath string) error {
 		return err
 	}
 
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS routes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			name TEXT NOT NULL,
-			domain TEXT NOT NULL UNIQUE,
-			target TEXT NOT NULL,
-			enabled INTEGER DEFAULT 1,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
+	_, err = DB.Exec(fmt.Sprintf(routesSchema, "routes"))
 	if err != nil {
 		return err
 	}
 
+	if err := migrate(); err != nil {
+		return err
+	}
+
+	for _, schema := range []string{revisionsSchema, projectsSchema, apiTokensSchema, sessionsSchema, healthResultsSchema, healthRollupsSchema, healthTransitionsSchema, webhooksSchema, webhookDeliveriesSchema, routeUsersSchema} {
+		if _, err := DB.Exec(schema); err != nil {
+			return err
+		}
+	}
+
 	log.Println("Database initialized")
 	return nil
 }
@@ -50,7 +222,7 @@ func Close() {
 
 // GetAllRoutes retrieves all routes ordered by name.
 func GetAllRoutes() ([]models.Route, error) {
-	rows, err := DB.Query("SELECT id, name, domain, target, enabled, created_at, updated_at FROM routes ORDER BY name")
+	rows, err := DB.Query("SELECT " + routeColumns + " FROM routes ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -58,12 +230,10 @@ func GetAllRoutes() ([]models.Route, error) {
 
 	var routes []models.Route
 	for rows.Next() {
-		var r models.Route
-		var enabled int
-		if err := rows.Scan(&r.ID, &r.Name, &r.Domain, &r.Target, &enabled, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		r, err := scanRoute(rows)
+		if err != nil {
 			return nil, err
 		}
-		r.Enabled = enabled == 1
 		routes = append(routes, r)
 	}
 
@@ -73,30 +243,41 @@ func GetAllRoutes() ([]models.Route, error) {
 	return routes, nil
 }
 
+// GetRoutesByProject retrieves the routes of a project ordered by name.
+// Project 0 selects routes without a project.
+func GetRoutesByProject(projectID int64) ([]models.Route, error) {
+	rows, err := DB.Query("SELECT "+routeColumns+" FROM routes WHERE project_id = ? ORDER BY name", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []models.Route{}
+	for rows.Next() {
+		r, err := scanRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
 // GetRouteByID retrieves a single route by ID.
 func GetRouteByID(id string) (*models.Route, error) {
-	var r models.Route
-	var enabled int
-	err := DB.QueryRow("SELECT id, name, domain, target, enabled, created_at, updated_at FROM routes WHERE id = ?", id).
-		Scan(&r.ID, &r.Name, &r.Domain, &r.Target, &enabled, &r.CreatedAt, &r.UpdatedAt)
+	r, err := scanRoute(DB.QueryRow("SELECT "+routeColumns+" FROM routes WHERE id = ?", id))
 	if err != nil {
 		return nil, err
 	}
-	r.Enabled = enabled == 1
 	return &r, nil
 }
 
 // CreateRoute inserts a new route and returns the created route.
 func CreateRoute(r *models.Route) error {
-	enabled := 0
-	if r.Enabled {
-		enabled = 1
-	}
-
-	result, err := DB.Exec("INSERT INTO routes (name, domain, target, enabled) VALUES (?, ?, ?, ?)",
-		r.Name, r.Domain, r.Target, enabled)
+	result, err := DB.Exec("INSERT INTO routes (name, domain, aliases, path, priority, strip_prefix, target, tls_mode, enabled, source, source_ref, project_id, health_check, headers, allow_cidrs, kind, kind_options, maintenance) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		r.Name, r.Domain, encodeAliases(r.Aliases), r.Path, r.Priority, boolToInt(r.StripPrefix), r.Target, r.TLSMode, boolToInt(r.Enabled), r.Source, r.SourceRef, r.ProjectID, encodeHealthCheck(r.HealthCheck), encodeHeaderRules(r.Headers), encodeAliases(r.AllowCIDRs), encodeRouteKind(r.Kind), encodeKindOptions(r.Kind, r.Redirect, r.Static, r.FileServer), boolToInt(r.Maintenance))
 	if err != nil {
-		return err
+		return routeWriteError(err)
 	}
 
 	r.ID, _ = result.LastInsertId()
@@ -107,14 +288,9 @@ func CreateRoute(r *models.Route) error {
 
 // UpdateRoute updates an existing route.
 func UpdateRoute(id string, r *models.Route) error {
-	enabled := 0
-	if r.Enabled {
-		enabled = 1
-	}
-
-	_, err := DB.Exec("UPDATE routes SET name = ?, domain = ?, target = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		r.Name, r.Domain, r.Target, enabled, id)
-	return err
+	_, err := DB.Exec("UPDATE routes SET name = ?, domain = ?, aliases = ?, path = ?, priority = ?, strip_prefix = ?, target = ?, tls_mode = ?, enabled = ?, project_id = ?, health_check = ?, headers = ?, allow_cidrs = ?, kind = ?, kind_options = ?, maintenance = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		r.Name, r.Domain, encodeAliases(r.Aliases), r.Path, r.Priority, boolToInt(r.StripPrefix), r.Target, r.TLSMode, boolToInt(r.Enabled), r.ProjectID, encodeHealthCheck(r.HealthCheck), encodeHeaderRules(r.Headers), encodeAliases(r.AllowCIDRs), encodeRouteKind(r.Kind), encodeKindOptions(r.Kind, r.Redirect, r.Static, r.FileServer), boolToInt(r.Maintenance), id)
+	return routeWriteError(err)
 }
 
 // DeleteRoute removes a route by ID.
@@ -129,9 +305,16 @@ func ToggleRoute(id string) error {
 	return err
 }
 
-// GetEnabledRoutes retrieves all enabled routes.
+// SetRouteMaintenance switches the maintenance mode of a route.
+func SetRouteMaintenance(id int64, maintenance bool) error {
+	_, err := DB.Exec("UPDATE routes SET maintenance = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", boolToInt(maintenance), id)
+	return err
+}
+
+// GetEnabledRoutes retrieves all enabled routes with their basic auth
+// credentials, for config generation.
 func GetEnabledRoutes() ([]models.Route, error) {
-	rows, err := DB.Query("SELECT id, domain, target FROM routes WHERE enabled = 1")
+	rows, err := DB.Query("SELECT " + routeColumns + " FROM routes WHERE enabled = 1 ORDER BY domain, priority DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -139,74 +322,244 @@ func GetEnabledRoutes() ([]models.Route, error) {
 
 	var routes []models.Route
 	for rows.Next() {
-		var r models.Route
-		if err := rows.Scan(&r.ID, &r.Domain, &r.Target); err != nil {
+		r, err := scanRoute(rows)
+		if err != nil {
 			continue
 		}
 		routes = append(routes, r)
 	}
+	rows.Close()
+
+	creds, err := getRouteCredentials()
+	if err != nil {
+		return nil, err
+	}
+	for i := range routes {
+		routes[i].BasicAuth = creds[routes[i].ID]
+	}
+	return routes, nil
+}
+
+// GetRoutesBySource retrieves all routes managed by the given source.
+func GetRoutesBySource(source string) ([]models.Route, error) {
+	rows, err := DB.Query("SELECT "+routeColumns+" FROM routes WHERE source = ? ORDER BY id", source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []models.Route
+	for rows.Next() {
+		r, err := scanRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
 	return routes, nil
 }
 
-// GetAppliedRoutes retrieves all routes for the applied state comparison.
-func GetAppliedRoutes() ([]models.AppliedRoute, error) {
-	rows, err := DB.Query("SELECT id, name, domain, target, enabled FROM routes ORDER BY id")
+// SetRouteEnabled sets the enabled state of a route.
+func SetRouteEnabled(id int64, enabled bool) error {
+	_, err := DB.Exec("UPDATE routes SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", boolToInt(enabled), id)
+	return err
+}
+
+// GetRoutesByDomain retrieves all routes sharing the given domain.
+func GetRoutesByDomain(domain string) ([]models.Route, error) {
+	rows, err := DB.Query("SELECT "+routeColumns+" FROM routes WHERE domain = ? ORDER BY priority DESC", domain)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var routes []models.AppliedRoute
+	var routes []models.Route
 	for rows.Next() {
-		var r models.AppliedRoute
-		var enabled int
-		if err := rows.Scan(&r.ID, &r.Name, &r.Domain, &r.Target, &enabled); err != nil {
-			continue
+		r, err := scanRoute(rows)
+		if err != nil {
+			return nil, err
 		}
-		r.Enabled = enabled == 1
 		routes = append(routes, r)
 	}
 	return routes, nil
 }
 
-// GetExportRoutes retrieves routes for export.
-func GetExportRoutes() ([]map[string]interface{}, error) {
-	rows, err := DB.Query("SELECT name, domain, target, enabled FROM routes ORDER BY name")
+// GetAppliedRoutes retrieves all routes for the applied state comparison.
+func GetAppliedRoutes() ([]models.AppliedRoute, error) {
+	rows, err := DB.Query("SELECT " + routeColumns + " FROM routes ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var routes []map[string]interface{}
+	var routes []models.AppliedRoute
 	for rows.Next() {
-		var name, domain, target string
-		var enabled int
-		if err := rows.Scan(&name, &domain, &target, &enabled); err != nil {
+		r, err := scanRoute(rows)
+		if err != nil {
 			continue
 		}
-		routes = append(routes, map[string]interface{}{
-			"name":    name,
-			"domain":  domain,
-			"target":  target,
-			"enabled": enabled == 1,
+		routes = append(routes, models.AppliedRoute{
+			ID:          r.ID,
+			Name:        r.Name,
+			Domain:      r.Domain,
+			Aliases:     r.Aliases,
+			Path:        r.Path,
+			Priority:    r.Priority,
+			StripPrefix: r.StripPrefix,
+			Kind:        r.Kind,
+			Target:      r.Target,
+			Redirect:    r.Redirect,
+			Static:      r.Static,
+			FileServer:  r.FileServer,
+			TLSMode:     r.TLSMode,
+			Enabled:     r.Enabled,
+			Maintenance: r.Maintenance,
+			Headers:     r.Headers,
+			AllowCIDRs:  r.AllowCIDRs,
 		})
 	}
 	return routes, nil
 }
 
-// ImportRoutes imports routes from a list, using INSERT OR REPLACE.
-func ImportRoutes(routes []models.ImportRoute) (int, error) {
-	imported := 0
-	for _, r := range routes {
-		enabled := 0
-		if r.Enabled {
-			enabled = 1
+// GetExportRoutes retrieves all routes for export, with their project names
+// and basic auth hashes.
+func GetExportRoutes() ([]models.ImportRoute, error) {
+	return queryExportRoutes("")
+}
+
+// GetProjectExportRoutes retrieves the routes of one project for export.
+func GetProjectExportRoutes(projectID int64) ([]models.ImportRoute, error) {
+	return queryExportRoutes("WHERE project_id = ?", projectID)
+}
+
+func queryExportRoutes(where string, args ...interface{}) ([]models.ImportRoute, error) {
+	creds, err := getRouteCredentials()
+	if err != nil {
+		return nil, err
+	}
+	projects := make(map[int64]string)
+	projectRows, err := DB.Query("SELECT id, name FROM projects")
+	if err != nil {
+		return nil, err
+	}
+	for projectRows.Next() {
+		var id int64
+		var name string
+		if err := projectRows.Scan(&id, &name); err != nil {
+			projectRows.Close()
+			return nil, err
+		}
+		projects[id] = name
+	}
+	projectRows.Close()
+
+	rows, err := DB.Query("SELECT "+routeColumns+" FROM routes "+where+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []models.ImportRoute{}
+	for rows.Next() {
+		r, err := scanRoute(rows)
+		if err != nil {
+			return nil, err
+		}
+		route := models.ImportRoute{
+			Name:        r.Name,
+			Domain:      r.Domain,
+			Aliases:     r.Aliases,
+			Path:        r.Path,
+			Priority:    r.Priority,
+			StripPrefix: r.StripPrefix,
+			Kind:        r.Kind,
+			Target:      r.Target,
+			Redirect:    r.Redirect,
+			Static:      r.Static,
+			FileServer:  r.FileServer,
+			TLSMode:     r.TLSMode,
+			Enabled:     r.Enabled,
+			Maintenance: r.Maintenance,
+			Project:     projects[r.ProjectID],
+			HealthCheck: r.HealthCheck,
+			Headers:     r.Headers,
+			AllowCIDRs:  r.AllowCIDRs,
+		}
+		// Credentials are exported as bcrypt hashes only.
+		for _, u := range creds[r.ID] {
+			route.BasicAuth = append(route.BasicAuth, models.BasicAuthUser{Username: u.Username, PasswordHash: u.PasswordHash})
+		}
+		routes = append(routes, route)
+	}
+	return routes, rows.Err()
+}
+
+// ApplyImport writes a planned import in one transaction: either every
+// route is written or none is. With replaceAll, manual routes are deleted
+// first; routes managed by Docker discovery are kept. Projects that do not
+// exist yet are created. Updated routes keep their ID and source, and their
+// project unless the import names one. Updates may swap domains and
+// paths between routes.
+func ApplyImport(replaceAll bool, projects []models.ExportProject, create []models.ImportRoute, update map[int64]models.ImportRoute) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replaceAll {
+		if _, err := tx.Exec("DELETE FROM routes WHERE source = ''"); err != nil {
+			return err
+		}
+	}
+	for _, p := range projects {
+		if _, err := tx.Exec("INSERT INTO projects (name, color, description) VALUES (?, ?, ?) ON CONFLICT(name) DO NOTHING",
+			p.Name, p.Color, p.Description); err != nil {
+			return err
+		}
+	}
+	// Updates may move a domain and path from one route to another, so the
+	// keys of updated routes are released first and then written in ID
+	// order; the unique index sees no transient duplicates.
+	ids := make([]int64, 0, len(update))
+	for id := range update {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		if _, err := tx.Exec("UPDATE routes SET path = ? WHERE id = ?", fmt.Sprintf("\x00import-%d", id), id); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		r := update[id]
+		result, err := tx.Exec("UPDATE routes SET name = ?, domain = ?, aliases = ?, path = ?, priority = ?, strip_prefix = ?, target = ?, tls_mode = ?, enabled = ?, health_check = ?, headers = ?, allow_cidrs = ?, kind = ?, kind_options = ?, maintenance = ?, project_id = COALESCE((SELECT id FROM projects WHERE name = ?), project_id), updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+			r.Name, r.Domain, encodeAliases(r.Aliases), r.Path, r.Priority, boolToInt(r.StripPrefix), r.Target, r.TLSMode, boolToInt(r.Enabled), encodeHealthCheck(r.HealthCheck), encodeHeaderRules(r.Headers), encodeAliases(r.AllowCIDRs), encodeRouteKind(r.Kind), encodeKindOptions(r.Kind, r.Redirect, r.Static, r.FileServer), boolToInt(r.Maintenance), r.Project, id)
+		if err != nil {
+			return routeWriteError(err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("route %d no longer exists", id)
+		}
+		if err := setRouteCredentials(tx, id, r.BasicAuth); err != nil {
+			return err
+		}
+	}
+	for _, r := range create {
+		result, err := tx.Exec("INSERT INTO routes (name, domain, aliases, path, priority, strip_prefix, target, tls_mode, enabled, health_check, headers, allow_cidrs, kind, kind_options, maintenance, project_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE((SELECT id FROM projects WHERE name = ?), 0))",
+			r.Name, r.Domain, encodeAliases(r.Aliases), r.Path, r.Priority, boolToInt(r.StripPrefix), r.Target, r.TLSMode, boolToInt(r.Enabled), encodeHealthCheck(r.HealthCheck), encodeHeaderRules(r.Headers), encodeAliases(r.AllowCIDRs), encodeRouteKind(r.Kind), encodeKindOptions(r.Kind, r.Redirect, r.Static, r.FileServer), boolToInt(r.Maintenance), r.Project)
+		if err != nil {
+			return routeWriteError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
 		}
-		_, err := DB.Exec("INSERT OR REPLACE INTO routes (name, domain, target, enabled) VALUES (?, ?, ?, ?)",
-			r.Name, r.Domain, r.Target, enabled)
-		if err == nil {
-			imported++
+		if err := setRouteCredentials(tx, id, r.BasicAuth); err != nil {
+			return err
 		}
 	}
-	return imported, nil
+	return tx.Commit()
 }
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+const healthResultsSchema = `
+	CREATE TABLE IF NOT EXISTS health_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		route_id INTEGER NOT NULL,
+		checked_at DATETIME NOT NULL,
+		passed INTEGER NOT NULL,
+		healthy INTEGER NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		response_time_ms INTEGER NOT NULL DEFAULT 0,
+		error_type TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_health_results_route ON health_results (route_id, checked_at)
+`
+
+const healthRollupsSchema = `
+	CREATE TABLE IF NOT EXISTS health_rollups (
+		route_id INTEGER NOT NULL,
+		bucket_start DATETIME NOT NULL,
+		bucket_seconds INTEGER NOT NULL,
+		checks INTEGER NOT NULL,
+		passed INTEGER NOT NULL,
+		latency TEXT NOT NULL DEFAULT '[]',
+		PRIMARY KEY (route_id, bucket_start)
+	)
+`
+
+const healthTransitionsSchema = `
+	CREATE TABLE IF NOT EXISTS health_transitions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		route_id INTEGER NOT NULL,
+		domain TEXT NOT NULL DEFAULT '',
+		healthy INTEGER NOT NULL,
+		error_type TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_health_transitions_route ON health_transitions (route_id, at)
+`
+
+// CreateHealthResult stores a health probe.
+func CreateHealthResult(r models.HealthResult) error {
+	_, err := DB.Exec("INSERT INTO health_results (route_id, checked_at, passed, healthy, status_code, response_time_ms, error_type) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		r.RouteID, r.CheckedAt.UTC(), boolToInt(r.Passed), boolToInt(r.Healthy), r.StatusCode, r.ResponseTime, r.ErrorType)
+	return err
+}
+
+// GetHealthResults lists the stored probes in [from, to), oldest first. A
+// routeID of 0 selects all routes.
+func GetHealthResults(routeID int64, from, to time.Time) ([]models.HealthResult, error) {
+	rows, err := DB.Query("SELECT route_id, checked_at, passed, healthy, status_code, response_time_ms, error_type FROM health_results WHERE (? = 0 OR route_id = ?) AND checked_at >= ? AND checked_at < ? ORDER BY checked_at",
+		routeID, routeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.HealthResult
+	for rows.Next() {
+		var r models.HealthResult
+		var passed, healthy int
+		if err := rows.Scan(&r.RouteID, &r.CheckedAt, &passed, &healthy, &r.StatusCode, &r.ResponseTime, &r.ErrorType); err != nil {
+			return nil, err
+		}
+		r.Passed = passed == 1
+		r.Healthy = healthy == 1
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// GetHealthRollups lists the rollups starting in [from, to), oldest first.
+// A routeID of 0 selects all routes.
+func GetHealthRollups(routeID int64, from, to time.Time) ([]models.HealthRollup, error) {
+	rows, err := DB.Query("SELECT route_id, bucket_start, bucket_seconds, checks, passed, latency FROM health_rollups WHERE (? = 0 OR route_id = ?) AND bucket_start >= ? AND bucket_start < ? ORDER BY bucket_start",
+		routeID, routeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollups []models.HealthRollup
+	for rows.Next() {
+		var r models.HealthRollup
+		var latency string
+		if err := rows.Scan(&r.RouteID, &r.BucketStart, &r.BucketSeconds, &r.Checks, &r.Passed, &latency); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(latency), &r.Latency)
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+// RollupHealthResults replaces the raw probes older than cutoff with the
+// given rollups in one transaction. Rollups for a bucket that already exists
+// are merged into it.
+func RollupHealthResults(cutoff time.Time, rollups []models.HealthRollup) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, r := range rollups {
+		var checks, passed int
+		var latency string
+		err := tx.QueryRow("SELECT checks, passed, latency FROM health_rollups WHERE route_id = ? AND bucket_start = ?",
+			r.RouteID, r.BucketStart.UTC()).Scan(&checks, &passed, &latency)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return err
+		default:
+			var existing []int64
+			json.Unmarshal([]byte(latency), &existing)
+			r.Checks += checks
+			r.Passed += passed
+			r.Latency = addCounts(r.Latency, existing)
+		}
+
+		data, _ := json.Marshal(r.Latency)
+		if _, err := tx.Exec("INSERT OR REPLACE INTO health_rollups (route_id, bucket_start, bucket_seconds, checks, passed, latency) VALUES (?, ?, ?, ?, ?, ?)",
+			r.RouteID, r.BucketStart.UTC(), r.BucketSeconds, r.Checks, r.Passed, string(data)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM health_results WHERE checked_at < ?", cutoff.UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PruneHealthHistory deletes all health history older than cutoff.
+func PruneHealthHistory(cutoff time.Time) error {
+	for _, stmt := range []string{
+		"DELETE FROM health_results WHERE checked_at < ?",
+		"DELETE FROM health_rollups WHERE bucket_start < ?",
+		"DELETE FROM health_transitions WHERE at < ?",
+	} {
+		if _, err := DB.Exec(stmt, cutoff.UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateHealthTransition stores a health state change and sets its ID.
+func CreateHealthTransition(t *models.HealthTransition) error {
+	result, err := DB.Exec("INSERT INTO health_transitions (route_id, domain, healthy, error_type, error, at) VALUES (?, ?, ?, ?, ?, ?)",
+		t.RouteID, t.Domain, boolToInt(t.Healthy), t.ErrorType, t.Error, t.At.UTC())
+	if err != nil {
+		return err
+	}
+
+	t.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetLastHealthTransition retrieves the latest transition of a route. It
+// returns sql.ErrNoRows if there is none.
+func GetLastHealthTransition(routeID int64) (models.HealthTransition, error) {
+	row := DB.QueryRow("SELECT id, route_id, domain, healthy, error_type, error, at FROM health_transitions WHERE route_id = ? ORDER BY at DESC, id DESC LIMIT 1", routeID)
+	return scanHealthTransition(row)
+}
+
+// GetHealthTransitions lists transitions in [from, to), newest first. A
+// routeID of 0 selects all routes.
+func GetHealthTransitions(routeID int64, from, to time.Time, limit int) ([]models.HealthTransition, error) {
+	rows, err := DB.Query("SELECT id, route_id, domain, healthy, error_type, error, at FROM health_transitions WHERE (? = 0 OR route_id = ?) AND at >= ? AND at < ? ORDER BY at DESC, id DESC LIMIT ?",
+		routeID, routeID, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []models.HealthTransition{}
+	for rows.Next() {
+		t, err := scanHealthTransition(rows)
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, rows.Err()
+}
+
+func scanHealthTransition(s rowScanner) (models.HealthTransition, error) {
+	var t models.HealthTransition
+	var healthy int
+	err := s.Scan(&t.ID, &t.RouteID, &t.Domain, &healthy, &t.ErrorType, &t.Error, &t.At)
+	t.Healthy = healthy == 1
+	return t, err
+}
+
+// addCounts adds two histograms of possibly different length.
+func addCounts(a, b []int64) []int64 {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	sum := append([]int64(nil), a...)
+	for i, n := range b {
+		sum[i] += n
+	}
+	return sum
+}
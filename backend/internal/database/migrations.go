@@ -0,0 +1,165 @@
+package database
+
+import (
+	"fmt"
+	"log"
+)
+
+// migrate brings databases created by older versions up to the current schema.
+func migrate() error {
+	legacy, err := hasUniqueDomainIndex()
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if err := rebuildRoutesTable(); err != nil {
+			return fmt.Errorf("migrate routes table: %w", err)
+		}
+		log.Println("Migrated routes table to path-based routing schema")
+	}
+
+	if err := ensureColumn("routes", "tls_mode", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "aliases", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "source", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "source_ref", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "project_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "health_check", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "headers", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "allow_cidrs", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "kind", "TEXT NOT NULL DEFAULT 'proxy'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "kind_options", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := ensureColumn("routes", "maintenance", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	_, err = DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_routes_domain_path ON routes (domain, path)")
+	return err
+}
+
+// ensureColumn adds a column to an existing table if it is missing.
+func ensureColumn(table, column, definition string) error {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%q)", table))
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			found = true
+		}
+	}
+	rows.Close()
+
+	if found {
+		return nil
+	}
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %q ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// hasUniqueDomainIndex reports whether the routes table still carries the
+// original "domain TEXT NOT NULL UNIQUE" constraint, which prevents several
+// routes from sharing a domain.
+func hasUniqueDomainIndex() (bool, error) {
+	rows, err := DB.Query("PRAGMA index_list(routes)")
+	if err != nil {
+		return false, err
+	}
+
+	var candidates []string
+	for rows.Next() {
+		var seq, unique, partial int
+		var name, origin string
+		if err := rows.Scan(&seq, &name, &unique, &origin, &partial); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if unique == 1 && origin == "u" {
+			candidates = append(candidates, name)
+		}
+	}
+	rows.Close()
+
+	for _, name := range candidates {
+		columns, err := indexColumns(name)
+		if err != nil {
+			return false, err
+		}
+		if len(columns) == 1 && columns[0] == "domain" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func indexColumns(index string) ([]string, error) {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA index_info(%q)", index))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var seqno, cid int
+		var name string
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// rebuildRoutesTable copies routes into a table with the current schema.
+// SQLite cannot drop a column constraint in place, so the table is recreated.
+func rebuildRoutesTable() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"DROP TABLE IF EXISTS routes_new",
+		fmt.Sprintf(routesSchema, "routes_new"),
+		`INSERT INTO routes_new (id, name, domain, target, enabled, created_at, updated_at)
+			SELECT id, name, domain, target, enabled, created_at, updated_at FROM routes`,
+		"DROP TABLE routes",
+		"ALTER TABLE routes_new RENAME TO routes",
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+const projectsSchema = `
+	CREATE TABLE IF NOT EXISTS projects (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		color TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// projectQuery selects projects with their route counts.
+const projectQuery = `
+	SELECT p.id, p.name, p.color, p.description,
+		COUNT(r.id), COALESCE(SUM(r.enabled), 0),
+		p.created_at, p.updated_at
+	FROM projects p LEFT JOIN routes r ON r.project_id = p.id`
+
+// IsUniqueViolation reports whether err is a SQLite UNIQUE constraint error.
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+func scanProject(s rowScanner) (models.Project, error) {
+	var p models.Project
+	err := s.Scan(&p.ID, &p.Name, &p.Color, &p.Description, &p.RouteCount, &p.EnabledCount, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// GetAllProjects retrieves all projects ordered by name.
+func GetAllProjects() ([]models.Project, error) {
+	rows, err := DB.Query(projectQuery + " GROUP BY p.id ORDER BY p.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// GetProjectByID retrieves a single project by ID.
+func GetProjectByID(id string) (*models.Project, error) {
+	p, err := scanProject(DB.QueryRow(projectQuery+" WHERE p.id = ? GROUP BY p.id", id))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ProjectExists reports whether a project with the given ID exists.
+func ProjectExists(id int64) (bool, error) {
+	var n int
+	err := DB.QueryRow("SELECT COUNT(*) FROM projects WHERE id = ?", id).Scan(&n)
+	return n > 0, err
+}
+
+// CreateProject inserts a new project.
+func CreateProject(p *models.Project) error {
+	result, err := DB.Exec("INSERT INTO projects (name, color, description) VALUES (?, ?, ?)",
+		p.Name, p.Color, p.Description)
+	if err != nil {
+		return err
+	}
+
+	p.ID, _ = result.LastInsertId()
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateProject updates the name, color and description of a project.
+func UpdateProject(id string, p *models.Project) error {
+	result, err := DB.Exec("UPDATE projects SET name = ?, color = ?, description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		p.Name, p.Color, p.Description, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteProject removes a project. Its routes are kept without a project.
+func DeleteProject(id string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE routes SET project_id = 0 WHERE project_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM projects WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AssignRoutesToProject moves routes into a project. Project 0 removes them
+// from their project. It returns the number of routes moved.
+func AssignRoutesToProject(projectID int64, routeIDs []int64) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	moved := 0
+	for _, id := range routeIDs {
+		result, err := tx.Exec("UPDATE routes SET project_id = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", projectID, id)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		moved += int(n)
+	}
+	return moved, tx.Commit()
+}
+
+// SetRoutesEnabled sets the enabled state of the given routes in one
+// transaction.
+func SetRoutesEnabled(routeIDs []int64, enabled bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range routeIDs {
+		if _, err := tx.Exec("UPDATE routes SET enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", boolToInt(enabled), id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteRoutes removes the given routes in one transaction.
+func DeleteRoutes(routeIDs []int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range routeIDs {
+		if _, err := tx.Exec("DELETE FROM routes WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+const revisionsSchema = `
+	CREATE TABLE IF NOT EXISTS revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		author TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL DEFAULT '',
+		route_count INTEGER NOT NULL DEFAULT 0,
+		routes TEXT NOT NULL DEFAULT '[]',
+		config TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// CreateRevision stores a new revision and sets its ID and timestamp.
+func CreateRevision(rev *models.Revision) error {
+	routes, err := json.Marshal(rev.Routes)
+	if err != nil {
+		return err
+	}
+	config := string(rev.Config)
+	if config == "" {
+		config = "{}"
+	}
+
+	rev.RouteCount = len(rev.Routes)
+	rev.CreatedAt = time.Now().UTC()
+	result, err := DB.Exec("INSERT INTO revisions (author, message, route_count, routes, config, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		rev.Author, rev.Message, rev.RouteCount, string(routes), config, rev.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rev.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// GetRevisions lists the most recent revisions, newest first, without their
+// routes and config.
+func GetRevisions(limit int) ([]models.Revision, error) {
+	rows, err := DB.Query("SELECT id, author, message, route_count, created_at FROM revisions ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		var rev models.Revision
+		if err := rows.Scan(&rev.ID, &rev.Author, &rev.Message, &rev.RouteCount, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision retrieves a revision with its routes and config.
+func GetRevision(id string) (*models.Revision, error) {
+	return scanRevision(DB.QueryRow("SELECT id, author, message, route_count, routes, config, created_at FROM revisions WHERE id = ?", id))
+}
+
+// GetLatestRevision retrieves the most recent revision. It returns
+// sql.ErrNoRows if nothing has been applied yet.
+func GetLatestRevision() (*models.Revision, error) {
+	return scanRevision(DB.QueryRow("SELECT id, author, message, route_count, routes, config, created_at FROM revisions ORDER BY id DESC LIMIT 1"))
+}
+
+// GetPreviousRevision retrieves the revision applied before id. It returns
+// sql.ErrNoRows for the first revision.
+func GetPreviousRevision(id int64) (*models.Revision, error) {
+	return scanRevision(DB.QueryRow("SELECT id, author, message, route_count, routes, config, created_at FROM revisions WHERE id < ? ORDER BY id DESC LIMIT 1", id))
+}
+
+func scanRevision(s rowScanner) (*models.Revision, error) {
+	var rev models.Revision
+	var routes, config string
+	if err := s.Scan(&rev.ID, &rev.Author, &rev.Message, &rev.RouteCount, &routes, &config, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(routes), &rev.Routes); err != nil {
+		return nil, err
+	}
+	if rev.Routes == nil {
+		rev.Routes = []models.Route{}
+	}
+	rev.Config = json.RawMessage(config)
+	return &rev, nil
+}
+
+// ReplaceRoutes replaces the whole route table with the given routes,
+// keeping their IDs and timestamps. It is used to roll back to a revision.
+// Routes of projects that no longer exist are left without a project.
+func ReplaceRoutes(routes []models.Route) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM routes"); err != nil {
+		return err
+	}
+	for _, r := range routes {
+		_, err := tx.Exec("INSERT INTO routes ("+routeColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE((SELECT id FROM projects WHERE id = ?), 0), ?, ?, ?, ?, ?, ?, ?, ?)",
+			r.ID, r.Name, r.Domain, encodeAliases(r.Aliases), r.Path, r.Priority, boolToInt(r.StripPrefix), r.Target, r.TLSMode, boolToInt(r.Enabled), r.Source, r.SourceRef, r.ProjectID, encodeHealthCheck(r.HealthCheck), encodeHeaderRules(r.Headers), encodeAliases(r.AllowCIDRs), encodeRouteKind(r.Kind), encodeKindOptions(r.Kind, r.Redirect, r.Static, r.FileServer), boolToInt(r.Maintenance), r.CreatedAt, r.UpdatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
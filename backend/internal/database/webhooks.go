@@ -0,0 +1,177 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+const webhooksSchema = `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		format TEXT NOT NULL DEFAULT 'generic',
+		events TEXT NOT NULL DEFAULT '[]',
+		secret TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+const webhookDeliveriesSchema = `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event_id INTEGER NOT NULL,
+		event_type TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		success INTEGER NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		payload TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id)
+`
+
+// webhookDeliveriesKept is the number of deliveries kept per webhook.
+const webhookDeliveriesKept = 200
+
+const webhookColumns = "id, name, url, format, events, secret, enabled, created_at, updated_at"
+
+// scanWebhook reads a webhook selected with webhookColumns, including its
+// secret.
+func scanWebhook(s rowScanner) (models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	var enabled int
+	err := s.Scan(&w.ID, &w.Name, &w.URL, &w.Format, &events, &w.Secret, &enabled, &w.CreatedAt, &w.UpdatedAt)
+	w.Events = []string{}
+	json.Unmarshal([]byte(events), &w.Events)
+	w.Enabled = enabled == 1
+	w.HasSecret = w.Secret != ""
+	return w, err
+}
+
+// GetAllWebhooks retrieves all webhooks with their secrets.
+func GetAllWebhooks() ([]models.Webhook, error) {
+	rows, err := DB.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhookByID retrieves a single webhook with its secret.
+func GetWebhookByID(id string) (*models.Webhook, error) {
+	w, err := scanWebhook(DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateWebhook inserts a new webhook.
+func CreateWebhook(w *models.Webhook) error {
+	events, _ := json.Marshal(w.Events)
+	result, err := DB.Exec("INSERT INTO webhooks (name, url, format, events, secret, enabled) VALUES (?, ?, ?, ?, ?, ?)",
+		w.Name, w.URL, w.Format, string(events), w.Secret, boolToInt(w.Enabled))
+	if err != nil {
+		return err
+	}
+
+	w.ID, _ = result.LastInsertId()
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateWebhook updates all fields of a webhook, including its secret.
+func UpdateWebhook(id string, w *models.Webhook) error {
+	events, _ := json.Marshal(w.Events)
+	result, err := DB.Exec("UPDATE webhooks SET name = ?, url = ?, format = ?, events = ?, secret = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		w.Name, w.URL, w.Format, string(events), w.Secret, boolToInt(w.Enabled), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+func DeleteWebhook(id string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateWebhookDelivery logs a delivery attempt and drops the oldest
+// entries beyond webhookDeliveriesKept.
+func CreateWebhookDelivery(d *models.WebhookDelivery) error {
+	d.CreatedAt = time.Now().UTC()
+	result, err := DB.Exec("INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, attempt, success, status_code, error, duration_ms, payload, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		d.WebhookID, d.EventID, d.EventType, d.Attempt, boolToInt(d.Success), d.StatusCode, d.Error, d.Duration, d.Payload, d.CreatedAt)
+	if err != nil {
+		return err
+	}
+	d.ID, _ = result.LastInsertId()
+
+	_, err = DB.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ? AND id <= (
+		SELECT id FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?)`,
+		d.WebhookID, d.WebhookID, webhookDeliveriesKept)
+	return err
+}
+
+// GetWebhookDeliveries lists the latest delivery attempts of a webhook,
+// newest first.
+func GetWebhookDeliveries(webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := DB.Query("SELECT id, webhook_id, event_id, event_type, attempt, success, status_code, error, duration_ms, payload, created_at FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?",
+		webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var success int
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Attempt, &success, &d.StatusCode, &d.Error, &d.Duration, &d.Payload, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Success = success == 1
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,151 @@
+// Package docker is a minimal client for the Docker Engine API, used to
+// discover containers on the dev-proxy network from their labels.
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Container is the subset of the Docker container summary DevProxy uses.
+type Container struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Image           string            `json:"Image"`
+	State           string            `json:"State"`
+	Labels          map[string]string `json:"Labels"`
+	Ports           []Port            `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]Network `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// Port is a port exposed by a container.
+type Port struct {
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort,omitempty"`
+	Type        string `json:"Type"`
+}
+
+// Network describes a container's attachment to a network.
+type Network struct {
+	IPAddress string `json:"IPAddress"`
+}
+
+// Name returns the container name without Docker's leading slash.
+func (c Container) Name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// Event is a container lifecycle event from the Docker event stream.
+type Event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time int64 `json:"time"`
+}
+
+// Client talks to the Docker Engine API over a unix socket.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client for the Docker daemon listening on socketPath.
+func NewClient(socketPath string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport}}
+}
+
+// ListContainers returns the running containers attached to a network.
+func (c *Client) ListContainers(ctx context.Context, network string) ([]Container, error) {
+	filters, _ := json.Marshal(map[string][]string{"network": {network}})
+	resp, err := c.get(ctx, "/containers/json?filters="+url.QueryEscape(string(filters)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var containers []Container
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("decode containers: %w", err)
+	}
+	return containers, nil
+}
+
+// Events streams container start/stop events until ctx is cancelled or the
+// connection drops. The returned error channel receives exactly one value
+// when the stream ends.
+func (c *Client) Events(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(events)
+
+		filters, _ := json.Marshal(map[string][]string{
+			"type":  {"container"},
+			"event": {"start", "stop", "die", "destroy", "connect", "disconnect", "update"},
+		})
+		resp, err := c.get(ctx, "/events?filters="+url.QueryEscape(string(filters)))
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var e Event
+			if err := dec.Decode(&e); err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				errs <- fmt.Errorf("event stream: %w", err)
+				return
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+func (c *Client) get(ctx context.Context, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connect to Docker: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("Docker returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,72 @@
+package docker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"devproxy/internal/docker"
+	"devproxy/internal/docker/dockertest"
+)
+
+func TestListContainersFiltersNetwork(t *testing.T) {
+	srv, err := dockertest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	srv.SetContainers(
+		dockertest.NewContainer("a1", "shop-web-1", "dev-proxy", map[string]string{"devproxy.domain": "shop.test"}, 80),
+		dockertest.NewContainer("b2", "other-db-1", "other", nil, 5432),
+	)
+
+	client := docker.NewClient(srv.SocketPath)
+	containers, err := client.ListContainers(context.Background(), "dev-proxy")
+	if err != nil {
+		t.Fatalf("ListContainers() error = %v", err)
+	}
+	if len(containers) != 1 || containers[0].Name() != "shop-web-1" {
+		t.Fatalf("ListContainers() = %+v; want only shop-web-1", containers)
+	}
+	if containers[0].Labels["devproxy.domain"] != "shop.test" {
+		t.Errorf("labels not decoded: %v", containers[0].Labels)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	srv, err := dockertest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, errs := docker.NewClient(srv.SocketPath).Events(ctx)
+
+	// The stream registers asynchronously; emit until the event arrives.
+	var e docker.Event
+	e.Type, e.Action, e.Actor.ID = "container", "start", "a1"
+	deadline := time.After(2 * time.Second)
+	for {
+		srv.Emit(e)
+		select {
+		case got := <-events:
+			if got.Action != "start" || got.Actor.ID != "a1" {
+				t.Fatalf("received %+v; want start of a1", got)
+			}
+			cancel()
+			if err := <-errs; err == nil {
+				t.Error("expected an error after the stream was cancelled")
+			}
+			return
+		case err := <-errs:
+			t.Fatalf("event stream ended: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package dockertest provides a fake Docker Engine API served on a unix
+// socket, for testing code that uses the docker package.
+package dockertest
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"devproxy/internal/docker"
+)
+
+// Server is a fake Docker daemon. Containers are filtered by the "network"
+// filter like the real API; events are delivered to every open event stream.
+type Server struct {
+	SocketPath string
+
+	mu         sync.Mutex
+	containers []docker.Container
+	streams    map[chan docker.Event]struct{}
+
+	dir      string
+	listener net.Listener
+	srv      *http.Server
+}
+
+// NewServer starts a fake daemon on a socket in a new temporary directory.
+func NewServer() (*Server, error) {
+	dir, err := os.MkdirTemp("", "dockertest")
+	if err != nil {
+		return nil, err
+	}
+
+	socketPath := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := &Server{
+		SocketPath: socketPath,
+		streams:    make(map[chan docker.Event]struct{}),
+		dir:        dir,
+		listener:   listener,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/containers/json", s.handleContainers)
+	mux.HandleFunc("/events", s.handleEvents)
+	s.srv = &http.Server{Handler: mux}
+
+	go s.srv.Serve(listener)
+	return s, nil
+}
+
+// SetContainers replaces the set of running containers.
+func (s *Server) SetContainers(containers ...docker.Container) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.containers = containers
+}
+
+// Emit sends an event to all connected event streams. Events are dropped
+// for streams whose buffer is full.
+func (s *Server) Emit(e docker.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.streams {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Close stops the server and removes the socket.
+func (s *Server) Close() {
+	s.srv.Close()
+	os.RemoveAll(s.dir)
+}
+
+// NewContainer builds a running container attached to network.
+func NewContainer(id, name, network string, labels map[string]string, ports ...int) docker.Container {
+	c := docker.Container{
+		ID:     id,
+		Names:  []string{"/" + name},
+		State:  "running",
+		Labels: labels,
+	}
+	for _, p := range ports {
+		c.Ports = append(c.Ports, docker.Port{PrivatePort: p, Type: "tcp"})
+	}
+	c.NetworkSettings.Networks = map[string]docker.Network{network: {}}
+	return c
+}
+
+func (s *Server) handleContainers(w http.ResponseWriter, r *http.Request) {
+	var filters map[string][]string
+	json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+
+	s.mu.Lock()
+	result := []docker.Container{}
+	for _, c := range s.containers {
+		if onNetworks(c, filters["network"]) {
+			result = append(result, c)
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ch := make(chan docker.Event, 16)
+	s.mu.Lock()
+	s.streams[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case e := <-ch:
+			enc.Encode(e)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func onNetworks(c docker.Container, networks []string) bool {
+	if len(networks) == 0 {
+		return true
+	}
+	for _, n := range networks {
+		if _, ok := c.NetworkSettings.Networks[n]; ok {
+			return true
+		}
+	}
+	return false
+}
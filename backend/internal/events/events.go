@@ -0,0 +1,121 @@
+// Package events is an in-process publish/subscribe bus for route, apply and
+// health events. Recent events are kept so that clients of the event stream
+// can resume after a reconnect.
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	RouteCreated   = "route.created"
+	RouteUpdated   = "route.updated"
+	RouteDeleted   = "route.deleted"
+	RouteToggled   = "route.toggled"
+	RoutesChanged  = "routes.changed" // bulk changes: imports, projects, rollbacks, discovery
+	ApplySucceeded = "apply.succeeded"
+	ApplyFailed    = "apply.failed"
+	HealthChanged  = "health.changed"
+	// Reset tells a resuming subscriber that events were missed and it
+	// should refetch its state.
+	Reset = "reset"
+)
+
+// backlogSize is the number of events kept for resuming subscribers.
+const backlogSize = 256
+
+// Event is a single published event.
+type Event struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+var (
+	mu          sync.Mutex
+	backlog     []Event
+	subscribers = make(map[chan Event]struct{})
+	// IDs start at the boot time in microseconds, so they keep increasing
+	// across restarts and stale IDs from an older run are detected.
+	lastID = time.Now().UnixMicro()
+)
+
+// Publish sends an event to all subscribers. data is encoded as JSON.
+// Subscribers that cannot keep up are disconnected and can resume from
+// their last event ID.
+func Publish(eventType string, data interface{}) Event {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		raw = json.RawMessage("null")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	lastID++
+	e := Event{ID: lastID, Type: eventType, Time: time.Now().UTC(), Data: raw}
+	backlog = append(backlog, e)
+	if len(backlog) > backlogSize {
+		backlog = backlog[len(backlog)-backlogSize:]
+	}
+
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			// Drop the subscriber; it resumes from its last ID.
+			delete(subscribers, ch)
+			close(ch)
+		}
+	}
+	return e
+}
+
+// Subscribe returns a channel of new events. If lastEventID is not zero, the
+// events published after it are delivered first; if some of them are no
+// longer available a Reset event is delivered instead. The channel is closed
+// if the subscriber falls too far behind. Call cancel when done.
+func Subscribe(lastEventID int64) (ch <-chan Event, cancel func()) {
+	c := make(chan Event, backlogSize+1)
+
+	mu.Lock()
+	if lastEventID != 0 {
+		for _, e := range replay(lastEventID) {
+			c <- e
+		}
+	}
+	subscribers[c] = struct{}{}
+	mu.Unlock()
+
+	cancel = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := subscribers[c]; ok {
+			delete(subscribers, c)
+			close(c)
+		}
+	}
+	return c, cancel
+}
+
+// replay must be called with mu held.
+func replay(after int64) []Event {
+	if after == lastID {
+		return nil
+	}
+	if after > lastID || len(backlog) == 0 || after < backlog[0].ID-1 {
+		return []Event{{ID: lastID, Type: Reset, Time: time.Now().UTC(), Data: json.RawMessage("null")}}
+	}
+
+	var missed []Event
+	for _, e := range backlog {
+		if e.ID > after {
+			missed = append(missed, e)
+		}
+	}
+	return missed
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestSubscribeReplay(t *testing.T) {
+	first := Publish(RouteCreated, map[string]int{"id": 1})
+	Publish(RouteUpdated, map[string]int{"id": 1})
+	third := Publish(RouteDeleted, map[string]int{"id": 1})
+
+	tests := []struct {
+		name     string
+		after    int64
+		expected []string
+	}{
+		{"resume", first.ID, []string{RouteUpdated, RouteDeleted}},
+		{"up to date", third.ID, nil},
+		{"unknown future ID", third.ID + 100, []string{Reset}},
+		{"stale ID", first.ID - backlogSize - 10, []string{Reset}},
+	}
+
+	for _, tt := range tests {
+		ch, cancel := Subscribe(tt.after)
+		var got []string
+		for len(ch) > 0 {
+			got = append(got, (<-ch).Type)
+		}
+		cancel()
+
+		if len(got) != len(tt.expected) {
+			t.Errorf("%s: Subscribe(%d) replayed %v; want %v", tt.name, tt.after, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("%s: Subscribe(%d) replayed %v; want %v", tt.name, tt.after, got, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestPublishDelivers(t *testing.T) {
+	ch, cancel := Subscribe(0)
+	defer cancel()
+
+	e := Publish(ApplySucceeded, map[string]string{"message": "ok"})
+	select {
+	case got := <-ch:
+		if got.ID != e.ID || got.Type != ApplySucceeded || string(got.Data) != `{"message":"ok"}` {
+			t.Errorf("received %+v; want %+v", got, e)
+		}
+	default:
+		t.Error("event was not delivered")
+	}
+}
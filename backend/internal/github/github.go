@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"devproxy/internal/metrics"
 )
 
 const (
@@ -13,6 +15,10 @@ const (
 	githubAPI  = "https://api.github.com/repos/" + githubRepo + "/releases"
 )
 
+var updateChecks = metrics.NewCounterVec("devproxy_update_checks_total",
+	"GitHub update checks by channel and outcome (update_available, up_to_date or error).",
+	"channel", "outcome")
+
 // Release represents a GitHub release
 type Release struct {
 	TagName     string    `json:"tag_name"`
@@ -36,8 +42,19 @@ type UpdateInfo struct {
 }
 
 // CheckForUpdates queries GitHub for the latest release based on the update channel
-func CheckForUpdates(currentVersion string, channel string) (*UpdateInfo, error) {
-	info := &UpdateInfo{
+func CheckForUpdates(currentVersion string, channel string) (info *UpdateInfo, err error) {
+	defer func() {
+		outcome := "up_to_date"
+		switch {
+		case err != nil:
+			outcome = "error"
+		case info.Available:
+			outcome = "update_available"
+		}
+		updateChecks.Inc(channel, outcome)
+	}()
+
+	info = &UpdateInfo{
 		CurrentVersion: currentVersion,
 		UpdateChannel:  channel,
 		CheckedAt:      time.Now(),
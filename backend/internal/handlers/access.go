@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"devproxy/internal/database"
+	"devproxy/internal/events"
+	"devproxy/internal/models"
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRouteUsers lists the basic auth users of a route. Password hashes are
+// never returned.
+func GetRouteUsers(c *gin.Context) {
+	route, ok := loadRoute(c)
+	if !ok {
+		return
+	}
+	users, err := database.GetRouteUsers(route.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// SetRouteUser creates a basic auth user for a route or changes its
+// password. The password is stored as a bcrypt hash.
+func SetRouteUser(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, ok := loadRoute(c)
+	if !ok {
+		return
+	}
+	username := c.Param("username")
+	if err := services.ValidateBasicAuthUsername(username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := services.HashBasicAuthPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := database.SetRouteUser(route.ID, username, hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	publishRoute(events.RouteUpdated, c.Param("id"))
+
+	if created {
+		c.JSON(http.StatusCreated, gin.H{"message": "User created"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// DeleteRouteUser removes a basic auth user from a route.
+func DeleteRouteUser(c *gin.Context) {
+	route, ok := loadRoute(c)
+	if !ok {
+		return
+	}
+	err := database.DeleteRouteUser(route.ID, c.Param("username"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	publishRoute(events.RouteUpdated, c.Param("id"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+// loadRoute loads the route named by the :id parameter, writing a 404 or
+// 500 response if that fails.
+func loadRoute(c *gin.Context) (*models.Route, bool) {
+	route, err := database.GetRouteByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return route, true
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"devproxy/internal/auth"
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuthStatus tells the UI whether a login is required and whether the
+// caller is logged in.
+func GetAuthStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":       auth.Enabled(),
+		"authenticated": !auth.Enabled() || auth.Authenticated(c),
+	})
+}
+
+// Login checks the admin password and starts a session.
+func Login(c *gin.Context) {
+	if !auth.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authentication is not enabled"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" form:"password"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !auth.CheckPassword(req.Password) {
+		// Slow down password guessing.
+		time.Sleep(time.Second)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	id, err := auth.NewSession()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(auth.SessionCookie, id, int(auth.SessionTTL.Seconds()), "/", "", secureRequest(c), true)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged in"})
+}
+
+// Logout ends the current session.
+func Logout(c *gin.Context) {
+	if id, err := c.Cookie(auth.SessionCookie); err == nil {
+		database.DeleteSession(auth.HashSecret(id))
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(auth.SessionCookie, "", -1, "/", "", secureRequest(c), true)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// GetTokens lists API tokens without their secrets.
+func GetTokens(c *gin.Context) {
+	tokens, err := database.GetAPITokens()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken creates an API token. The token itself is only returned in
+// this response.
+func CreateToken(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token name is required"})
+		return
+	}
+	if !auth.ValidScope(req.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scope must be one of read-only, routes:write, admin"})
+		return
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	t := models.APIToken{
+		Name:   req.Name,
+		Scope:  req.Scope,
+		Prefix: token[:len(auth.TokenPrefix)+8],
+	}
+	if err := database.CreateAPIToken(&t, hash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": token, "details": t})
+}
+
+// DeleteToken revokes an API token.
+func DeleteToken(c *gin.Context) {
+	err := database.DeleteAPIToken(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+}
+
+// LoginPage serves a minimal login form for the UI.
+func LoginPage(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(loginPage))
+}
+
+// secureRequest reports whether the client reached us over HTTPS, directly
+// or through a reverse proxy.
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+const loginPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>DevProxy - Login</title>
+<link rel="icon" href="/favicon.svg">
+<style>
+  body { font-family: system-ui, sans-serif; background: #0f172a; color: #e2e8f0; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+  form { background: #1e293b; padding: 2rem; border-radius: 8px; width: 300px; display: flex; flex-direction: column; gap: 1rem; }
+  h1 { margin: 0; font-size: 1.25rem; }
+  input, button { padding: 0.6rem; border-radius: 4px; border: 1px solid #334155; font-size: 1rem; }
+  input { background: #0f172a; color: inherit; }
+  button { background: #6366f1; color: white; border: none; cursor: pointer; }
+  .error { color: #f87171; min-height: 1.2em; margin: 0; font-size: 0.9rem; }
+</style>
+</head>
+<body>
+<form id="login">
+  <h1>DevProxy</h1>
+  <input type="password" name="password" placeholder="Admin password" autofocus required>
+  <button type="submit">Log in</button>
+  <p class="error" id="error"></p>
+</form>
+<script>
+document.getElementById('login').addEventListener('submit', async (e) => {
+  e.preventDefault()
+  const res = await fetch('/api/auth/login', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ password: e.target.password.value }),
+  })
+  if (res.ok) {
+    window.location.href = '/'
+    return
+  }
+  const data = await res.json().catch(() => ({}))
+  document.getElementById('error').textContent = data.error || 'Login failed'
+})
+</script>
+</body>
+</html>
+`
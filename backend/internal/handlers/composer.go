@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ComposeRequest sends a request to a route through Caddy and directly to
+// its upstream and returns both responses.
+func ComposeRequest(c *gin.Context) {
+	route, err := database.GetRouteByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req models.ComposeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req, err = services.NormalizeComposeRequest(*route, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.ComposeRoute(*route, req))
+}
+
+// GetComposeResult returns one of the latest composer results.
+func GetComposeResult(c *gin.Context) {
+	result, ok := loadComposeResult(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportComposeHAR downloads a composer result as a HAR 1.2 file.
+func ExportComposeHAR(c *gin.Context) {
+	result, ok := loadComposeResult(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=devproxy-compose-%d.har", result.ID))
+	c.JSON(http.StatusOK, services.ComposeHAR(result))
+}
+
+func loadComposeResult(c *gin.Context) (models.ComposeResult, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid result ID"})
+		return models.ComposeResult{}, false
+	}
+	result, ok := services.GetComposeResult(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Result not found; only the latest results are kept"})
+		return models.ComposeResult{}, false
+	}
+	return result, true
+}
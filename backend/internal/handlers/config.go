@@ -1,37 +1,212 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 
 	"devproxy/internal/database"
+	"devproxy/internal/events"
 	"devproxy/internal/models"
 	"devproxy/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ExportConfig exports all routes as JSON.
+// ExportConfig exports all routes and projects as a versioned document, in
+// JSON or, with format=yaml, YAML.
 func ExportConfig(c *gin.Context) {
 	routes, err := database.GetExportRoutes()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	projects, err := database.GetAllProjects()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.Header("Content-Disposition", "attachment; filename=devproxy-config.json")
-	c.JSON(http.StatusOK, routes)
+	writeExport(c, "devproxy-config", services.NewExport(routes, projects))
 }
 
-// ImportConfig imports routes from JSON.
+// writeExport sends an export document as a download in the format asked
+// for by the format query parameter.
+func writeExport(c *gin.Context, filename string, doc models.ExportDocument) {
+	format, err := services.NormalizeExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := services.EncodeExport(doc, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "application/json; charset=utf-8"
+	if format == services.ExportYAML {
+		contentType = "application/yaml; charset=utf-8"
+	}
+	c.Header("Content-Disposition", "attachment; filename="+filename+"."+format)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ImportConfig imports an export document in JSON or YAML; documents of
+// older schema versions, including plain route arrays, are migrated first.
+// The mode query parameter decides
+// what happens to routes that already exist (skip, overwrite, rename or
+// replace) and dry_run=true only reports what the import would do. The
+// import is written in one transaction and is refused as a whole when any
+// route is invalid; conflicting routes are skipped and reported.
 func ImportConfig(c *gin.Context) {
-	var routes []models.ImportRoute
-	if err := c.ShouldBindJSON(&routes); err != nil {
+	mode, err := services.NormalizeImportMode(c.Query("mode"))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	dryRun := c.Query("dry_run") == "true"
 
-	imported, _ := database.ImportRoutes(routes)
-	services.GenerateCaddyfile()
-	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Imported %d routes", imported)})
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	doc, schemaVersion, err := services.DecodeExport(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := database.GetAllRoutes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	report, plan := services.PlanImport(doc.Routes, existing, mode)
+	plan.Projects = services.ImportProjects(doc)
+	report.SchemaVersion = schemaVersion
+	report.DryRun = dryRun
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if report.Invalid > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  fmt.Sprintf("Import failed: %d invalid routes", report.Invalid),
+			"report": report,
+		})
+		return
+	}
+
+	if err := database.ApplyImport(plan.ReplaceAll, plan.Projects, plan.Create, plan.Update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Import failed, nothing was changed: " + err.Error()})
+		return
+	}
+	report.Applied = true
+
+	events.Publish(events.RoutesChanged, gin.H{
+		"action":  "import",
+		"mode":    mode,
+		"count":   report.Created + report.Updated,
+		"created": report.Created,
+		"updated": report.Updated,
+		"deleted": report.Deleted,
+	})
+	c.JSON(http.StatusOK, report)
+}
+
+// PreviewComposeImport proposes routes for the services of a compose file,
+// uploaded as the request body or read from the path query parameter. The
+// proposal is not stored: POST its document to /api/import to accept it.
+func PreviewComposeImport(c *gin.Context) {
+	var data []byte
+	var dir string
+	var err error
+	if path := c.Query("path"); path != "" {
+		var file string
+		if data, file, err = services.ReadComposePath(path); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, services.ErrComposePathDenied) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		dir = filepath.Dir(file)
+	} else if data, err = c.GetRawData(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := services.ParseCompose(data, c.Query("project"), dir)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if preview.Report, err = previewReport(preview.Document); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// PreviewNginxImport translates the server blocks of an nginx config in the
+// request body to routes. Like the compose preview, nothing is stored.
+func PreviewNginxImport(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preview, err := services.ParseNginx(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	respondConfigPreview(c, preview)
+}
+
+// PreviewTraefikImport translates the routers of a Traefik dynamic config in
+// the request body to routes. The format query parameter is yaml or toml;
+// it is detected from the content when omitted.
+func PreviewTraefikImport(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		format = services.DetectTraefikFormat(data)
+	}
+	preview, err := services.ParseTraefik(data, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	respondConfigPreview(c, preview)
+}
+
+// respondConfigPreview adds the dry run report of a translated config.
+func respondConfigPreview(c *gin.Context, preview services.ConfigPreview) {
+	var err error
+	if preview.Report, err = previewReport(preview.Document); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// previewReport is what importing a proposed document in skip mode would do.
+func previewReport(doc models.ExportDocument) (services.ImportReport, error) {
+	existing, err := database.GetAllRoutes()
+	if err != nil {
+		return services.ImportReport{}, err
+	}
+	report, _ := services.PlanImport(doc.Routes, existing, services.ImportSkipExisting)
+	report.SchemaVersion = doc.SchemaVersion
+	report.DryRun = true
+	return report, nil
 }
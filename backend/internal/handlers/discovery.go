@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDiscoveryStatus returns the state of Docker label discovery.
+func GetDiscoveryStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetDiscoveryStatus())
+}
+
+// SyncDiscovery reconciles Docker-managed routes immediately.
+func SyncDiscovery(c *gin.Context) {
+	changed, err := services.ReconcileDocker(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changed": changed,
+		"status":  services.GetDiscoveryStatus(),
+	})
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"devproxy/internal/database"
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorPageData is rendered by errorPageTemplate and returned as JSON to
+// clients that prefer it. The page is public, so it only carries what helps
+// a visitor tell why the route failed.
+type errorPageData struct {
+	Status      int             `json:"status"`
+	Title       string          `json:"title"`
+	Maintenance bool            `json:"maintenance"`
+	Route       *errorPageRoute `json:"route,omitempty"`
+}
+
+// errorPageRoute is the part of a route and its health shown on an error
+// page.
+type errorPageRoute struct {
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	ErrorType string `json:"error_type,omitempty"`
+	Tip       string `json:"tip,omitempty"`
+}
+
+// CaddyOnly rejects requests that do not come from Caddy.
+func CaddyOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.IsCaddyPeer(c.Request.RemoteAddr) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ErrorPage renders the branded page Caddy serves when an upstream fails or
+// a route is in maintenance. Caddy passes ?route=<id> and ?status=<code>;
+// the page is answered with that status. It is served on its own listener
+// that only answers Caddy.
+func ErrorPage(c *gin.Context) {
+	data := errorPageData{Status: services.ErrorPageStatus(c.Query("status"))}
+
+	if route, err := database.GetRouteByID(c.Query("route")); err == nil {
+		data.Route = &errorPageRoute{Name: route.Name, Target: route.Target}
+		data.Maintenance = route.Maintenance && data.Status == http.StatusServiceUnavailable
+		if status, ok := services.GetRouteHealth(route.ID); ok {
+			data.Route.ErrorType = status.ErrorType
+			data.Route.Tip = status.Tip
+		}
+	}
+
+	switch {
+	case data.Maintenance:
+		data.Title = "Under maintenance"
+		c.Header("Retry-After", "120")
+	case data.Route != nil:
+		data.Title = data.Route.Name + " is not reachable"
+	default:
+		data.Title = strconv.Itoa(data.Status) + " " + http.StatusText(data.Status)
+	}
+	c.Header("Cache-Control", "no-store")
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(data.Status, data)
+		return
+	}
+	c.Status(data.Status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	errorPageTemplate.Execute(c.Writer, data)
+}
+
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - DevProxy</title>
+<style>
+  body { font-family: system-ui, sans-serif; background: #0f172a; color: #e2e8f0; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+  main { background: #1e293b; padding: 2rem; border-radius: 8px; max-width: 560px; width: 90%; }
+  h1 { margin: 0 0 0.5rem; font-size: 1.4rem; }
+  .status { color: #94a3b8; margin: 0 0 1.5rem; }
+  dl { display: grid; grid-template-columns: max-content 1fr; gap: 0.5rem 1rem; margin: 0 0 1.5rem; }
+  dt { color: #94a3b8; }
+  dd { margin: 0; font-family: ui-monospace, monospace; word-break: break-all; }
+  .tip { background: #312e81; border-left: 3px solid #6366f1; padding: 0.75rem 1rem; border-radius: 4px; }
+  footer { color: #64748b; font-size: 0.85rem; margin-top: 1.5rem; }
+</style>
+</head>
+<body>
+<main>
+{{if .Maintenance}}
+  <h1>{{.Route.Name}} is under maintenance</h1>
+  <p class="status">503 Service Unavailable. The route is switched to maintenance mode in DevProxy; try again later.</p>
+{{else}}
+  <h1>{{.Title}}</h1>
+  <p class="status">{{.Status}} - Caddy could not get a response from the upstream.</p>
+  {{with .Route}}
+  <dl>
+    <dt>Route</dt><dd>{{.Name}}</dd>
+    <dt>Target</dt><dd>{{.Target}}</dd>
+    {{if .ErrorType}}<dt>Problem</dt><dd>{{.ErrorType}}</dd>{{end}}
+  </dl>
+  {{if .Tip}}<p class="tip">{{.Tip}}</p>{{end}}
+  {{end}}
+{{end}}
+  <footer>Served by DevProxy</footer>
+</main>
+</body>
+</html>
+`))
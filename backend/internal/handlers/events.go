@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"devproxy/internal/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventsHeartbeat keeps idle streams open through proxies and lets clients
+// detect dead connections.
+const eventsHeartbeat = 15 * time.Second
+
+// StreamEvents streams route, apply and health events as Server-Sent Events.
+// Clients resume after a reconnect with the Last-Event-ID header or the
+// last_event_id query parameter; if events were missed in between they
+// receive a reset event and should refetch their state.
+func StreamEvents(c *gin.Context) {
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	var after int64
+	if lastID != "" {
+		id, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+		after = id
+	}
+
+	ch, cancel := events.Subscribe(after)
+	defer cancel()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			w.Flush()
+		case e, ok := <-ch:
+			if !ok {
+				// Too slow; the client reconnects and resumes.
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+			w.Flush()
+		}
+	}
+}
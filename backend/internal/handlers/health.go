@@ -1,14 +1,141 @@
 package handlers
 
 import (
+	"database/sql"
 	"net/http"
+	"strconv"
+	"time"
 
+	"devproxy/internal/database"
 	"devproxy/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxHistoryWindow bounds the time window of history requests.
+const maxHistoryWindow = 366 * 24 * time.Hour
+
 // GetHealthStatus returns all health statuses.
 func GetHealthStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, services.GetHealthStatuses())
 }
+
+// GetHealthHistory summarises the health of all routes over a time window.
+func GetHealthHistory(c *gin.Context) {
+	from, to, ok := historyWindow(c)
+	if !ok {
+		return
+	}
+
+	histories, err := services.GetHealthHistories(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, histories)
+}
+
+// GetRouteHealthHistory returns the health of one route over a time window
+// with its transitions and chart points (?step=5m).
+func GetRouteHealthHistory(c *gin.Context) {
+	route, err := database.GetRouteByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, ok := historyWindow(c)
+	if !ok {
+		return
+	}
+	var step time.Duration
+	if v := c.Query("step"); v != "" {
+		if step, err = services.ParseDuration(v); err != nil || step <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step"})
+			return
+		}
+	}
+
+	history, err := services.GetHealthHistory(*route, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetHealthTransitions lists health transitions over a time window, newest
+// first, optionally for one route (?route=<id>).
+func GetHealthTransitions(c *gin.Context) {
+	from, to, ok := historyWindow(c)
+	if !ok {
+		return
+	}
+
+	var routeID int64
+	if v := c.Query("route"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route"})
+			return
+		}
+		routeID = id
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	transitions, err := database.GetHealthTransitions(routeID, from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, transitions)
+}
+
+// historyWindow reads the time window of a history request: either from and
+// to as RFC 3339 timestamps, or a window ending now (?window=24h, default
+// 24 hours). It writes a 400 response and returns false if they are invalid.
+func historyWindow(c *gin.Context) (from, to time.Time, ok bool) {
+	to = time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to timestamp"})
+			return from, to, false
+		}
+		to = t.UTC()
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from timestamp"})
+			return from, to, false
+		}
+		from = t.UTC()
+	} else {
+		window, err := services.ParseDuration(c.DefaultQuery("window", "24h"))
+		if err != nil || window <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+			return from, to, false
+		}
+		from = to.Add(-window)
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return from, to, false
+	}
+	if to.Sub(from) > maxHistoryWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Time window must not exceed 366 days"})
+		return from, to, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"devproxy/internal/database"
+	"devproxy/internal/events"
+	"devproxy/internal/models"
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultProjectColor is used for projects created without a color.
+const defaultProjectColor = "#6b7280"
+
+var projectColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// GetProjects returns all projects with their route counts.
+func GetProjects(c *gin.Context) {
+	projects, err := database.GetAllProjects()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, projects)
+}
+
+// GetProject returns a single project by ID.
+func GetProject(c *gin.Context) {
+	project, ok := loadProject(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, project)
+}
+
+// CreateProject creates a new project.
+func CreateProject(c *gin.Context) {
+	var p models.Project
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !normalizeProject(c, &p) {
+		return
+	}
+
+	err := database.CreateProject(&p)
+	if database.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A project named %q already exists", p.Name)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, p)
+}
+
+// UpdateProject updates the name, color and description of a project.
+func UpdateProject(c *gin.Context) {
+	var p models.Project
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !normalizeProject(c, &p) {
+		return
+	}
+
+	err := database.UpdateProject(c.Param("id"), &p)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("A project named %q already exists", p.Name)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project updated"})
+}
+
+// DeleteProject deletes a project. Its routes are kept without a project;
+// use DeleteProjectRoutes to remove them.
+func DeleteProject(c *gin.Context) {
+	if _, ok := loadProject(c); !ok {
+		return
+	}
+
+	if err := database.DeleteProject(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project deleted"})
+}
+
+// AssignProjectRoutes moves the routes listed in "route_ids" into the
+// project, or out of any project for ID 0. Docker-managed routes may be
+// assigned too, as the project does not affect the proxy config.
+func AssignProjectRoutes(c *gin.Context) {
+	project := &models.Project{Name: "no project"}
+	if c.Param("id") != "0" {
+		var ok bool
+		if project, ok = loadProject(c); !ok {
+			return
+		}
+	}
+
+	var req struct {
+		RouteIDs []int64 `json:"route_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	moved, err := database.AssignRoutesToProject(project.ID, req.RouteIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	events.Publish(events.RoutesChanged, gin.H{"action": "assign_project", "project_id": project.ID})
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Moved %d routes to %s", moved, project.Name)})
+}
+
+// EnableProject enables every route of a project. With "exclusive=true",
+// the routes of all other projects are disabled, switching from one project
+// to another in a single call. Docker-managed routes follow their containers
+// and are left alone. Like toggling a single route, the change takes effect
+// on the next apply.
+func EnableProject(c *gin.Context) {
+	setProjectEnabled(c, true)
+}
+
+// DisableProject disables every route of a project.
+func DisableProject(c *gin.Context) {
+	setProjectEnabled(c, false)
+}
+
+func setProjectEnabled(c *gin.Context, enabled bool) {
+	project, ok := loadProject(c)
+	if !ok {
+		return
+	}
+
+	routes, err := database.GetRoutesByProject(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ids, skipped := manualRouteIDs(routes, false)
+
+	disabled := 0
+	if enabled && c.Query("exclusive") == "true" {
+		all, err := database.GetAllRoutes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		var others []models.Route
+		for _, r := range all {
+			if r.ProjectID != 0 && r.ProjectID != project.ID && r.Enabled {
+				others = append(others, r)
+			}
+		}
+		otherIDs, otherSkipped := manualRouteIDs(others, false)
+		if err := database.SetRoutesEnabled(otherIDs, false); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		disabled = len(otherIDs)
+		skipped += otherSkipped
+	}
+
+	if err := database.SetRoutesEnabled(ids, enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	state := "Disabled"
+	if enabled {
+		state = "Enabled"
+	}
+	events.Publish(events.RoutesChanged, gin.H{"action": strings.ToLower(state), "project_id": project.ID})
+	message := fmt.Sprintf("%s %d routes in %s", state, len(ids), project.Name)
+	if disabled > 0 {
+		message += fmt.Sprintf(", disabled %d routes in other projects", disabled)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "skipped": skipped})
+}
+
+// ExportProject exports a project and its routes in the same format as
+// ExportConfig.
+func ExportProject(c *gin.Context) {
+	project, ok := loadProject(c)
+	if !ok {
+		return
+	}
+
+	routes, err := database.GetProjectExportRoutes(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeExport(c, "devproxy-"+projectSlug(project.Name), services.NewExport(routes, []models.Project{*project}))
+}
+
+// DeleteProjectRoutes deletes every route of a project. Enabled
+// Docker-managed routes are skipped, as they would be recreated.
+func DeleteProjectRoutes(c *gin.Context) {
+	project, ok := loadProject(c)
+	if !ok {
+		return
+	}
+
+	routes, err := database.GetRoutesByProject(project.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ids, skipped := manualRouteIDs(routes, true)
+
+	if err := database.DeleteRoutes(ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	events.Publish(events.RoutesChanged, gin.H{"action": "deleted", "project_id": project.ID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Deleted %d routes from %s", len(ids), project.Name),
+		"skipped": skipped,
+	})
+}
+
+// manualRouteIDs returns the IDs of routes that may be changed through the
+// API, following the same rules as requireManualRoute, and how many were
+// left out.
+func manualRouteIDs(routes []models.Route, allowDisabled bool) (ids []int64, skipped int) {
+	for _, r := range routes {
+		if r.Source == models.RouteSourceManual || (allowDisabled && !r.Enabled) {
+			ids = append(ids, r.ID)
+		} else {
+			skipped++
+		}
+	}
+	return ids, skipped
+}
+
+func loadProject(c *gin.Context) (*models.Project, bool) {
+	project, err := database.GetProjectByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return project, true
+}
+
+// normalizeProject cleans user-supplied project fields in place. It writes a
+// 400 response and returns false if a field is invalid.
+func normalizeProject(c *gin.Context, p *models.Project) bool {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+
+	if p.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project name is required"})
+		return false
+	}
+	if p.Color == "" {
+		p.Color = defaultProjectColor
+	}
+	if !projectColorRe.MatchString(p.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project color must be a hex color like #3b82f6"})
+		return false
+	}
+	return true
+}
+
+// projectSlug turns a project name into a safe file name component.
+func projectSlug(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case sb.Len() > 0 && !strings.HasSuffix(sb.String(), "-"):
+			sb.WriteByte('-')
+		}
+	}
+	slug := strings.Trim(sb.String(), "-")
+	if slug == "" {
+		return "project"
+	}
+	return slug
+}
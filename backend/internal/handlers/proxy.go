@@ -1,26 +1,94 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
+	"devproxy/internal/auth"
 	"devproxy/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// ReloadCaddy regenerates Caddyfile and reloads Caddy.
+// ReloadCaddy pushes the current routes to Caddy. Routes that fail
+// validation are reported with 422, a config rejected by Caddy with 502;
+// both responses list the offending routes under "errors".
 func ReloadCaddy(c *gin.Context) {
-	message, warning := services.ReloadCaddy()
+	message, warning, err := services.ReloadCaddy(requestAuthor(c))
+	if writeApplyError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, applyResponse(message, warning))
+}
+
+// writeApplyError writes the response for a config that was not applied
+// and reports whether err was such an error.
+func writeApplyError(c *gin.Context, err error) bool {
+	var applyErr *services.ApplyError
+	if !errors.As(err, &applyErr) {
+		return false
+	}
 
+	status := http.StatusUnprocessableEntity
+	if applyErr.Rejected {
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, applyErr)
+	return true
+}
+
+func applyResponse(message, warning string) gin.H {
 	response := gin.H{"message": message}
 	if warning != "" {
 		response["warning"] = warning
 	}
+	return response
+}
 
-	c.JSON(http.StatusOK, response)
+// requestAuthor names the caller of a request for the revision history:
+// the authenticated user or token if authentication is enabled, otherwise
+// the X-DevProxy-Author header or the client IP.
+func requestAuthor(c *gin.Context) string {
+	if p, ok := auth.PrincipalFrom(c); ok {
+		return p.Name
+	}
+	if author := strings.TrimSpace(c.GetHeader("X-DevProxy-Author")); author != "" {
+		return author
+	}
+	return c.ClientIP()
 }
 
 // GetAppliedState returns the last applied route configuration.
 func GetAppliedState(c *gin.Context) {
 	c.JSON(http.StatusOK, services.GetAppliedState())
 }
+
+// ExportCaddyfile returns the enabled routes as a Caddyfile.
+func ExportCaddyfile(c *gin.Context) {
+	content, err := services.ExportCaddyfile()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=Caddyfile")
+	c.String(http.StatusOK, content)
+}
+
+// ExportCaddyJSON returns the enabled routes as native Caddy JSON config.
+func ExportCaddyJSON(c *gin.Context) {
+	data, err := services.ExportCaddyJSON()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=caddy.json")
+	c.Data(http.StatusOK, "application/json", data)
+}
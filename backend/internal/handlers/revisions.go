@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRevisions lists applied config revisions, newest first. The number of
+// revisions is limited by the "limit" query parameter (default 50).
+func GetRevisions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+
+	revisions, err := database.GetRevisions(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevision returns a revision with its routes and generated config.
+func GetRevision(c *gin.Context) {
+	rev, ok := loadRevision(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rev)
+}
+
+// DiffRevisions compares the revision :id with the revision given by the
+// "from" query parameter, or with the revision before it.
+func DiffRevisions(c *gin.Context) {
+	to, ok := loadRevision(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var from *models.Revision
+	if id := c.Query("from"); id != "" {
+		if from, ok = loadRevision(c, id); !ok {
+			return
+		}
+	} else {
+		prev, err := database.GetPreviousRevision(to.ID)
+		if err != nil && err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// The first revision is compared with an empty route table.
+		if prev == nil {
+			prev = &models.Revision{Routes: []models.Route{}}
+		}
+		from = prev
+	}
+
+	c.JSON(http.StatusOK, services.DiffRevisions(from, to))
+}
+
+// RollbackRevision restores the routes of a revision and applies them.
+func RollbackRevision(c *gin.Context) {
+	message, warning, err := services.RollbackToRevision(c.Param("id"), requestAuthor(c))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	if writeApplyError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, applyResponse(message, warning))
+}
+
+func loadRevision(c *gin.Context, id string) (*models.Revision, bool) {
+	rev, err := database.GetRevision(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return rev, true
+}
@@ -3,18 +3,36 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"devproxy/internal/database"
+	"devproxy/internal/events"
 	"devproxy/internal/models"
 	"devproxy/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetRoutes returns all routes.
+// GetRoutes returns all routes. The "project" query parameter limits the
+// result to one project, or to routes without a project when it is "none".
 func GetRoutes(c *gin.Context) {
-	routes, err := database.GetAllRoutes()
+	var routes []models.Route
+	var err error
+	switch project := c.Query("project"); project {
+	case "":
+		routes, err = database.GetAllRoutes()
+	case "none":
+		routes, err = database.GetRoutesByProject(0)
+	default:
+		id, parseErr := strconv.ParseInt(project, 10, 64)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project must be a project ID or \"none\""})
+			return
+		}
+		routes, err = database.GetRoutesByProject(id)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -44,13 +62,19 @@ func CreateRoute(c *gin.Context) {
 		return
 	}
 
+	if !validateRoute(c, &r) {
+		return
+	}
+
+	r.Source = models.RouteSourceManual
+	r.SourceRef = ""
 	if err := database.CreateRoute(&r); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondSaveError(c, err)
 		return
 	}
+	events.Publish(events.RouteCreated, r)
 
-	services.GenerateCaddyfile()
-	c.JSON(http.StatusCreated, r)
+	c.JSON(http.StatusCreated, routeResponse{Route: r, Warnings: services.RouteWarnings(r)})
 }
 
 // UpdateRoute updates an existing route.
@@ -61,33 +85,172 @@ func UpdateRoute(c *gin.Context) {
 		return
 	}
 
+	if _, ok := requireManualRoute(c, false); !ok {
+		return
+	}
+
+	r.ID, _ = strconv.ParseInt(c.Param("id"), 10, 64)
+	if !validateRoute(c, &r) {
+		return
+	}
+
 	if err := database.UpdateRoute(c.Param("id"), &r); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondSaveError(c, err)
 		return
 	}
+	publishRoute(events.RouteUpdated, c.Param("id"))
 
-	services.GenerateCaddyfile()
-	c.JSON(http.StatusOK, gin.H{"message": "Route updated"})
+	c.JSON(http.StatusOK, gin.H{"message": "Route updated", "warnings": services.RouteWarnings(r)})
 }
 
 // DeleteRoute deletes a route.
 func DeleteRoute(c *gin.Context) {
+	route, ok := requireManualRoute(c, true)
+	if !ok {
+		return
+	}
+
 	if err := database.DeleteRoute(c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	events.Publish(events.RouteDeleted, route)
 
-	services.GenerateCaddyfile()
 	c.JSON(http.StatusOK, gin.H{"message": "Route deleted"})
 }
 
 // ToggleRoute toggles the enabled state of a route.
 func ToggleRoute(c *gin.Context) {
+	if _, ok := requireManualRoute(c, false); !ok {
+		return
+	}
+
 	if err := database.ToggleRoute(c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	publishRoute(events.RouteToggled, c.Param("id"))
 
-	services.GenerateCaddyfile()
 	c.JSON(http.StatusOK, gin.H{"message": "Route toggled"})
 }
+
+// SetRouteMaintenance switches a route in or out of maintenance mode. It
+// also works for routes managed by Docker discovery.
+func SetRouteMaintenance(c *gin.Context) {
+	var req struct {
+		Maintenance bool `json:"maintenance"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	route, ok := loadRoute(c)
+	if !ok {
+		return
+	}
+	if err := database.SetRouteMaintenance(route.ID, req.Maintenance); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	publishRoute(events.RouteUpdated, c.Param("id"))
+
+	if req.Maintenance {
+		c.JSON(http.StatusOK, gin.H{"message": "Maintenance mode on"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance mode off"})
+}
+
+// publishRoute publishes an event carrying the stored state of a route.
+func publishRoute(eventType, id string) {
+	if route, err := database.GetRouteByID(id); err == nil {
+		events.Publish(eventType, route)
+	}
+}
+
+// requireManualRoute loads the route named by the :id parameter and rejects
+// changes to routes owned by a reconciler such as Docker discovery. With
+// allowDisabled, disabled managed routes (e.g. for removed containers) pass
+// so they can be cleaned up.
+func requireManualRoute(c *gin.Context, allowDisabled bool) (*models.Route, bool) {
+	route, err := database.GetRouteByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if route.Source == models.RouteSourceManual || (allowDisabled && !route.Enabled) {
+		return route, true
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":  "Route is managed by " + route.Source + "; change the labels of container " + route.SourceRef + " instead",
+		"source": route.Source,
+	})
+	return nil, false
+}
+
+// routeResponse is a saved route with the warnings found while validating
+// it, such as a domain on an HSTS-preloaded TLD.
+type routeResponse struct {
+	models.Route
+	Warnings []services.FieldError `json:"warnings,omitempty"`
+}
+
+// validateRoute normalizes a submitted route in place and checks it
+// against the stored routes. Invalid fields and collisions are answered
+// with 422 and one error per field; it returns false in that case.
+func validateRoute(c *gin.Context, r *models.Route) bool {
+	normalized, errs := services.NormalizeRoute(*r)
+	*r = normalized
+
+	if r.ProjectID != 0 {
+		exists, err := database.ProjectExists(r.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if !exists {
+			errs = append(errs, services.FieldError{Field: "project_id", Message: "project not found"})
+		}
+	}
+
+	if len(errs) == 0 {
+		all, err := database.GetAllRoutes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		errs = services.RouteConflicts(*r, all)
+	}
+
+	if len(errs) > 0 {
+		respondValidation(c, errs)
+		return false
+	}
+	return true
+}
+
+// respondValidation writes a 422 response listing the invalid fields.
+func respondValidation(c *gin.Context, errs []services.FieldError) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":  "Validation failed: " + errs[0].Message,
+		"errors": errs,
+	})
+}
+
+// respondSaveError answers a failed insert or update, reporting a
+// domain and path that are already taken as a validation error rather
+// than a database error.
+func respondSaveError(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrDuplicateRoute) {
+		respondValidation(c, []services.FieldError{{Field: "path", Message: err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
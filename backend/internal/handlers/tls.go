@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRootCA serves the root certificate of Caddy's local CA as a PEM file,
+// ready to be installed into a system or browser trust store.
+func GetRootCA(c *gin.Context) {
+	ca, err := services.GetRootCA()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Root CA not available",
+			"details": err.Error(),
+			"tip":     "Enable TLS on at least one route and apply the configuration so Caddy creates its local CA.",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=devproxy-root-ca.crt")
+	c.Data(http.StatusOK, "application/x-pem-file", []byte(ca.PEM))
+}
+
+// GetRootCAInfo returns metadata about Caddy's local CA, including the
+// fingerprint used by the agent to check whether the CA is trusted.
+func GetRootCAInfo(c *gin.Context) {
+	ca, err := services.GetRootCA()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Root CA not available",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ca)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTraffic lists captured requests, newest first. Filters: ?route=<id>
+// (0 for requests that matched no route), ?status=2xx and ?path=<substring>;
+// ?limit defaults to 100.
+func GetTraffic(c *gin.Context) {
+	filter, ok := trafficFilter(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  services.TrafficEnabled(),
+		"requests": services.ListTraffic(filter, limit),
+	})
+}
+
+// GetTrafficEntry returns a captured request with its headers.
+func GetTrafficEntry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+	entry, ok := services.GetTrafficEntry(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// ClearTraffic drops captured requests, of one route with ?route=<id>.
+func ClearTraffic(c *gin.Context) {
+	filter, ok := trafficFilter(c)
+	if !ok {
+		return
+	}
+	services.ClearTraffic(filter.RouteID)
+	c.JSON(http.StatusOK, gin.H{"message": "Traffic cleared"})
+}
+
+// StreamTraffic streams newly captured requests as Server-Sent Events,
+// with the same filters as GetTraffic. Requests are sent without headers;
+// fetch them from /api/traffic/:id.
+func StreamTraffic(c *gin.Context) {
+	filter, ok := trafficFilter(c)
+	if !ok {
+		return
+	}
+
+	ch, cancel := services.SubscribeTraffic()
+	defer cancel()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": ping\n\n")
+			w.Flush()
+		case e := <-ch:
+			if !filter.Match(e) {
+				continue
+			}
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "id: %d\nevent: request\ndata: %s\n\n", e.ID, data)
+			w.Flush()
+		}
+	}
+}
+
+// trafficFilter parses the traffic query filters, writing a 400 response
+// if one is invalid.
+func trafficFilter(c *gin.Context) (services.TrafficFilter, bool) {
+	filter := services.TrafficFilter{Path: c.Query("path")}
+
+	if route := c.Query("route"); route != "" {
+		id, err := strconv.ParseInt(route, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid route ID"})
+			return filter, false
+		}
+		filter.RouteID = &id
+	}
+
+	if status := strings.ToLower(c.Query("status")); status != "" {
+		class, err := strconv.Atoi(strings.TrimSuffix(status, "xx"))
+		if err != nil || class < 1 || class > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status class, use 1xx to 5xx"})
+			return filter, false
+		}
+		filter.StatusClass = class
+	}
+	return filter, true
+}
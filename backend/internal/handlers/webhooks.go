@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+	"devproxy/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookRequest is the body of webhook create and update requests. An
+// empty secret keeps the current one on update; RemoveSecret clears it.
+type webhookRequest struct {
+	models.Webhook
+	RemoveSecret bool `json:"remove_secret"`
+}
+
+// GetWebhooks returns all webhooks without their secrets.
+func GetWebhooks(c *gin.Context) {
+	hooks, err := database.GetAllWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks, "event_types": services.WebhookEventTypes})
+}
+
+// GetWebhook returns a single webhook without its secret.
+func GetWebhook(c *gin.Context) {
+	hook, ok := loadWebhook(c)
+	if !ok {
+		return
+	}
+	hook.Secret = ""
+	c.JSON(http.StatusOK, hook)
+}
+
+// CreateWebhook creates a new webhook.
+func CreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	req.Enabled = true
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook, err := services.NormalizeWebhook(req.Webhook)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.CreateWebhook(&hook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook.HasSecret = hook.Secret != ""
+	hook.Secret = ""
+	c.JSON(http.StatusCreated, hook)
+}
+
+// UpdateWebhook replaces the settings of a webhook.
+func UpdateWebhook(c *gin.Context) {
+	existing, ok := loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook, err := services.NormalizeWebhook(req.Webhook)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case req.RemoveSecret:
+		hook.Secret = ""
+	case hook.Secret == "":
+		hook.Secret = existing.Secret
+	}
+
+	if err := database.UpdateWebhook(c.Param("id"), &hook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated"})
+}
+
+// DeleteWebhook deletes a webhook and its delivery log.
+func DeleteWebhook(c *gin.Context) {
+	err := database.DeleteWebhook(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// TestWebhook sends a test notification and returns the delivery.
+func TestWebhook(c *gin.Context) {
+	hook, ok := loadWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, services.TestWebhook(*hook))
+}
+
+// GetWebhookDeliveries returns the latest delivery attempts of a webhook
+// (?limit=50).
+func GetWebhookDeliveries(c *gin.Context) {
+	if _, ok := loadWebhook(c); !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	deliveries, err := database.GetWebhookDeliveries(c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// loadWebhook fetches the webhook named by the :id parameter, writing a
+// 404 or 500 response if it cannot.
+func loadWebhook(c *gin.Context) (*models.Webhook, bool) {
+	hook, err := database.GetWebhookByID(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return hook, true
+}
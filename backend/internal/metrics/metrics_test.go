@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	registry = nil
+	defer func() { registry = nil }()
+
+	requests := NewCounterVec("test_requests_total", "Requests.", "code")
+	requests.Inc("200")
+	requests.Add(2, "500")
+	requests.Inc("200")
+
+	latency := NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, `a"b`)
+	latency.Observe(0.5, `a"b`)
+	latency.Observe(3, `a"b`)
+
+	NewGaugeFunc("test_up", "Up.", nil, func() []Sample {
+		return []Sample{{Value: 1}}
+	})
+
+	var out strings.Builder
+	WriteTo(&out)
+
+	expected := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{code="200"} 2
+test_requests_total{code="500"} 2
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="a\"b",le="0.1"} 1
+test_latency_seconds_bucket{route="a\"b",le="1"} 2
+test_latency_seconds_bucket{route="a\"b",le="+Inf"} 3
+test_latency_seconds_sum{route="a\"b"} 3.55
+test_latency_seconds_count{route="a\"b"} 3
+# HELP test_up Up.
+# TYPE test_up gauge
+test_up 1
+`
+	if out.String() != expected {
+		t.Errorf("WriteTo() =\n%s\nwant\n%s", out.String(), expected)
+	}
+
+	requests.Delete("500")
+	out.Reset()
+	WriteTo(&out)
+	if strings.Contains(out.String(), `code="500"`) {
+		t.Errorf("deleted series still written:\n%s", out.String())
+	}
+}
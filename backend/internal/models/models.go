@@ -1,27 +1,195 @@
 // Package models defines the data structures used throughout DevProxy.
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
-// Route represents a proxy route configuration.
+// TLS modes for a route.
+const (
+	TLSModeOff      = ""
+	TLSModeInternal = "internal"
+)
+
+// Route kinds. A route either proxies to an upstream, redirects, answers
+// with a fixed response or serves files from a directory.
+const (
+	RouteKindProxy      = "proxy"
+	RouteKindRedirect   = "redirect"
+	RouteKindStatic     = "static"
+	RouteKindFileServer = "file_server"
+)
+
+// Route sources. Routes from a source other than RouteSourceManual are owned
+// by a reconciler and cannot be edited through the API.
+const (
+	RouteSourceManual = ""
+	RouteSourceDocker = "docker"
+)
+
+// Route represents a route configuration.
+//
+// Several routes may share a domain as long as their paths differ. Path is
+// either a prefix ("/api") or a glob containing "*" ("/static/*.css"); an
+// empty path matches everything. Routes on the same domain are evaluated by
+// descending Priority, then by path specificity.
+//
+// Aliases are additional hostnames served by the same site. Domain and
+// aliases may be wildcards covering one label, such as "*.shop.test". Aliases
+// apply to every route sharing the domain.
+//
+// TLSMode selects how the domain is served: empty for plain HTTP, or
+// TLSModeInternal for HTTPS with a certificate from Caddy's local CA. A
+// domain is served over HTTPS if any of its routes enables TLS.
+//
+// Source records who manages the route. Docker-discovered routes carry the
+// container name in SourceRef and are kept in sync with its labels.
+//
+// ProjectID groups the route into a Project; 0 means no project.
+//
+// HealthCheck configures how the upstream is probed; its zero value keeps
+// the default check.
+//
+// Headers changes the request headers sent upstream and the response
+// headers sent back to the client.
+//
+// Kind selects what the route does; Target is read accordingly: the
+// upstream of a proxy route, the destination URL of a redirect or the
+// directory of a file server. Static routes have no target. Redirect,
+// Static and FileServer hold the options of their kind.
+//
+// Maintenance serves a 503 maintenance page instead of the route while
+// keeping it enabled.
+//
+// AllowCIDRs restricts the route to clients from these networks. BasicAuth
+// holds the basic auth accounts; it is only loaded to generate the Caddy
+// config and never serialized.
 type Route struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Domain    string    `json:"domain"`
-	Target    string    `json:"target"`
-	Enabled   bool      `json:"enabled"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Domain      string            `json:"domain"`
+	Aliases     []string          `json:"aliases"`
+	Path        string            `json:"path"`
+	Priority    int               `json:"priority"`
+	StripPrefix bool              `json:"strip_prefix"`
+	Kind        string            `json:"kind"`
+	Target      string            `json:"target"`
+	Redirect    RedirectOptions   `json:"redirect"`
+	Static      StaticResponse    `json:"static"`
+	FileServer  FileServerOptions `json:"file_server"`
+	TLSMode     string            `json:"tls_mode"`
+	Enabled     bool              `json:"enabled"`
+	Maintenance bool              `json:"maintenance"`
+	Source      string            `json:"source"`
+	SourceRef   string            `json:"source_ref,omitempty"`
+	ProjectID   int64             `json:"project_id"`
+	HealthCheck HealthCheck       `json:"health_check"`
+	Headers     HeaderRules       `json:"headers"`
+	AllowCIDRs  []string          `json:"allow_cidrs"`
+	BasicAuth   []BasicAuthUser   `json:"-"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// RedirectOptions configure a redirect route. Status is 301, 302, 307 or
+// 308; with PreservePath the request URI is appended to the target.
+type RedirectOptions struct {
+	Status       int  `json:"status,omitempty"`
+	PreservePath bool `json:"preserve_path,omitempty"`
+}
+
+// StaticResponse is the fixed response of a static route.
+type StaticResponse struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// FileServerOptions configure a file server route. Browse lists directories
+// without an index file.
+type FileServerOptions struct {
+	Browse bool `json:"browse,omitempty"`
+}
+
+// HealthCheck configures the health probe of a route. Empty fields use the
+// defaults: GET / every 30 seconds with a 5 second timeout, where any status
+// below 500 is healthy and a single result flips the state.
+//
+// ExpectedStatus is a comma-separated list of codes and ranges, such as
+// "200-299,401". BodyContains, if set, must appear in the response body.
+// FailureThreshold and SuccessThreshold are the number of consecutive
+// results needed before the route is reported unhealthy or healthy again.
+type HealthCheck struct {
+	Path             string `json:"path,omitempty"`
+	Method           string `json:"method,omitempty"`
+	ExpectedStatus   string `json:"expected_status,omitempty"`
+	BodyContains     string `json:"body_contains,omitempty"`
+	TimeoutSeconds   int    `json:"timeout_seconds,omitempty"`
+	IntervalSeconds  int    `json:"interval_seconds,omitempty"`
+	FailureThreshold int    `json:"failure_threshold,omitempty"`
+	SuccessThreshold int    `json:"success_threshold,omitempty"`
+}
+
+// BasicAuthUser is a basic auth account of a route. PasswordHash is a
+// bcrypt hash; plain passwords are never stored. CreatedAt is not part of
+// exports.
+type BasicAuthUser struct {
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+// HeaderRules are the header changes of a route. Request rules apply to the
+// request forwarded upstream, response rules to the response sent back.
+type HeaderRules struct {
+	Request  HeaderOps `json:"request"`
+	Response HeaderOps `json:"response"`
+}
+
+// HeaderOps sets, adds and deletes headers. Set replaces any existing
+// values, Add appends a value. Values may use Caddy placeholders such as
+// {http.request.host}.
+type HeaderOps struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+	Delete []string          `json:"delete,omitempty"`
+}
+
+// Project groups related routes, typically those of one Compose project, so
+// they can be filtered and switched on or off together.
+type Project struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Color        string    `json:"color"`
+	Description  string    `json:"description"`
+	RouteCount   int       `json:"route_count"`
+	EnabledCount int       `json:"enabled_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 // AppliedRoute represents a route that has been applied to Caddy.
-// Used to track configuration changes.
+// Used to track configuration changes, so it holds every field that ends up
+// in the Caddy config.
 type AppliedRoute struct {
-	ID      int64  `json:"id"`
-	Name    string `json:"name"`
-	Domain  string `json:"domain"`
-	Target  string `json:"target"`
-	Enabled bool   `json:"enabled"`
+	ID          int64             `json:"id"`
+	Name        string            `json:"name"`
+	Domain      string            `json:"domain"`
+	Aliases     []string          `json:"aliases"`
+	Path        string            `json:"path"`
+	Priority    int               `json:"priority"`
+	StripPrefix bool              `json:"strip_prefix"`
+	Kind        string            `json:"kind"`
+	Target      string            `json:"target"`
+	Redirect    RedirectOptions   `json:"redirect"`
+	Static      StaticResponse    `json:"static"`
+	FileServer  FileServerOptions `json:"file_server"`
+	TLSMode     string            `json:"tls_mode"`
+	Enabled     bool              `json:"enabled"`
+	Maintenance bool              `json:"maintenance"`
+	Headers     HeaderRules       `json:"headers"`
+	AllowCIDRs  []string          `json:"allow_cidrs"`
 }
 
 // HealthStatus represents the health check result for a route.
@@ -38,12 +206,296 @@ type HealthStatus struct {
 	DNSResolved  bool   `json:"dns_resolved"`
 	ResolvedIP   string `json:"resolved_ip,omitempty"`
 	Tip          string `json:"tip,omitempty"`
+	// State combines Healthy with the latest probe; see HealthStateHealthy
+	// and the other states. Error and ErrorType describe the latest probe,
+	// so a degraded route has them while it is still reported healthy.
+	State string `json:"state"`
+	// Consecutive failed and successful probes. Healthy only flips once
+	// one of them reaches the route's threshold.
+	ConsecutiveFailures  int `json:"consecutive_failures"`
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+}
+
+// Health states. A degraded route failed its latest probes but has not
+// reached the failure threshold yet; a recovering route passed its latest
+// probes but has not reached the success threshold.
+const (
+	HealthStateHealthy    = "healthy"
+	HealthStateDegraded   = "degraded"
+	HealthStateRecovering = "recovering"
+	HealthStateUnhealthy  = "unhealthy"
+)
+
+// HealthResult is a stored health probe. Passed is the outcome of the probe
+// itself, Healthy the reported state after thresholds.
+type HealthResult struct {
+	RouteID      int64     `json:"route_id"`
+	CheckedAt    time.Time `json:"checked_at"`
+	Passed       bool      `json:"passed"`
+	Healthy      bool      `json:"healthy"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	ResponseTime int64     `json:"response_time_ms"`
+	ErrorType    string    `json:"error_type,omitempty"`
+}
+
+// HealthRollup summarises the health results of a route over a fixed
+// interval once the raw results expire. Latency holds response counts per
+// latency bucket.
+type HealthRollup struct {
+	RouteID       int64     `json:"route_id"`
+	BucketStart   time.Time `json:"bucket_start"`
+	BucketSeconds int       `json:"bucket_seconds"`
+	Checks        int       `json:"checks"`
+	Passed        int       `json:"passed"`
+	Latency       []int64   `json:"latency"`
+}
+
+// HealthTransition records a route turning healthy or unhealthy.
+type HealthTransition struct {
+	ID        int64     `json:"id"`
+	RouteID   int64     `json:"route_id"`
+	Domain    string    `json:"domain"`
+	Healthy   bool      `json:"healthy"`
+	ErrorType string    `json:"error_type,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// HealthHistory summarises a route's health over a time window. Uptime is
+// the percentage of passed probes; P50 and P95 are response times of probes
+// that got an HTTP response. Points and Transitions are only included for a
+// single route.
+type HealthHistory struct {
+	RouteID         int64              `json:"route_id"`
+	Name            string             `json:"name"`
+	Domain          string             `json:"domain"`
+	From            time.Time          `json:"from"`
+	To              time.Time          `json:"to"`
+	Checks          int                `json:"checks"`
+	UptimePercent   float64            `json:"uptime_percent"`
+	P50             int64              `json:"p50_ms"`
+	P95             int64              `json:"p95_ms"`
+	TransitionCount int                `json:"transition_count"`
+	Transitions     []HealthTransition `json:"transitions,omitempty"`
+	Points          []HealthPoint      `json:"points,omitempty"`
 }
 
-// ImportRoute is used for importing routes from JSON.
+// HealthPoint is one step of a health chart. Points without checks have no
+// data.
+type HealthPoint struct {
+	Time          time.Time `json:"time"`
+	Checks        int       `json:"checks"`
+	UptimePercent float64   `json:"uptime_percent"`
+	P50           int64     `json:"p50_ms"`
+	P95           int64     `json:"p95_ms"`
+}
+
+// ExportSchemaVersion is the version of the export document written by this
+// backend. Imports migrate older documents to it.
+const ExportSchemaVersion = 1
+
+// ExportDocument is a DevProxy configuration export. Settings is reserved for
+// backend settings; imports ignore keys they do not know.
+type ExportDocument struct {
+	SchemaVersion  int                    `json:"schema_version"`
+	BackendVersion string                 `json:"backend_version"`
+	ExportedAt     time.Time              `json:"exported_at"`
+	Projects       []ExportProject        `json:"projects"`
+	Routes         []ImportRoute          `json:"routes"`
+	Settings       map[string]interface{} `json:"settings,omitempty"`
+}
+
+// ExportProject is a project in an export. Routes refer to it by name.
+type ExportProject struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ImportRoute is a route in an export document. Project is the name of the
+// route's project, if any.
 type ImportRoute struct {
-	Name    string `json:"name"`
-	Domain  string `json:"domain"`
-	Target  string `json:"target"`
-	Enabled bool   `json:"enabled"`
+	Name        string            `json:"name"`
+	Domain      string            `json:"domain"`
+	Aliases     []string          `json:"aliases,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	Priority    int               `json:"priority,omitempty"`
+	StripPrefix bool              `json:"strip_prefix,omitempty"`
+	Kind        string            `json:"kind,omitempty"`
+	Target      string            `json:"target,omitempty"`
+	Redirect    RedirectOptions   `json:"redirect"`
+	Static      StaticResponse    `json:"static"`
+	FileServer  FileServerOptions `json:"file_server"`
+	TLSMode     string            `json:"tls_mode,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Maintenance bool              `json:"maintenance,omitempty"`
+	Project     string            `json:"project,omitempty"`
+	HealthCheck HealthCheck       `json:"health_check"`
+	Headers     HeaderRules       `json:"headers"`
+	AllowCIDRs  []string          `json:"allow_cidrs,omitempty"`
+	BasicAuth   []BasicAuthUser   `json:"basic_auth,omitempty"`
+}
+
+// Revision is a configuration that was successfully applied to Caddy. It
+// holds every route at the time, including disabled ones, so that rolling
+// back restores the route table exactly. Routes and Config are omitted from
+// revision listings.
+type Revision struct {
+	ID         int64           `json:"id"`
+	Author     string          `json:"author"`
+	Message    string          `json:"message"`
+	RouteCount int             `json:"route_count"`
+	Routes     []Route         `json:"routes,omitempty"`
+	Config     json.RawMessage `json:"config,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// RevisionDiff lists the route changes between two revisions. Routes are
+// matched by ID.
+type RevisionDiff struct {
+	From          int64         `json:"from"`
+	To            int64         `json:"to"`
+	Added         []Route       `json:"added"`
+	Removed       []Route       `json:"removed"`
+	Changed       []RouteChange `json:"changed"`
+	ConfigChanged bool          `json:"config_changed"`
+}
+
+// RouteChange is a route present in both revisions of a diff with at least
+// one differing field. Fields holds the JSON names of those fields.
+type RouteChange struct {
+	RouteID int64    `json:"route_id"`
+	Fields  []string `json:"fields"`
+	Before  Route    `json:"before"`
+	After   Route    `json:"after"`
+}
+
+// APIToken is a bearer token for scripts and the host agent. Only a hash of
+// the token is stored; Prefix holds its first characters so users can tell
+// tokens apart.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Webhook formats.
+const (
+	WebhookFormatGeneric = "generic"
+	WebhookFormatSlack   = "slack"
+	WebhookFormatNtfy    = "ntfy"
+)
+
+// Webhook delivers events to an external URL. Events lists the event types
+// it receives. Secret signs deliveries with HMAC-SHA256; it is never
+// returned by the API, HasSecret tells whether one is set.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Format    string    `json:"format"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	HasSecret bool      `json:"has_secret"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         int64     `json:"id"`
+	WebhookID  int64     `json:"webhook_id"`
+	EventID    int64     `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	Attempt    int       `json:"attempt"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   int64     `json:"duration_ms"`
+	Payload    string    `json:"payload"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TrafficEntry is a request captured from Caddy's access log. RouteID is 0
+// for requests that matched no route. Bodies are only captured when a body
+// limit is configured and are cut to it; the Truncated flags tell when.
+type TrafficEntry struct {
+	ID                    int64               `json:"id"`
+	RouteID               int64               `json:"route_id"`
+	Time                  time.Time           `json:"time"`
+	Method                string              `json:"method"`
+	Host                  string              `json:"host"`
+	URI                   string              `json:"uri"`
+	Proto                 string              `json:"proto"`
+	TLS                   bool                `json:"tls"`
+	RemoteIP              string              `json:"remote_ip"`
+	Status                int                 `json:"status"`
+	Duration              float64             `json:"duration_ms"`
+	BytesRead             int64               `json:"bytes_read"`
+	Size                  int64               `json:"size"`
+	RequestHeaders        map[string][]string `json:"request_headers,omitempty"`
+	ResponseHeaders       map[string][]string `json:"response_headers,omitempty"`
+	RequestBody           string              `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool                `json:"request_body_truncated,omitempty"`
+	ResponseBody          string              `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool                `json:"response_body_truncated,omitempty"`
+}
+
+// ComposeRequest is a request sent to a route by the request composer. Host
+// defaults to the route domain; Path is the public path, including the
+// route's path prefix.
+type ComposeRequest struct {
+	Method         string            `json:"method"`
+	Host           string            `json:"host,omitempty"`
+	Path           string            `json:"path"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+}
+
+// ComposeTimings breaks down the time of a composed request in
+// milliseconds. Phases that did not happen, such as TLS for plain HTTP, are
+// -1.
+type ComposeTimings struct {
+	DNS     float64 `json:"dns_ms"`
+	Connect float64 `json:"connect_ms"`
+	TLS     float64 `json:"tls_ms"`
+	Send    float64 `json:"send_ms"`
+	TTFB    float64 `json:"ttfb_ms"`
+	Receive float64 `json:"receive_ms"`
+	Total   float64 `json:"total_ms"`
+}
+
+// ComposeResponse is the outcome of one leg of a composed request. Bodies
+// that are not valid UTF-8 are base64 encoded with BodyEncoding "base64".
+type ComposeResponse struct {
+	URL            string              `json:"url"`
+	ServerAddress  string              `json:"server_address,omitempty"`
+	RequestHeaders map[string][]string `json:"request_headers"`
+	Status         int                 `json:"status,omitempty"`
+	StatusText     string              `json:"status_text,omitempty"`
+	Proto          string              `json:"proto,omitempty"`
+	Headers        map[string][]string `json:"headers,omitempty"`
+	Body           string              `json:"body,omitempty"`
+	BodyEncoding   string              `json:"body_encoding,omitempty"`
+	BodySize       int64               `json:"body_size"`
+	BodyTruncated  bool                `json:"body_truncated,omitempty"`
+	Error          string              `json:"error,omitempty"`
+	Timings        ComposeTimings      `json:"timings"`
+	StartedAt      time.Time           `json:"started_at"`
+}
+
+// ComposeResult holds the responses of a request sent through Caddy and
+// directly to the route's upstream.
+type ComposeResult struct {
+	ID        int64           `json:"id"`
+	RouteID   int64           `json:"route_id"`
+	Request   ComposeRequest  `json:"request"`
+	Proxy     ComposeResponse `json:"proxy"`
+	Direct    ComposeResponse `json:"direct"`
+	CreatedAt time.Time       `json:"created_at"`
 }
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"devproxy/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicAuthRealm is the realm browsers show in the login prompt.
+const basicAuthRealm = "DevProxy"
+
+var basicAuthUsernameRe = regexp.MustCompile(`^[A-Za-z0-9._@-]{1,64}$`)
+
+// NormalizeCIDRs validates an IP allowlist. Plain addresses are converted to
+// single-host ranges and all entries are returned in canonical form without
+// duplicates.
+func NormalizeCIDRs(cidrs []string) ([]string, error) {
+	seen := make(map[string]bool)
+	out := []string{}
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address or CIDR %q", c)
+			}
+			if ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or CIDR %q", c)
+		}
+		if s := ipnet.String(); !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// ValidateBasicAuthUsername checks a basic auth username.
+func ValidateBasicAuthUsername(username string) error {
+	if !basicAuthUsernameRe.MatchString(username) {
+		return errors.New("username must be 1-64 letters, digits or . _ @ -")
+	}
+	return nil
+}
+
+// HashBasicAuthPassword hashes a basic auth password with bcrypt.
+func HashBasicAuthPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password is required")
+	}
+	if len(password) > 72 {
+		return "", errors.New("password must be at most 72 bytes")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// NormalizeImportedBasicAuth validates basic auth accounts from an import.
+// Only bcrypt hashes are accepted, never plaintext passwords.
+func NormalizeImportedBasicAuth(users []models.BasicAuthUser) ([]models.BasicAuthUser, error) {
+	seen := make(map[string]bool)
+	out := make([]models.BasicAuthUser, 0, len(users))
+	for _, u := range users {
+		if err := ValidateBasicAuthUsername(u.Username); err != nil {
+			return nil, err
+		}
+		if seen[u.Username] {
+			return nil, fmt.Errorf("duplicate user %q", u.Username)
+		}
+		seen[u.Username] = true
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %q: password_hash must be a bcrypt hash", u.Username)
+		}
+		out = append(out, models.BasicAuthUser{Username: u.Username, PasswordHash: u.PasswordHash})
+	}
+	return out, nil
+}
+
+// accessHandlers returns the Caddy handlers enforcing a route's IP
+// allowlist and basic auth, which run before the route is proxied.
+func accessHandlers(r models.Route) []map[string]interface{} {
+	var handlers []map[string]interface{}
+	if len(r.AllowCIDRs) > 0 {
+		handlers = append(handlers, map[string]interface{}{
+			"handler": "subroute",
+			"routes": []map[string]interface{}{{
+				"match": []map[string]interface{}{{
+					"not": []map[string]interface{}{{"remote_ip": map[string]interface{}{"ranges": r.AllowCIDRs}}},
+				}},
+				"handle": []map[string]interface{}{{
+					"handler":     "static_response",
+					"status_code": 403,
+					"body":        "Forbidden",
+				}},
+			}},
+		})
+	}
+	if len(r.BasicAuth) > 0 {
+		accounts := make([]map[string]string, len(r.BasicAuth))
+		for i, u := range r.BasicAuth {
+			// Caddy expects the hash base64-encoded in JSON configs.
+			accounts[i] = map[string]string{
+				"username": u.Username,
+				"password": base64.StdEncoding.EncodeToString([]byte(u.PasswordHash)),
+			}
+		}
+		handlers = append(handlers, map[string]interface{}{
+			"handler": "authentication",
+			"providers": map[string]interface{}{
+				"http_basic": map[string]interface{}{
+					"accounts": accounts,
+					"hash":     map[string]string{"algorithm": "bcrypt"},
+					"realm":    basicAuthRealm,
+				},
+			},
+		})
+	}
+	return handlers
+}
+
+// caddyfileAccessLines renders a route's IP allowlist and basic auth as
+// Caddyfile directives.
+func caddyfileAccessLines(r models.Route) []string {
+	var lines []string
+	if len(r.AllowCIDRs) > 0 {
+		lines = append(lines,
+			fmt.Sprintf("@denied%d not remote_ip %s", r.ID, strings.Join(r.AllowCIDRs, " ")),
+			fmt.Sprintf("respond @denied%d \"Forbidden\" 403", r.ID),
+		)
+	}
+	if len(r.BasicAuth) > 0 {
+		lines = append(lines, "basic_auth bcrypt "+basicAuthRealm+" {")
+		for _, u := range r.BasicAuth {
+			lines = append(lines, "    "+u.Username+" "+u.PasswordHash)
+		}
+		lines = append(lines, "}")
+	}
+	return lines
+}
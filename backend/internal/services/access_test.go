@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestNormalizeCIDRs(t *testing.T) {
+	tests := []struct {
+		in      []string
+		want    []string
+		wantErr bool
+	}{
+		{nil, []string{}, false},
+		{[]string{"192.168.1.0/24"}, []string{"192.168.1.0/24"}, false},
+		{[]string{" 10.0.0.7 "}, []string{"10.0.0.7/32"}, false},
+		{[]string{"::1"}, []string{"::1/128"}, false},
+		{[]string{"10.1.2.3/8", "10.0.0.0/8"}, []string{"10.0.0.0/8"}, false},
+		{[]string{"example.com"}, nil, true},
+		{[]string{"10.0.0.0/33"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCIDRs(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeCIDRs(%v) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NormalizeCIDRs(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeImportedBasicAuth(t *testing.T) {
+	hash, err := HashBasicAuthPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		users   []models.BasicAuthUser
+		wantErr bool
+	}{
+		{"bcrypt hash", []models.BasicAuthUser{{Username: "dev", PasswordHash: hash}}, false},
+		{"plaintext", []models.BasicAuthUser{{Username: "dev", PasswordHash: "secret"}}, true},
+		{"invalid username", []models.BasicAuthUser{{Username: "dev user", PasswordHash: hash}}, true},
+		{"duplicate", []models.BasicAuthUser{{Username: "dev", PasswordHash: hash}, {Username: "dev", PasswordHash: hash}}, true},
+	}
+	for _, tt := range tests {
+		if _, err := NormalizeImportedBasicAuth(tt.users); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAccessHandlers(t *testing.T) {
+	r := models.Route{
+		ID:         3,
+		Target:     "app:3000",
+		AllowCIDRs: []string{"10.0.0.0/8"},
+		BasicAuth:  []models.BasicAuthUser{{Username: "dev", PasswordHash: "$2a$10$abc"}},
+	}
+
+	handlers := routeHandlers(r)
+	if len(handlers) != 3 || handlers[0]["handler"] != "subroute" || handlers[1]["handler"] != "authentication" || handlers[2]["handler"] != "reverse_proxy" {
+		t.Fatalf("handler chain = %v", handlers)
+	}
+	basic := handlers[1]["providers"].(map[string]interface{})["http_basic"].(map[string]interface{})
+	account := basic["accounts"].([]map[string]string)[0]
+	if account["password"] != base64.StdEncoding.EncodeToString([]byte("$2a$10$abc")) {
+		t.Errorf("password = %q, want the base64 hash", account["password"])
+	}
+
+	caddyfile := buildCaddyfile([]models.Route{r})
+	for _, want := range []string{"@denied3 not remote_ip 10.0.0.0/8", "respond @denied3", "basic_auth bcrypt DevProxy {", "dev $2a$10$abc"} {
+		if !strings.Contains(caddyfile, want) {
+			t.Errorf("Caddyfile missing %q:\n%s", want, caddyfile)
+		}
+	}
+}
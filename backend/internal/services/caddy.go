@@ -2,9 +2,13 @@
 package services
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,92 +17,415 @@ import (
 	"time"
 
 	"devproxy/internal/database"
+	"devproxy/internal/events"
 	"devproxy/internal/models"
 )
 
 var (
+	configPath      string
 	caddyfilePath   string
 	caddyAPI        string
 	appliedState    []models.AppliedRoute
+	appliedConfig   *caddyConfig
 	appliedStateMux sync.RWMutex
+	reloadMux       sync.Mutex
 )
 
-// InitCaddy initializes the Caddy service with configuration paths.
-func InitCaddy(caddyfile, api string) {
+// CaddyError is an error response from the Caddy admin API.
+type CaddyError struct {
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message"`
+}
+
+func (e *CaddyError) Error() string {
+	return fmt.Sprintf("Caddy returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// adminOp is a single request against the Caddy admin API.
+type adminOp struct {
+	Method string
+	Path   string
+	Body   interface{}
+}
+
+// InitCaddy initializes the Caddy service. The JSON config is written to
+// config, which Caddy loads when it starts. If caddyfile is not empty, a
+// Caddyfile export of every applied config is written there as well.
+func InitCaddy(config, caddyfile, api string) {
+	configPath = config
 	caddyfilePath = caddyfile
 	caddyAPI = api
 }
 
-// GenerateCaddyfile generates the Caddyfile from enabled routes.
-func GenerateCaddyfile() error {
+// IsCaddyPeer reports whether remoteAddr, the address of a connection, is
+// Caddy's, found by resolving the host of its admin API. The listeners Caddy
+// calls back into check it because the backend also shares the dev-proxy
+// network with project containers.
+func IsCaddyPeer(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	ips, err := net.LookupIP(caddyProxyHost())
+	if err != nil {
+		return false
+	}
+	for _, candidate := range ips {
+		if candidate.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateConfig writes the config for the enabled routes to disk without
+// contacting Caddy, and records it as applied. It is used at startup, when
+// Caddy picks the file up itself. If the routes fail validation the file on
+// disk, i.e. the last good config, is kept and recorded instead.
+func GenerateConfig() error {
 	routes, err := database.GetEnabledRoutes()
 	if err != nil {
 		log.Printf("Error querying routes: %v", err)
 		return err
 	}
 
-	var sb strings.Builder
-	sb.WriteString("# DevProxy Caddyfile - Auto-generated\n")
-	sb.WriteString("{\n")
-	sb.WriteString("    admin :2019\n")
-	sb.WriteString("    auto_https off\n")
-	sb.WriteString("    http_port 80\n")
-	sb.WriteString("}\n\n")
-
-	for _, r := range routes {
-		sb.WriteString(fmt.Sprintf("http://%s {\n", r.Domain))
-		sb.WriteString(fmt.Sprintf("    reverse_proxy %s\n", r.Target))
-		sb.WriteString("}\n\n")
+	if errs := ValidateRoutes(routes); len(errs) > 0 {
+		applyErr := &ApplyError{Message: "Routes failed validation", Errors: errs}
+		log.Printf("Not generating Caddy config: %v", applyErr)
+		if cfg, err := readConfigFile(); err == nil {
+			appliedStateMux.Lock()
+			appliedConfig = cfg
+			appliedStateMux.Unlock()
+		}
+		return applyErr
 	}
 
-	// Ensure directory exists
-	dir := filepath.Dir(caddyfilePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Printf("Error creating directory: %v", err)
+	cfg := buildCaddyConfig(routes)
+	if err := writeConfigFiles(cfg, routes); err != nil {
+		log.Printf("Error writing Caddy config: %v", err)
 		return err
 	}
 
-	if err := os.WriteFile(caddyfilePath, []byte(sb.String()), 0644); err != nil {
-		log.Printf("Error writing Caddyfile: %v", err)
-		return err
+	appliedStateMux.Lock()
+	appliedConfig = cfg
+	appliedStateMux.Unlock()
+
+	if err := recordRevision(cfg, RevisionAuthorSystem, "Startup"); err != nil {
+		log.Printf("Error recording revision: %v", err)
 	}
 
-	log.Println("Caddyfile generated successfully")
+	log.Println("Caddy config generated successfully")
 	return nil
 }
 
-// ReloadCaddy regenerates the Caddyfile and reloads Caddy.
-// Returns a message and optional warning.
-func ReloadCaddy() (message string, warning string) {
-	if err := GenerateCaddyfile(); err != nil {
-		return "Failed to generate Caddyfile", err.Error()
+// ExportCaddyfile renders the enabled routes as a Caddyfile.
+func ExportCaddyfile() (string, error) {
+	routes, err := database.GetEnabledRoutes()
+	if err != nil {
+		return "", err
 	}
+	return buildCaddyfile(routes), nil
+}
 
-	content, err := os.ReadFile(caddyfilePath)
+// ExportCaddyJSON renders the enabled routes as native Caddy JSON.
+func ExportCaddyJSON() ([]byte, error) {
+	routes, err := database.GetEnabledRoutes()
 	if err != nil {
-		return "Failed to read Caddyfile", err.Error()
+		return nil, err
 	}
+	return json.MarshalIndent(buildCaddyConfig(routes), "", "  ")
+}
 
-	req, err := http.NewRequest("POST", caddyAPI+"/load", strings.NewReader(string(content)))
+// ReloadCaddy validates the enabled routes and pushes them to Caddy as JSON
+// through the admin API. When only individual sites changed they are patched
+// in place by @id; anything else loads the full config. If Caddy rejects the
+// config, the last good config is loaded again. The config file on disk is
+// only replaced once Caddy has accepted the new config.
+//
+// Every apply that changes the config is recorded as a revision by author.
+// A returned *ApplyError means nothing was applied; warning reports problems
+// that did not prevent the apply.
+func ReloadCaddy(author string) (message string, warning string, err error) {
+	reloadMux.Lock()
+	defer reloadMux.Unlock()
+	return reloadCaddy(author, "Apply")
+}
+
+// reloadCaddy must be called with reloadMux held.
+func reloadCaddy(author, revisionMessage string) (message string, warning string, err error) {
+	start := time.Now()
+	defer func() {
+		observeReload(start, err)
+		publishApply(author, message, warning, err)
+	}()
+
+	routes, err := database.GetEnabledRoutes()
 	if err != nil {
-		return "Caddyfile regenerated", err.Error()
+		return "", "", err
 	}
-	req.Header.Set("Content-Type", "text/caddyfile")
+	if errs := ValidateRoutes(routes); len(errs) > 0 {
+		return "", "", &ApplyError{Message: "Routes failed validation", Errors: errs}
+	}
+	cfg := buildCaddyConfig(routes)
+
+	appliedStateMux.RLock()
+	prev := appliedConfig
+	appliedStateMux.RUnlock()
+
+	ops, incremental := planConfigPatch(prev, cfg)
+	if incremental {
+		if err := applyAdminOps(ops); err != nil {
+			log.Printf("Incremental Caddy update failed, loading full config: %v", err)
+			incremental = false
+		}
+	}
+	if !incremental {
+		if err := caddyRequest(adminOp{Method: http.MethodPost, Path: "/load", Body: cfg}); err != nil {
+			return "", "", rejectConfig(cfg, prev, routes, err)
+		}
+	}
+
+	appliedStateMux.Lock()
+	appliedConfig = cfg
+	appliedStateMux.Unlock()
+	SaveAppliedState()
+
+	if err := recordRevision(cfg, author, revisionMessage); err != nil {
+		log.Printf("Error recording revision: %v", err)
+	}
+	if err := writeConfigFiles(cfg, routes); err != nil {
+		return "Proxy reloaded, but the config file could not be written", err.Error(), nil
+	}
+
+	if incremental {
+		if len(ops) == 0 {
+			return "Proxy already up to date", "", nil
+		}
+		return fmt.Sprintf("Proxy updated (%d site changes applied)", len(ops)), "", nil
+	}
+	return "Proxy reloaded successfully", "", nil
+}
+
+// publishApply publishes the outcome of an apply on the event stream.
+func publishApply(author, message, warning string, err error) {
+	if err != nil {
+		var applyErr *ApplyError
+		if !errors.As(err, &applyErr) {
+			applyErr = &ApplyError{Message: err.Error()}
+		}
+		events.Publish(events.ApplyFailed, map[string]interface{}{
+			"author":      author,
+			"error":       applyErr.Message,
+			"errors":      applyErr.Errors,
+			"rolled_back": applyErr.RolledBack,
+		})
+		return
+	}
+	events.Publish(events.ApplySucceeded, map[string]string{
+		"author":  author,
+		"message": message,
+		"warning": warning,
+	})
+}
+
+// rejectConfig restores the last good config after Caddy refused cfg and
+// builds the error describing which routes caused it.
+func rejectConfig(cfg, prev *caddyConfig, routes []models.Route, err error) *ApplyError {
+	applyErr := &ApplyError{Message: "Caddy rejected the config", Rejected: true}
+
+	var caddyErr *CaddyError
+	if errors.As(err, &caddyErr) {
+		applyErr.Errors = routeErrorsFromCaddy(cfg, routes, caddyErr.Message)
+	} else {
+		applyErr.Message = "Caddy reload failed"
+		applyErr.Errors = []RouteError{{Message: err.Error()}}
+	}
+
+	if prev != nil {
+		if err := caddyRequest(adminOp{Method: http.MethodPost, Path: "/load", Body: prev}); err != nil {
+			log.Printf("Rolling back Caddy config failed: %v", err)
+		} else {
+			applyErr.RolledBack = true
+		}
+	}
+	return applyErr
+}
+
+// planConfigPatch returns the admin API operations that turn the applied
+// config into next by patching site routes, or false if a full load is
+// required because servers, TLS settings or wildcard ordering changed.
+func planConfigPatch(prev, next *caddyConfig) ([]adminOp, bool) {
+	if prev == nil || !bytes.Equal(configSkeleton(prev), configSkeleton(next)) {
+		return nil, false
+	}
+
+	var deletes, patches, posts []adminOp
+	for name, srv := range next.Apps.HTTP.Servers {
+		old := make(map[string]caddyRoute)
+		oldWildcard := false
+		for _, r := range prev.Apps.HTTP.Servers[name].Routes {
+			old[r.ID] = r
+			oldWildcard = oldWildcard || siteHasWildcard(r)
+		}
+
+		current := make(map[string]bool)
+		for _, r := range srv.Routes {
+			current[r.ID] = true
+			prevRoute, exists := old[r.ID]
+			switch {
+			case !exists:
+				// Appended routes land after existing wildcard sites,
+				// which would shadow them.
+				if oldWildcard || siteHasWildcard(r) {
+					return nil, false
+				}
+				posts = append(posts, adminOp{Method: http.MethodPost, Path: "/config/apps/http/servers/" + name + "/routes", Body: r})
+			case !jsonEqual(prevRoute, r):
+				patches = append(patches, adminOp{Method: http.MethodPatch, Path: "/id/" + r.ID, Body: r})
+			}
+		}
+
+		for id := range old {
+			if !current[id] {
+				deletes = append(deletes, adminOp{Method: http.MethodDelete, Path: "/id/" + id})
+			}
+		}
+	}
+
+	ops := append(deletes, patches...)
+	return append(ops, posts...), true
+}
+
+// configSkeleton serializes a config without its site routes, so two
+// configs that only differ in sites compare equal.
+func configSkeleton(cfg *caddyConfig) []byte {
+	skeleton := *cfg
+	skeleton.Apps.HTTP.Servers = make(map[string]*caddyServer, len(cfg.Apps.HTTP.Servers))
+	for name, srv := range cfg.Apps.HTTP.Servers {
+		s := *srv
+		s.Routes = nil
+		skeleton.Apps.HTTP.Servers[name] = &s
+	}
+	data, _ := json.Marshal(skeleton)
+	return data
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return bytes.Equal(ja, jb)
+}
+
+func applyAdminOps(ops []adminOp) error {
+	for _, op := range ops {
+		if err := caddyRequest(op); err != nil {
+			return fmt.Errorf("%s %s: %w", op.Method, op.Path, err)
+		}
+	}
+	return nil
+}
+
+// caddyRequest sends a JSON request to the Caddy admin API. Error responses
+// are returned as *CaddyError.
+func caddyRequest(op adminOp) error {
+	var body io.Reader
+	if op.Body != nil {
+		data, err := json.Marshal(op.Body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(op.Method, caddyAPI+op.Path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
 
 	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "Caddyfile regenerated, Caddy reload failed", err.Error()
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
-		return "Caddyfile regenerated, Caddy returned error", string(body)
+		data, _ := io.ReadAll(resp.Body)
+		caddyErr := &CaddyError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		var parsed struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &parsed) == nil && parsed.Error != "" {
+			caddyErr.Message = parsed.Error
+		}
+		return caddyErr
 	}
+	return nil
+}
 
-	SaveAppliedState()
-	return "Proxy reloaded successfully", ""
+// writeConfigFiles writes the JSON config and, if configured, the
+// Caddyfile export.
+func writeConfigFiles(cfg *caddyConfig, routes []models.Route) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(configPath, data); err != nil {
+		return err
+	}
+	if caddyfilePath != "" {
+		return writeFile(caddyfilePath, []byte(buildCaddyfile(routes)))
+	}
+	return nil
+}
+
+// readConfigFile loads the last config written to disk.
+func readConfigFile() (*caddyConfig, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var cfg caddyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// writeFile replaces path atomically, so Caddy never starts from a
+// partially written config.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 // SaveAppliedState captures the current route configuration as the applied state.
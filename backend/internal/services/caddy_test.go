@@ -0,0 +1,168 @@
+package services
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestBuildCaddyfilePathRoutes(t *testing.T) {
+	routes := []models.Route{
+		{ID: 1, Domain: "app.test", Path: "", Target: "app-vite-1:5173"},
+		{ID: 2, Domain: "app.test", Path: "/api", StripPrefix: true, Target: "app-go-1:8080"},
+		{ID: 3, Domain: "plain.test", Target: "plain-nginx-1:80"},
+	}
+
+	content := buildCaddyfile(routes)
+
+	if strings.Count(content, "http://app.test {") != 1 {
+		t.Errorf("expected a single site block for app.test:\n%s", content)
+	}
+	if !strings.Contains(content, "@route2 path /api /api/*") {
+		t.Errorf("missing path matcher for /api:\n%s", content)
+	}
+	if !strings.Contains(content, "uri strip_prefix /api") {
+		t.Errorf("missing strip_prefix for /api:\n%s", content)
+	}
+	if strings.Index(content, "handle @route2") > strings.Index(content, "handle {") {
+		t.Errorf("catch-all handle must come after /api:\n%s", content)
+	}
+	if !strings.Contains(content, "http://plain.test {\n    reverse_proxy plain-nginx-1:80\n}") {
+		t.Errorf("single catch-all route should keep the simple form:\n%s", content)
+	}
+}
+
+func TestBuildCaddyfileInternalTLS(t *testing.T) {
+	routes := []models.Route{
+		{ID: 1, Domain: "secure.test", Target: "app-web-1:80", TLSMode: models.TLSModeInternal},
+		{ID: 2, Domain: "plain.test", Target: "plain-web-1:80"},
+	}
+
+	content := buildCaddyfile(routes)
+
+	if strings.Contains(content, "auto_https off") {
+		t.Errorf("auto_https must stay enabled when a route uses TLS:\n%s", content)
+	}
+	if !strings.Contains(content, "https://secure.test {\n    tls internal\n") {
+		t.Errorf("missing internal TLS site block:\n%s", content)
+	}
+	if !strings.Contains(content, "http://plain.test {") {
+		t.Errorf("plain route should stay on http://:\n%s", content)
+	}
+
+	content = buildCaddyfile(routes[1:])
+	if !strings.Contains(content, "auto_https off") {
+		t.Errorf("auto_https should be off without TLS routes:\n%s", content)
+	}
+}
+
+func TestBuildCaddyfileAliases(t *testing.T) {
+	routes := []models.Route{
+		{ID: 1, Domain: "shop.test", Aliases: []string{"*.shop.test", "store.test"}, Target: "shop-web-1:80"},
+		{ID: 2, Domain: "shop.test", Aliases: []string{"store.test"}, Path: "/api", Target: "shop-api-1:8080"},
+	}
+
+	content := buildCaddyfile(routes)
+
+	if !strings.Contains(content, "http://shop.test, http://store.test, http://*.shop.test {") {
+		t.Errorf("site block should list the domain and each alias once:\n%s", content)
+	}
+}
+
+func TestBuildCaddyConfig(t *testing.T) {
+	routes := []models.Route{
+		{ID: 1, Domain: "*.shop.test", Target: "shop-web-1"},
+		{ID: 2, Domain: "app.test", Path: "/api", StripPrefix: true, Target: "app-go-1:8080"},
+		{ID: 3, Domain: "secure.test", Target: "https://secure-web-1", TLSMode: models.TLSModeInternal},
+	}
+
+	cfg := buildCaddyConfig(routes)
+
+	if cfg.Admin.Listen != ":2019" {
+		t.Errorf("admin must stay reachable from the backend, got %q", cfg.Admin.Listen)
+	}
+
+	plain := cfg.Apps.HTTP.Servers[caddyServerHTTP]
+	if len(plain.Routes) != 2 || plain.Routes[0].ID != siteRouteID("app.test") {
+		t.Fatalf("exact sites must precede wildcard sites: %+v", plain.Routes)
+	}
+
+	sub := plain.Routes[0].Handle[0]["routes"].([]caddyRoute)
+	if len(sub) != 1 || sub[0].ID != "devproxy-route-2" {
+		t.Fatalf("unexpected subroutes: %+v", sub)
+	}
+	if sub[0].Handle[0]["handler"] != "rewrite" || sub[0].Handle[0]["strip_path_prefix"] != "/api" {
+		t.Errorf("missing strip prefix rewrite: %+v", sub[0].Handle)
+	}
+
+	wildcard := plain.Routes[1].Handle[0]["routes"].([]caddyRoute)
+	upstreams := wildcard[0].Handle[0]["upstreams"].([]map[string]interface{})
+	if upstreams[0]["dial"] != "shop-web-1:80" {
+		t.Errorf("dial = %v; want default port 80", upstreams[0]["dial"])
+	}
+
+	if _, ok := cfg.Apps.HTTP.Servers[caddyServerHTTPS]; !ok || cfg.Apps.TLS == nil {
+		t.Fatal("TLS route should add an HTTPS server and TLS automation")
+	}
+	if subjects := cfg.Apps.TLS.Automation.Policies[0].Subjects; len(subjects) != 1 || subjects[0] != "secure.test" {
+		t.Errorf("TLS subjects = %v", subjects)
+	}
+}
+
+func TestPlanConfigPatch(t *testing.T) {
+	base := []models.Route{
+		{ID: 1, Domain: "a.test", Target: "a-web-1:80"},
+		{ID: 2, Domain: "b.test", Target: "b-web-1:80"},
+	}
+	prev := buildCaddyConfig(base)
+
+	changed := append([]models.Route{}, base...)
+	changed[1].Target = "b-web-1:8080"
+	ops, ok := planConfigPatch(prev, buildCaddyConfig(changed))
+	if !ok || len(ops) != 1 || ops[0].Method != http.MethodPatch || ops[0].Path != "/id/"+siteRouteID("b.test") {
+		t.Errorf("changing a target should patch one site, got %v %+v", ok, ops)
+	}
+
+	ops, ok = planConfigPatch(prev, buildCaddyConfig(base[:1]))
+	if !ok || len(ops) != 1 || ops[0].Method != http.MethodDelete {
+		t.Errorf("disabling a route should delete its site, got %v %+v", ok, ops)
+	}
+
+	added := append(append([]models.Route{}, base...), models.Route{ID: 3, Domain: "c.test", Target: "c-web-1:80"})
+	ops, ok = planConfigPatch(prev, buildCaddyConfig(added))
+	if !ok || len(ops) != 1 || ops[0].Method != http.MethodPost {
+		t.Errorf("adding a route should append its site, got %v %+v", ok, ops)
+	}
+
+	withTLS := append([]models.Route{}, base...)
+	withTLS[0].TLSMode = models.TLSModeInternal
+	if _, ok := planConfigPatch(prev, buildCaddyConfig(withTLS)); ok {
+		t.Error("enabling TLS changes servers and must require a full load")
+	}
+
+	if _, ok := planConfigPatch(nil, prev); ok {
+		t.Error("without an applied config a full load is required")
+	}
+}
+
+func TestIsCaddyPeer(t *testing.T) {
+	defer func(api string) { caddyAPI = api }(caddyAPI)
+	caddyAPI = "http://127.0.0.1:2019"
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1:41000", true},
+		{"127.0.0.1", true},
+		{"172.18.0.7:41000", false},
+		{"not-an-ip:80", false},
+	}
+	for _, tt := range tests {
+		if got := IsCaddyPeer(tt.addr); got != tt.want {
+			t.Errorf("IsCaddyPeer(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
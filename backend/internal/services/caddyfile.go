@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// buildCaddyfile renders the Caddyfile for the given enabled routes. Routes
+// sharing a domain are grouped into one site block. Caddy is configured
+// through JSON; the Caddyfile is only kept as a human-readable export.
+func buildCaddyfile(routes []models.Route) string {
+	domains, byDomain := groupByDomain(routes)
+	anyTLS := false
+	for _, r := range routes {
+		anyTLS = anyTLS || r.TLSMode == models.TLSModeInternal
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# DevProxy Caddyfile - Auto-generated\n")
+	sb.WriteString("{\n")
+	sb.WriteString("    admin :2019\n")
+	if anyTLS {
+		// Keep automatic HTTP->HTTPS redirects for TLS sites; plain sites
+		// use explicit http:// addresses and are left alone. The root CA is
+		// installed on the host by the agent, not inside the container.
+		sb.WriteString("    skip_install_trust\n")
+	} else {
+		sb.WriteString("    auto_https off\n")
+	}
+	sb.WriteString("    http_port 80\n")
+	if anyTLS {
+		sb.WriteString("    https_port 443\n")
+	}
+	sb.WriteString("}\n\n")
+
+	for _, domain := range domains {
+		group := byDomain[domain]
+		SortRoutesForDomain(group)
+
+		scheme := "http://"
+		if siteUsesTLS(group) {
+			scheme = "https://"
+		}
+		addrs := siteAddresses(group)
+		for i := range addrs {
+			addrs[i] = scheme + addrs[i]
+		}
+		sb.WriteString(strings.Join(addrs, ", ") + " {\n")
+		if scheme == "https://" {
+			sb.WriteString("    tls internal\n")
+		}
+		if len(group) == 1 && group[0].Path == "" {
+			writeRoute(&sb, "    ", group[0])
+		} else {
+			writePathRoutes(&sb, group)
+		}
+		if ErrorPagesEnabled() {
+			for _, line := range caddyfileErrorPageLines() {
+				sb.WriteString("    " + line + "\n")
+			}
+		}
+		sb.WriteString("}\n\n")
+	}
+
+	return sb.String()
+}
+
+// siteUsesTLS reports whether any route on a domain requests HTTPS.
+func siteUsesTLS(group []models.Route) bool {
+	for _, r := range group {
+		if r.TLSMode == models.TLSModeInternal {
+			return true
+		}
+	}
+	return false
+}
+
+// writePathRoutes writes an ordered route block with one handle per path.
+// Caddy evaluates the handles top to bottom and runs only the first match.
+func writePathRoutes(sb *strings.Builder, group []models.Route) {
+	for _, r := range group {
+		if matchers := pathMatchers(r.Path); matchers != nil {
+			sb.WriteString(fmt.Sprintf("    @route%d path %s\n", r.ID, strings.Join(matchers, " ")))
+		}
+	}
+
+	sb.WriteString("    route {\n")
+	for _, r := range group {
+		if r.Path == "" {
+			sb.WriteString("        handle {\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("        handle @route%d {\n", r.ID))
+			if prefix := stripPrefixFor(r.Path); r.StripPrefix && prefix != "" {
+				sb.WriteString(fmt.Sprintf("            uri strip_prefix %s\n", prefix))
+			}
+		}
+		writeRoute(sb, "            ", r)
+		sb.WriteString("        }\n")
+	}
+	sb.WriteString("    }\n")
+}
+
+// writeRoute writes the directives of a route: the error page variable,
+// the access checks, then either the maintenance page or the handler.
+func writeRoute(sb *strings.Builder, indent string, r models.Route) {
+	var lines []string
+	if ErrorPagesEnabled() {
+		lines = append(lines, errorPageVarLine(r))
+	}
+	lines = append(lines, caddyfileAccessLines(r)...)
+	if r.Maintenance {
+		lines = append(lines, caddyfileMaintenanceLines()...)
+	}
+	for _, line := range lines {
+		sb.WriteString(indent + line + "\n")
+	}
+	if !r.Maintenance {
+		writeReverseProxy(sb, indent, r)
+	}
+}
+
+// writeReverseProxy writes the reverse_proxy directive of a route, with a
+// block for its header rules if it has any. Routes that do not proxy get
+// the directives of their kind instead.
+func writeReverseProxy(sb *strings.Builder, indent string, r models.Route) {
+	if !IsProxyRoute(r) {
+		for _, line := range caddyfileKindLines(r) {
+			sb.WriteString(indent + line + "\n")
+		}
+		return
+	}
+	lines := caddyfileHeaderLines(r.Headers)
+	if len(lines) == 0 {
+		sb.WriteString(fmt.Sprintf("%sreverse_proxy %s\n", indent, r.Target))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("%sreverse_proxy %s {\n", indent, r.Target))
+	for _, line := range lines {
+		sb.WriteString(indent + "    " + line + "\n")
+	}
+	sb.WriteString(indent + "}\n")
+}
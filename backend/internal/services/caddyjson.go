@@ -0,0 +1,296 @@
+package services
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// Caddy JSON config structures. Only the parts DevProxy generates are
+// modelled; handlers and matchers are plain maps as in Caddy's own docs.
+type caddyConfig struct {
+	Admin   caddyAdmin    `json:"admin"`
+	Logging *caddyLogging `json:"logging,omitempty"`
+	Apps    caddyApps     `json:"apps"`
+}
+
+type caddyAdmin struct {
+	Listen string `json:"listen"`
+}
+
+type caddyLogging struct {
+	Logs map[string]*caddyLog `json:"logs"`
+}
+
+type caddyLog struct {
+	Writer  map[string]interface{} `json:"writer,omitempty"`
+	Encoder map[string]interface{} `json:"encoder,omitempty"`
+	Include []string               `json:"include,omitempty"`
+	Exclude []string               `json:"exclude,omitempty"`
+}
+
+type caddyApps struct {
+	HTTP caddyHTTPApp `json:"http"`
+	TLS  *caddyTLSApp `json:"tls,omitempty"`
+	PKI  *caddyPKIApp `json:"pki,omitempty"`
+}
+
+type caddyHTTPApp struct {
+	HTTPPort  int                     `json:"http_port"`
+	HTTPSPort int                     `json:"https_port,omitempty"`
+	Servers   map[string]*caddyServer `json:"servers"`
+}
+
+type caddyServer struct {
+	Listen         []string         `json:"listen"`
+	Routes         []caddyRoute     `json:"routes"`
+	AutomaticHTTPS *caddyAutoHTTPS  `json:"automatic_https,omitempty"`
+	Logs           *caddyServerLogs `json:"logs,omitempty"`
+	Errors         *caddyErrors     `json:"errors,omitempty"`
+}
+
+type caddyErrors struct {
+	Routes []caddyRoute `json:"routes"`
+}
+
+type caddyServerLogs struct {
+	DefaultLoggerName string `json:"default_logger_name"`
+}
+
+type caddyAutoHTTPS struct {
+	Disable bool `json:"disable,omitempty"`
+}
+
+type caddyRoute struct {
+	ID       string                   `json:"@id,omitempty"`
+	Match    []map[string]interface{} `json:"match,omitempty"`
+	Handle   []map[string]interface{} `json:"handle"`
+	Terminal bool                     `json:"terminal,omitempty"`
+}
+
+type caddyTLSApp struct {
+	Automation caddyTLSAutomation `json:"automation"`
+}
+
+type caddyTLSAutomation struct {
+	Policies []caddyTLSPolicy `json:"policies"`
+}
+
+type caddyTLSPolicy struct {
+	Subjects []string                 `json:"subjects"`
+	Issuers  []map[string]interface{} `json:"issuers"`
+}
+
+type caddyPKIApp struct {
+	CertificateAuthorities map[string]caddyCA `json:"certificate_authorities"`
+}
+
+type caddyCA struct {
+	InstallTrust bool `json:"install_trust"`
+}
+
+// Server names in the generated config.
+const (
+	caddyServerHTTP  = "devproxy_http"
+	caddyServerHTTPS = "devproxy_https"
+)
+
+// siteRouteID is the @id of a site's top-level route, used to patch a
+// single site through the admin API.
+func siteRouteID(domain string) string {
+	return "devproxy-site-" + domain
+}
+
+// buildCaddyConfig renders the native Caddy JSON config for the given
+// enabled routes. Each domain becomes one host-matched route whose subroute
+// holds the path routes in evaluation order.
+func buildCaddyConfig(routes []models.Route) *caddyConfig {
+	cfg := &caddyConfig{
+		Admin: caddyAdmin{Listen: ":2019"},
+		Apps: caddyApps{
+			HTTP: caddyHTTPApp{
+				HTTPPort: 80,
+				Servers: map[string]*caddyServer{
+					caddyServerHTTP: {
+						Listen:         []string{":80"},
+						Routes:         []caddyRoute{},
+						AutomaticHTTPS: &caddyAutoHTTPS{Disable: true},
+					},
+				},
+			},
+		},
+	}
+
+	var tlsSubjects []string
+	domains, byDomain := groupByDomain(routes)
+	for _, domain := range domains {
+		group := byDomain[domain]
+		SortRoutesForDomain(group)
+		site := buildSiteRoute(domain, group)
+
+		if !siteUsesTLS(group) {
+			srv := cfg.Apps.HTTP.Servers[caddyServerHTTP]
+			srv.Routes = append(srv.Routes, site)
+			continue
+		}
+
+		srv, ok := cfg.Apps.HTTP.Servers[caddyServerHTTPS]
+		if !ok {
+			// Automatic HTTPS stays on for this server so Caddy manages
+			// the certificates and adds HTTP->HTTPS redirects.
+			srv = &caddyServer{Listen: []string{":443"}, Routes: []caddyRoute{}}
+			cfg.Apps.HTTP.Servers[caddyServerHTTPS] = srv
+		}
+		srv.Routes = append(srv.Routes, site)
+		tlsSubjects = append(tlsSubjects, siteAddresses(group)...)
+	}
+
+	if len(tlsSubjects) > 0 {
+		cfg.Apps.HTTP.HTTPSPort = 443
+		cfg.Apps.TLS = &caddyTLSApp{Automation: caddyTLSAutomation{Policies: []caddyTLSPolicy{{
+			Subjects: tlsSubjects,
+			Issuers:  []map[string]interface{}{{"module": "internal"}},
+		}}}}
+		// The root CA is installed on the host by the agent, not inside
+		// the container.
+		cfg.Apps.PKI = &caddyPKIApp{CertificateAuthorities: map[string]caddyCA{
+			"local": {InstallTrust: false},
+		}}
+	}
+
+	for _, srv := range cfg.Apps.HTTP.Servers {
+		sortSiteRoutes(srv.Routes)
+	}
+	if TrafficEnabled() {
+		addTrafficLogging(cfg)
+	}
+	if ErrorPagesEnabled() {
+		addErrorPages(cfg)
+	}
+	return cfg
+}
+
+// buildSiteRoute builds the host-matched route for one domain.
+func buildSiteRoute(domain string, group []models.Route) caddyRoute {
+	var subroutes []caddyRoute
+	for _, r := range group {
+		sub := caddyRoute{
+			ID:       routeID(r.ID),
+			Handle:   routeHandlers(r),
+			Terminal: true,
+		}
+		if matchers := pathMatchers(r.Path); matchers != nil {
+			sub.Match = []map[string]interface{}{{"path": matchers}}
+		}
+		subroutes = append(subroutes, sub)
+	}
+
+	return caddyRoute{
+		ID:    siteRouteID(domain),
+		Match: []map[string]interface{}{{"host": siteAddresses(group)}},
+		Handle: []map[string]interface{}{{
+			"handler": "subroute",
+			"routes":  subroutes,
+		}},
+		Terminal: true,
+	}
+}
+
+// routeHandlers returns the handler chain for a single route: body capture
+// for the traffic inspector, access checks, the prefix rewrite and the
+// handler of the route's kind. Routes in maintenance keep their access
+// checks and then answer with the maintenance page.
+func routeHandlers(r models.Route) []map[string]interface{} {
+	var handlers []map[string]interface{}
+	if ErrorPagesEnabled() {
+		handlers = append(handlers, errorPageVars(r))
+	}
+	if trafficBodiesEnabled() {
+		handlers = append(handlers, trafficBodyHandlers()...)
+	}
+	handlers = append(handlers, accessHandlers(r)...)
+	if r.Maintenance {
+		return append(handlers, maintenanceHandlers()...)
+	}
+	if prefix := stripPrefixFor(r.Path); r.StripPrefix && prefix != "" {
+		handlers = append(handlers, map[string]interface{}{
+			"handler":           "rewrite",
+			"strip_path_prefix": prefix,
+		})
+	}
+
+	if !IsProxyRoute(r) {
+		return append(handlers, kindHandler(r))
+	}
+
+	dial, useTLS := upstreamDial(r.Target)
+	proxy := map[string]interface{}{
+		"handler":   "reverse_proxy",
+		"upstreams": []map[string]interface{}{{"dial": dial}},
+	}
+	if useTLS {
+		proxy["transport"] = map[string]interface{}{"protocol": "http", "tls": map[string]interface{}{}}
+	}
+	if headers := caddyHeaders(r.Headers); headers != nil {
+		proxy["headers"] = headers
+	}
+	return append(handlers, proxy)
+}
+
+// upstreamDial converts a route target ("host:port" or a URL) into a Caddy
+// dial address and whether the upstream speaks TLS.
+func upstreamDial(target string) (dial string, useTLS bool) {
+	host := target
+	port := "80"
+	if strings.Contains(target, "://") {
+		if u, err := url.Parse(target); err == nil {
+			host = u.Host
+			if u.Scheme == "https" {
+				useTLS = true
+				port = "443"
+			}
+		}
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host, useTLS
+	}
+	return net.JoinHostPort(host, port), useTLS
+}
+
+// sortSiteRoutes moves sites with wildcard hosts after exact ones, so that
+// "a.shop.test" is not shadowed by "*.shop.test".
+func sortSiteRoutes(routes []caddyRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return !siteHasWildcard(routes[i]) && siteHasWildcard(routes[j])
+	})
+}
+
+// siteHasWildcard also accepts configs decoded from disk, where the host
+// matcher is a []interface{}.
+func siteHasWildcard(r caddyRoute) bool {
+	for _, m := range r.Match {
+		switch hosts := m["host"].(type) {
+		case []string:
+			for _, h := range hosts {
+				if IsWildcard(h) {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, h := range hosts {
+				if s, _ := h.(string); IsWildcard(s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func routeID(id int64) string {
+	return "devproxy-route-" + strconv.FormatInt(id, 10)
+}
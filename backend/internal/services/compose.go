@@ -0,0 +1,412 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"devproxy/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxComposeFileSize bounds compose files read from disk.
+const maxComposeFileSize = 1 << 20
+
+// composeFileNames are the files Compose looks for in a project directory,
+// in order.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// ErrComposePathDenied is returned by ReadComposePath when reading compose
+// files from disk is off or the path is outside the compose base directory.
+var ErrComposePathDenied = errors.New("compose path not allowed")
+
+var (
+	// composeBaseDir confines compose files read from disk. Reading from
+	// disk is off when it is empty.
+	composeBaseDir     string
+	composeNetwork     = "dev-proxy"
+	composeProjectRe   = regexp.MustCompile(`[^a-z0-9_-]`)
+	composeVariableRe  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:?-([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+	composeHostLabelRe = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// ComposeService is one service of a compose file and the route proposed
+// for it, if any.
+type ComposeService struct {
+	Service   string              `json:"service"`
+	Container string              `json:"container"`
+	Ports     []int               `json:"ports"`
+	OnNetwork bool                `json:"on_network"`
+	Route     *models.ImportRoute `json:"route,omitempty"`
+	Notes     []string            `json:"notes,omitempty"`
+}
+
+// ComposePreview is the result of reading a compose file. Document holds the
+// proposed routes in the import format, ready for POST /api/import; Report
+// is what importing it in skip mode would do.
+type ComposePreview struct {
+	Project  string                `json:"project"`
+	Network  string                `json:"network"`
+	Services []ComposeService      `json:"services"`
+	Document models.ExportDocument `json:"document"`
+	Report   ImportReport          `json:"report"`
+}
+
+type composeFile struct {
+	Name     string                     `yaml:"name"`
+	Services map[string]composeService  `yaml:"services"`
+	Networks map[string]*composeNetDecl `yaml:"networks"`
+}
+
+type composeService struct {
+	ContainerName string        `yaml:"container_name"`
+	Ports         []interface{} `yaml:"ports"`
+	Expose        []interface{} `yaml:"expose"`
+	Networks      interface{}   `yaml:"networks"`
+	NetworkMode   string        `yaml:"network_mode"`
+	Labels        interface{}   `yaml:"labels"`
+}
+
+type composeNetDecl struct {
+	Name     string      `yaml:"name"`
+	External interface{} `yaml:"external"`
+}
+
+// ConfigureComposeImport sets the Docker network proposed routes rely on,
+// the one DevProxy shares with project containers, and the directory compose
+// files may be read from by path.
+func ConfigureComposeImport(network, baseDir string) {
+	composeNetwork = network
+	composeBaseDir = baseDir
+}
+
+// ReadComposePath reads a compose file, or the compose file of a project
+// directory, from the backend's file system. Relative paths are relative to
+// the compose base directory, and paths, after resolving symlinks, must stay
+// inside it.
+func ReadComposePath(path string) ([]byte, string, error) {
+	if composeBaseDir == "" {
+		return nil, "", fmt.Errorf("%w: set COMPOSE_BASE_DIR to read compose files by path", ErrComposePathDenied)
+	}
+	base, err := filepath.EvalSymlinks(composeBaseDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("compose base directory: %v", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	if path, err = resolveComposePath(base, path); err != nil {
+		return nil, "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		dir := path
+		path = ""
+		for _, name := range composeFileNames {
+			file, err := resolveComposePath(base, filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			if f, err := os.Stat(file); err == nil && f.Mode().IsRegular() {
+				path, info = file, f
+				break
+			}
+		}
+		if path == "" {
+			return nil, "", fmt.Errorf("no compose file in %s", dir)
+		}
+	}
+	if ext := filepath.Ext(path); !info.Mode().IsRegular() || (ext != ".yaml" && ext != ".yml") {
+		return nil, "", fmt.Errorf("%s is not a compose file", path)
+	}
+	if info.Size() > maxComposeFileSize {
+		return nil, "", fmt.Errorf("%s is larger than %d bytes", path, maxComposeFileSize)
+	}
+	data, err := os.ReadFile(path)
+	return data, path, err
+}
+
+// resolveComposePath resolves the symlinks of path and checks that it stays
+// inside base. The path is checked before it is resolved too, so that
+// errors do not reveal files outside base.
+func resolveComposePath(base, path string) (string, error) {
+	if !withinDir(base, filepath.Clean(path)) {
+		return "", fmt.Errorf("%w: %s is outside %s", ErrComposePathDenied, path, composeBaseDir)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if !withinDir(base, resolved) {
+		return "", fmt.Errorf("%w: %s is outside %s", ErrComposePathDenied, path, composeBaseDir)
+	}
+	return resolved, nil
+}
+
+// withinDir reports whether path is dir or inside it.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ComposeProjectName derives a Compose project name the way Compose does
+// for a directory: lowercased, with characters other than letters, digits,
+// dashes and underscores removed.
+func ComposeProjectName(name string) string {
+	return strings.TrimLeft(composeProjectRe.ReplaceAllString(strings.ToLower(name), ""), "_-")
+}
+
+// ParseCompose reads a compose file and proposes a proxy route for every
+// service with a port. Containers are addressed by the DNS name Compose
+// gives them, "<project>-<service>-1", or their container_name. project
+// overrides the name set in the file; without either, the name of the
+// project directory dir is used, as Compose does.
+func ParseCompose(data []byte, project, dir string) (ComposePreview, error) {
+	var file composeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return ComposePreview{}, fmt.Errorf("invalid compose file: %v", err)
+	}
+	if len(file.Services) == 0 {
+		return ComposePreview{}, errors.New("compose file has no services")
+	}
+	if project == "" {
+		project = file.Name
+	}
+	if project == "" && dir != "" {
+		project = filepath.Base(dir)
+	}
+	if project = ComposeProjectName(project); project == "" {
+		return ComposePreview{}, errors.New("compose project name is required: set name in the file or pass project")
+	}
+
+	preview := ComposePreview{Project: project, Network: composeNetwork, Services: []ComposeService{}}
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var routes []models.ImportRoute
+	for _, name := range names {
+		svc := parseComposeService(project, name, file.Services[name], file.Networks)
+		if svc.Route != nil {
+			routes = append(routes, *svc.Route)
+		}
+		preview.Services = append(preview.Services, svc)
+	}
+	preview.Document = NewExport(routes, nil)
+	if preview.Document.Routes == nil {
+		preview.Document.Routes = []models.ImportRoute{}
+	}
+	return preview, nil
+}
+
+func parseComposeService(project, name string, def composeService, networks map[string]*composeNetDecl) ComposeService {
+	svc := ComposeService{Service: name, Container: project + "-" + name + "-1", Ports: []int{}}
+	if def.ContainerName != "" {
+		svc.Container = def.ContainerName
+	}
+	for _, p := range def.Ports {
+		svc.addPort(composePort(p, true))
+	}
+	for _, p := range def.Expose {
+		svc.addPort(composePort(p, false))
+	}
+
+	if strings.HasPrefix(def.NetworkMode, "host") || strings.HasPrefix(def.NetworkMode, "service:") || strings.HasPrefix(def.NetworkMode, "container:") {
+		svc.Notes = append(svc.Notes, fmt.Sprintf("uses network_mode %s and cannot join the %s network", def.NetworkMode, composeNetwork))
+	}
+	for _, key := range composeServiceNetworks(def) {
+		if composeNetworkName(project, key, networks) == composeNetwork {
+			svc.OnNetwork = true
+		} else if key == composeNetwork {
+			svc.Notes = append(svc.Notes, fmt.Sprintf("network %s is not declared external, so Compose creates %s_%s instead of joining %s", key, project, key, composeNetwork))
+		}
+	}
+
+	if _, ok := composeLabels(def.Labels)[LabelDomain]; ok {
+		svc.Notes = append(svc.Notes, "has a "+LabelDomain+" label; Docker discovery creates its route")
+		return svc
+	}
+	if len(svc.Ports) == 0 {
+		svc.Notes = append(svc.Notes, "no ports or expose entries; nothing to route")
+		return svc
+	}
+	if len(svc.Ports) > 1 {
+		svc.Notes = append(svc.Notes, fmt.Sprintf("exposes several ports; proposed port %d", svc.Ports[0]))
+	}
+	if !svc.OnNetwork {
+		svc.Notes = append(svc.Notes, fmt.Sprintf("not on the %s network; add it to the service or the route will fail with 502", composeNetwork))
+	}
+
+	svc.Route = &models.ImportRoute{
+		Name:    project + "-" + name,
+		Domain:  composeHostLabel(name) + "." + composeHostLabel(project) + "." + composeTLD(),
+		Kind:    models.RouteKindProxy,
+		Target:  svc.Container + ":" + strconv.Itoa(svc.Ports[0]),
+		Enabled: true,
+	}
+	return svc
+}
+
+func (s *ComposeService) addPort(port int, note string) {
+	if note != "" {
+		s.Notes = append(s.Notes, note)
+	}
+	if port == 0 {
+		return
+	}
+	for _, p := range s.Ports {
+		if p == port {
+			return
+		}
+	}
+	s.Ports = append(s.Ports, port)
+}
+
+// composePort returns the container port of a ports or expose entry, in
+// short ("8080:80/tcp", "3000-3005") or long ({target: 80}) syntax. UDP
+// ports are ignored; unresolvable entries are returned as a note.
+func composePort(entry interface{}, published bool) (int, string) {
+	var spec string
+	switch e := entry.(type) {
+	case int:
+		return e, ""
+	case string:
+		spec = e
+	case map[string]interface{}:
+		if protocol, _ := e["protocol"].(string); protocol == "udp" {
+			return 0, ""
+		}
+		spec, published = fmt.Sprint(e["target"]), false
+	default:
+		return 0, fmt.Sprintf("cannot read port %v", entry)
+	}
+
+	resolved, ok := composeInterpolate(spec)
+	if !ok {
+		return 0, fmt.Sprintf("port %q uses a variable without a default", spec)
+	}
+	if i := strings.Index(resolved, "/"); i >= 0 {
+		if resolved[i+1:] == "udp" {
+			return 0, ""
+		}
+		resolved = resolved[:i]
+	}
+	if published {
+		resolved = resolved[strings.LastIndex(resolved, ":")+1:]
+	}
+	if i := strings.Index(resolved, "-"); i >= 0 {
+		resolved = resolved[:i]
+	}
+	port, err := strconv.Atoi(resolved)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Sprintf("cannot read port %q", spec)
+	}
+	return port, ""
+}
+
+// composeInterpolate replaces ${VAR:-default} and ${VAR-default} with their
+// defaults. The project's environment is not known here, so variables
+// without a default cannot be resolved.
+func composeInterpolate(s string) (string, bool) {
+	ok := true
+	out := composeVariableRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := composeVariableRe.FindStringSubmatch(m)
+		if sub[2] == "" {
+			ok = false
+			return ""
+		}
+		return sub[3]
+	})
+	return out, ok
+}
+
+// composeServiceNetworks returns the network keys of a service, given as a
+// list or a map. Services without networks or a network_mode are on the
+// default network.
+func composeServiceNetworks(def composeService) []string {
+	if def.Networks == nil && def.NetworkMode == "" {
+		return []string{"default"}
+	}
+	var keys []string
+	switch n := def.Networks.(type) {
+	case []interface{}:
+		for _, k := range n {
+			keys = append(keys, fmt.Sprint(k))
+		}
+	case map[string]interface{}:
+		for k := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+// composeNetworkName resolves a network key to the Docker network name:
+// its name, the name of an external network, or "<project>_<key>" for
+// networks Compose creates.
+func composeNetworkName(project, key string, networks map[string]*composeNetDecl) string {
+	decl := networks[key]
+	if decl == nil {
+		return project + "_" + key
+	}
+	if decl.Name != "" {
+		return decl.Name
+	}
+	switch ext := decl.External.(type) {
+	case bool:
+		if ext {
+			return key
+		}
+	case map[string]interface{}:
+		if name, ok := ext["name"].(string); ok && name != "" {
+			return name
+		}
+		return key
+	}
+	return project + "_" + key
+}
+
+// composeLabels returns service labels given as a map or a list of
+// "key=value" entries.
+func composeLabels(v interface{}) map[string]string {
+	labels := make(map[string]string)
+	switch l := v.(type) {
+	case map[string]interface{}:
+		for k, val := range l {
+			labels[k] = fmt.Sprint(val)
+		}
+	case []interface{}:
+		for _, entry := range l {
+			k, val, _ := strings.Cut(fmt.Sprint(entry), "=")
+			labels[k] = val
+		}
+	}
+	return labels
+}
+
+// composeHostLabel turns a service or project name into a hostname label.
+func composeHostLabel(name string) string {
+	return strings.Trim(composeHostLabelRe.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// composeTLD is the top-level domain of proposed routes: the first allowed
+// TLD, or "test".
+func composeTLD() string {
+	if len(allowedTLDs) > 0 {
+		return allowedTLDs[0]
+	}
+	return "test"
+}
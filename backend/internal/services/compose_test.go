@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testComposeFile = `
+name: Shop
+services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+      - "127.0.0.1:8443:443/tcp"
+    networks:
+      - proxy
+      - default
+  api:
+    container_name: shop-api
+    expose:
+      - "${API_PORT:-3000}"
+    networks:
+      dev-proxy: {}
+  db:
+    image: postgres
+  worker:
+    ports:
+      - target: 9000
+        published: 19000
+    labels:
+      - devproxy.domain=worker.test
+  admin_ui:
+    ports:
+      - "${ADMIN_PORT}:80"
+      - 5173
+networks:
+  proxy:
+    name: dev-proxy
+    external: true
+  dev-proxy: {}
+`
+
+func TestParseCompose(t *testing.T) {
+	preview, err := ParseCompose([]byte(testComposeFile), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Project != "shop" || preview.Network != "dev-proxy" {
+		t.Errorf("project = %q, network = %q", preview.Project, preview.Network)
+	}
+
+	tests := []struct {
+		service   string
+		target    string
+		domain    string
+		onNetwork bool
+		note      string
+	}{
+		{"admin_ui", "shop-admin_ui-1:5173", "admin-ui.shop.test", false, "ADMIN_PORT"},
+		{"api", "shop-api:3000", "api.shop.test", false, "not declared external"},
+		{"db", "", "", false, "no ports"},
+		{"web", "shop-web-1:80", "web.shop.test", true, "several ports"},
+		{"worker", "", "", false, "Docker discovery"},
+	}
+	if len(preview.Services) != len(tests) {
+		t.Fatalf("got %d services, want %d", len(preview.Services), len(tests))
+	}
+	for i, tt := range tests {
+		svc := preview.Services[i]
+		if svc.Service != tt.service {
+			t.Errorf("service %d = %q, want %q", i, svc.Service, tt.service)
+			continue
+		}
+		target, domain := "", ""
+		if svc.Route != nil {
+			target, domain = svc.Route.Target, svc.Route.Domain
+		}
+		if target != tt.target || domain != tt.domain || svc.OnNetwork != tt.onNetwork {
+			t.Errorf("%s: route %q -> %q, on network %v", tt.service, domain, target, svc.OnNetwork)
+		}
+		if !strings.Contains(strings.Join(svc.Notes, "; "), tt.note) {
+			t.Errorf("%s: notes %q, want one containing %q", tt.service, svc.Notes, tt.note)
+		}
+	}
+	if len(preview.Document.Routes) != 3 {
+		t.Errorf("document has %d routes, want 3", len(preview.Document.Routes))
+	}
+}
+
+func TestParseComposeDefaultNetwork(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		onNetwork bool
+	}{
+		{"external default", "services:\n  web:\n    expose: [80]\nnetworks:\n  default:\n    name: dev-proxy\n    external: true\n", true},
+		{"project default", "services:\n  web:\n    expose: [80]\n", false},
+		{"network_mode", "services:\n  web:\n    expose: [80]\n    network_mode: bridge\nnetworks:\n  default:\n    name: dev-proxy\n    external: true\n", false},
+	}
+	for _, tt := range tests {
+		preview, err := ParseCompose([]byte(tt.file), "app", "")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		svc := preview.Services[0]
+		if svc.OnNetwork != tt.onNetwork || strings.Contains(strings.Join(svc.Notes, "; "), "502") == tt.onNetwork {
+			t.Errorf("%s: on network %v, notes %q", tt.name, svc.OnNetwork, svc.Notes)
+		}
+	}
+}
+
+func TestParseComposeProjectName(t *testing.T) {
+	file := "services:\n  web:\n    expose: [80]\n"
+	tests := []struct {
+		project, dir, want string
+	}{
+		{"My App", "", "myapp"},
+		{"", "/src/Blog.Site", "blogsite"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		preview, err := ParseCompose([]byte(file), tt.project, tt.dir)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseCompose(%q, %q): expected error", tt.project, tt.dir)
+			}
+			continue
+		}
+		if err != nil || preview.Project != tt.want {
+			t.Errorf("ParseCompose(%q, %q) = %q, %v; want %q", tt.project, tt.dir, preview.Project, err, tt.want)
+		}
+	}
+}
+
+func TestReadComposePath(t *testing.T) {
+	defer ConfigureComposeImport(composeNetwork, composeBaseDir)
+	base := t.TempDir()
+	dir := filepath.Join(base, "shop")
+	outside := t.TempDir()
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{filepath.Join(dir, "docker-compose.yml"), filepath.Join(outside, "compose.yaml")} {
+		if err := os.WriteFile(file, []byte("services: {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secrets.env"), []byte("KEY=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(base, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(base, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	ConfigureComposeImport(composeNetwork, "")
+	if _, _, err := ReadComposePath(dir); !errors.Is(err, ErrComposePathDenied) {
+		t.Errorf("ReadComposePath() without a base directory: error = %v, want ErrComposePathDenied", err)
+	}
+
+	ConfigureComposeImport(composeNetwork, base)
+	for _, path := range []string{dir, "shop", "shop/docker-compose.yml"} {
+		if _, file, err := ReadComposePath(path); err != nil || filepath.Base(file) != "docker-compose.yml" {
+			t.Errorf("ReadComposePath(%q) = %q, %v", path, file, err)
+		}
+	}
+	if _, _, err := ReadComposePath(filepath.Join(dir, "secrets.env")); err == nil || errors.Is(err, ErrComposePathDenied) {
+		t.Errorf("expected non-YAML files to be refused, got %v", err)
+	}
+	if _, _, err := ReadComposePath(filepath.Join(base, "empty")); err == nil {
+		t.Error("expected an error for a directory without a compose file")
+	}
+	for _, path := range []string{outside, "../" + filepath.Base(outside), "link", "link/compose.yaml", "/etc/passwd.yml"} {
+		if _, _, err := ReadComposePath(path); !errors.Is(err, ErrComposePathDenied) {
+			t.Errorf("ReadComposePath(%q): error = %v, want ErrComposePathDenied", path, err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"devproxy/internal/models"
+)
+
+const (
+	// composeBodyLimit caps the response bodies kept by the composer.
+	composeBodyLimit = 1 << 20
+	// composeResultsKept is the number of results kept for HAR export.
+	composeResultsKept = 20
+
+	composeDefaultTimeout = 10
+	composeMaxTimeout     = 60
+)
+
+var (
+	composeMu      sync.Mutex
+	composeResults []models.ComposeResult
+	composeLastID  int64
+)
+
+// NormalizeComposeRequest validates a composer request for a route and
+// fills in defaults.
+func NormalizeComposeRequest(route models.Route, req models.ComposeRequest) (models.ComposeRequest, error) {
+	if !IsProxyRoute(route) {
+		return req, errors.New("only proxy routes have an upstream to compare with")
+	}
+
+	req.Method = strings.ToUpper(strings.TrimSpace(req.Method))
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if strings.ContainsAny(req.Method, " \t\r\n") {
+		return req, fmt.Errorf("invalid method %q", req.Method)
+	}
+
+	req.Host = strings.ToLower(strings.TrimSpace(req.Host))
+	if req.Host == "" {
+		req.Host = route.Domain
+	}
+	if IsWildcard(req.Host) {
+		return req, errors.New("host is required for wildcard domains, e.g. \"app." + strings.TrimPrefix(route.Domain, "*.") + "\"")
+	}
+	served := false
+	for _, h := range Hostnames(route) {
+		served = served || hostMatches(h, req.Host)
+	}
+	if !served {
+		return req, fmt.Errorf("host %q is not served by this route", req.Host)
+	}
+
+	req.Path = strings.TrimSpace(req.Path)
+	if req.Path == "" {
+		req.Path = pathLiteral(route.Path)
+	}
+	if req.Path == "" {
+		req.Path = "/"
+	}
+	if !strings.HasPrefix(req.Path, "/") {
+		return req, fmt.Errorf("path %q must start with /", req.Path)
+	}
+
+	switch {
+	case req.TimeoutSeconds == 0:
+		req.TimeoutSeconds = composeDefaultTimeout
+	case req.TimeoutSeconds < 0 || req.TimeoutSeconds > composeMaxTimeout:
+		return req, fmt.Errorf("timeout_seconds must be between 1 and %d", composeMaxTimeout)
+	}
+	return req, nil
+}
+
+// ComposeRoute sends a normalized request through Caddy with the route's
+// host and then straight to its upstream, and keeps the result for HAR
+// export.
+func ComposeRoute(route models.Route, req models.ComposeRequest) models.ComposeResult {
+	result := models.ComposeResult{
+		RouteID:   route.ID,
+		Request:   req,
+		Proxy:     composeViaProxy(route, req),
+		Direct:    composeDirect(route, req),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	composeMu.Lock()
+	defer composeMu.Unlock()
+	composeLastID++
+	result.ID = composeLastID
+	composeResults = append(composeResults, result)
+	if len(composeResults) > composeResultsKept {
+		composeResults = composeResults[len(composeResults)-composeResultsKept:]
+	}
+	return result
+}
+
+// GetComposeResult returns one of the latest composer results.
+func GetComposeResult(id int64) (models.ComposeResult, bool) {
+	composeMu.Lock()
+	defer composeMu.Unlock()
+	for _, r := range composeResults {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return models.ComposeResult{}, false
+}
+
+// composeViaProxy sends the request to Caddy's listener with the public URL
+// of the route. TLS routes are verified against Caddy's local CA.
+func composeViaProxy(route models.Route, req models.ComposeRequest) models.ComposeResponse {
+	scheme, port := "http", "80"
+	var tlsConfig *tls.Config
+	if route.TLSMode == models.TLSModeInternal {
+		scheme, port = "https", "443"
+		ca, err := GetRootCA()
+		if err != nil {
+			return models.ComposeResponse{
+				URL:       scheme + "://" + req.Host + req.Path,
+				StartedAt: time.Now().UTC(),
+				Error:     fmt.Sprintf("fetch Caddy's root certificate: %v", err),
+				Timings:   models.ComposeTimings{DNS: -1, Connect: -1, TLS: -1},
+			}
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM([]byte(ca.PEM))
+		tlsConfig = &tls.Config{RootCAs: pool, ServerName: req.Host}
+	}
+
+	return sendComposed(req, scheme+"://"+req.Host+req.Path, "", nil, net.JoinHostPort(caddyProxyHost(), port), tlsConfig)
+}
+
+// composeDirect sends the request to the upstream the way Caddy forwards
+// it: with the original Host header, the route prefix stripped if
+// configured and the X-Forwarded-* headers Caddy adds.
+func composeDirect(route models.Route, req models.ComposeRequest) models.ComposeResponse {
+	dial, useTLS := upstreamDial(route.Target)
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	p := req.Path
+	if prefix := stripPrefixFor(route.Path); route.StripPrefix && prefix != "" {
+		p = strings.TrimPrefix(p, prefix)
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+	}
+
+	proto := "http"
+	if route.TLSMode == models.TLSModeInternal {
+		proto = "https"
+	}
+	forwarded := map[string]string{
+		"X-Forwarded-Host":  req.Host,
+		"X-Forwarded-Proto": proto,
+	}
+	return sendComposed(req, scheme+"://"+dial+p, req.Host, forwarded, "", nil)
+}
+
+// caddyProxyHost is the host Caddy's HTTP listeners are reached on, which
+// is the host of its admin API.
+func caddyProxyHost() string {
+	if u, err := url.Parse(caddyAPI); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return "localhost"
+}
+
+// sendComposed sends one leg of a composed request on a fresh connection
+// and records its timings. host overrides the Host header, extra headers
+// are added unless the request sets them and dialAddr, if set, replaces the
+// address dialed for the URL.
+func sendComposed(req models.ComposeRequest, rawURL, host string, extra map[string]string, dialAddr string, tlsConfig *tls.Config) models.ComposeResponse {
+	res := models.ComposeResponse{
+		URL:       rawURL,
+		StartedAt: time.Now().UTC(),
+		Timings:   models.ComposeTimings{DNS: -1, Connect: -1, TLS: -1},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(req.Body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, rawURL, body)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	for k, v := range extra {
+		if httpReq.Header.Get(k) == "" {
+			httpReq.Header.Set(k, v)
+		}
+	}
+	if host != "" {
+		httpReq.Host = host
+	}
+	res.RequestHeaders = httpReq.Header.Clone()
+	res.RequestHeaders["Host"] = []string{httpReq.Host}
+
+	var dnsStart, connectStart, tlsStart, gotConn, wrote, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { res.Timings.DNS = millis(time.Since(dnsStart)) },
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(_, addr string, err error) {
+			res.Timings.Connect = millis(time.Since(connectStart))
+			if err == nil {
+				res.ServerAddress = addr
+			}
+		},
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { res.Timings.TLS = millis(time.Since(tlsStart)) },
+		GotConn:              func(httptrace.GotConnInfo) { gotConn = time.Now() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wrote = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+	httpReq = httpReq.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   tlsConfig,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if dialAddr != "" {
+				addr = dialAddr
+			}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		res.Error = err.Error()
+		res.Timings.Total = millis(time.Since(start))
+		return res
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, composeBodyLimit+1))
+	res.BodySize = int64(len(data))
+	if len(data) > composeBodyLimit {
+		data = data[:composeBodyLimit]
+		res.BodyTruncated = true
+		n, _ := io.Copy(io.Discard, resp.Body)
+		res.BodySize += n
+	}
+	end := time.Now()
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	res.Status = resp.StatusCode
+	res.StatusText = http.StatusText(resp.StatusCode)
+	res.Proto = resp.Proto
+	res.Headers = resp.Header
+	if utf8.Valid(data) {
+		res.Body = string(data)
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(data)
+		res.BodyEncoding = "base64"
+	}
+
+	if !wrote.IsZero() {
+		res.Timings.Send = millis(wrote.Sub(gotConn))
+		res.Timings.TTFB = millis(firstByte.Sub(wrote))
+	}
+	res.Timings.Receive = millis(end.Sub(firstByte))
+	res.Timings.Total = millis(end.Sub(start))
+	return res
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
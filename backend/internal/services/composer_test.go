@@ -0,0 +1,85 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestNormalizeComposeRequest(t *testing.T) {
+	route := models.Route{Domain: "app.test", Aliases: []string{"*.app.test"}, Path: "/api"}
+	tests := []struct {
+		req      models.ComposeRequest
+		wantHost string
+		wantPath string
+		wantErr  bool
+	}{
+		{models.ComposeRequest{}, "app.test", "/api", false},
+		{models.ComposeRequest{Method: "post", Host: "Admin.App.test", Path: "/api/users"}, "admin.app.test", "/api/users", false},
+		{models.ComposeRequest{Host: "other.test"}, "", "", true},
+		{models.ComposeRequest{Path: "api"}, "", "", true},
+		{models.ComposeRequest{TimeoutSeconds: 120}, "", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeComposeRequest(route, tt.req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeComposeRequest(%+v) error = %v, wantErr %v", tt.req, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (got.Host != tt.wantHost || got.Path != tt.wantPath || got.Method == "" || got.TimeoutSeconds != composeDefaultTimeout) {
+			t.Errorf("NormalizeComposeRequest(%+v) = %+v", tt.req, got)
+		}
+	}
+
+	if _, err := NormalizeComposeRequest(models.Route{Domain: "*.shop.test"}, models.ComposeRequest{}); err == nil {
+		t.Error("wildcard routes need an explicit host")
+	}
+}
+
+func TestComposeDirect(t *testing.T) {
+	var gotHost, gotPath, gotProto string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotPath, gotProto = r.Host, r.URL.Path, r.Header.Get("X-Forwarded-Proto")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	defer srv.Close()
+
+	route := models.Route{ID: 1, Domain: "app.test", Path: "/api", StripPrefix: true, Target: strings.TrimPrefix(srv.URL, "http://")}
+	req, err := NormalizeComposeRequest(route, models.ComposeRequest{Method: "POST", Path: "/api/users?x=1", Body: `{"a":1}`,
+		Headers: map[string]string{"Content-Type": "application/json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := composeDirect(route, req)
+	if res.Error != "" || res.Status != http.StatusTeapot || res.Body != "short and stout" {
+		t.Fatalf("composeDirect() = %+v", res)
+	}
+	if gotHost != "app.test" || gotPath != "/users" || gotProto != "http" {
+		t.Errorf("upstream saw host %q, path %q, proto %q", gotHost, gotPath, gotProto)
+	}
+	if res.Timings.Connect < 0 || res.Timings.TLS != -1 || res.Timings.Total <= 0 {
+		t.Errorf("timings = %+v", res.Timings)
+	}
+
+	// The proxy leg dials Caddy but keeps the public URL.
+	proxied := sendComposed(req, "http://app.test/api/users", "", nil, strings.TrimPrefix(srv.URL, "http://"), nil)
+	if proxied.Error != "" || gotPath != "/api/users" {
+		t.Errorf("sendComposed() = %+v, path %q", proxied, gotPath)
+	}
+
+	har := ComposeHAR(models.ComposeResult{Request: req, Proxy: proxied, Direct: res})
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("ComposeHAR() = %+v", har.Log)
+	}
+	entry := har.Log.Entries[1]
+	if entry.Response.Status != http.StatusTeapot || entry.Request.PostData == nil || entry.Request.PostData.MimeType != "application/json" ||
+		len(entry.Request.QueryString) != 1 || entry.ServerIPAddress != "127.0.0.1" || entry.Timings.SSL != -1 {
+		t.Errorf("HAR entry = %+v", entry)
+	}
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"devproxy/internal/database"
+	"devproxy/internal/docker"
+	"devproxy/internal/events"
+	"devproxy/internal/models"
+)
+
+// Container labels read by Docker discovery. Only devproxy.domain is
+// required; the target port defaults to the container's exposed port.
+const (
+	LabelDomain      = "devproxy.domain"
+	LabelPort        = "devproxy.port"
+	LabelName        = "devproxy.name"
+	LabelAliases     = "devproxy.aliases"
+	LabelPath        = "devproxy.path"
+	LabelPriority    = "devproxy.priority"
+	LabelStripPrefix = "devproxy.strip_prefix"
+	LabelTLS         = "devproxy.tls"
+	LabelEnable      = "devproxy.enable"
+
+	// Health check settings; see models.HealthCheck.
+	LabelHealthPath     = "devproxy.health.path"
+	LabelHealthMethod   = "devproxy.health.method"
+	LabelHealthStatus   = "devproxy.health.status"
+	LabelHealthBody     = "devproxy.health.body"
+	LabelHealthTimeout  = "devproxy.health.timeout"
+	LabelHealthInterval = "devproxy.health.interval"
+)
+
+const (
+	discoveryResync     = 60 * time.Second
+	discoveryDebounce   = 500 * time.Millisecond
+	discoveryMaxBackoff = 30 * time.Second
+)
+
+// DiscoveryStatus reports the state of Docker label discovery.
+type DiscoveryStatus struct {
+	Enabled       bool      `json:"enabled"`
+	Connected     bool      `json:"connected"`
+	Network       string    `json:"network"`
+	LastReconcile time.Time `json:"last_reconcile,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	Skipped       []string  `json:"skipped,omitempty"`
+}
+
+var (
+	dockerClient    *docker.Client
+	discoveryStatus DiscoveryStatus
+	discoveryMux    sync.Mutex
+)
+
+// StartDockerDiscovery watches the Docker daemon and keeps routes in sync
+// with the labels of containers on the given network. It runs forever and
+// reconnects with backoff when the daemon is unavailable.
+func StartDockerDiscovery(socketPath, network string) {
+	discoveryMux.Lock()
+	dockerClient = docker.NewClient(socketPath)
+	discoveryStatus = DiscoveryStatus{Enabled: true, Network: network}
+	discoveryMux.Unlock()
+
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		events, errs := dockerClient.Events(ctx)
+
+		if _, err := ReconcileDocker(ctx); err != nil {
+			log.Printf("Docker discovery: %v (retrying in %s)", err, backoff)
+		} else {
+			backoff = time.Second
+			log.Printf("Docker discovery: %v", watchDockerEvents(ctx, events, errs))
+		}
+		cancel()
+
+		setDiscoveryConnected(false)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > discoveryMaxBackoff {
+			backoff = discoveryMaxBackoff
+		}
+	}
+}
+
+// GetDiscoveryStatus returns the current Docker discovery state.
+func GetDiscoveryStatus() DiscoveryStatus {
+	discoveryMux.Lock()
+	defer discoveryMux.Unlock()
+	return discoveryStatus
+}
+
+// watchDockerEvents reconciles after container events, coalescing bursts
+// such as "docker compose up", and periodically as a safety net.
+func watchDockerEvents(ctx context.Context, events <-chan docker.Event, errs <-chan error) error {
+	resync := time.NewTicker(discoveryResync)
+	defer resync.Stop()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return <-errs
+			}
+			debounce = time.After(discoveryDebounce)
+		case <-debounce:
+			debounce = nil
+			if _, err := ReconcileDocker(ctx); err != nil {
+				return err
+			}
+		case <-resync.C:
+			if _, err := ReconcileDocker(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ReconcileDocker creates, updates and disables Docker-managed routes to
+// match the labels of running containers. It reloads Caddy when anything
+// changed and reports whether it did.
+func ReconcileDocker(ctx context.Context) (bool, error) {
+	discoveryMux.Lock()
+	defer discoveryMux.Unlock()
+
+	if dockerClient == nil {
+		return false, errors.New("Docker discovery is not enabled")
+	}
+
+	changed, skipped, err := reconcileDocker(ctx)
+	if err != nil {
+		discoveryStatus.Connected = false
+		discoveryStatus.LastError = err.Error()
+		return false, err
+	}
+
+	discoveryStatus.Connected = true
+	discoveryStatus.LastReconcile = time.Now()
+	discoveryStatus.LastError = ""
+	discoveryStatus.Skipped = skipped
+
+	if changed {
+		events.Publish(events.RoutesChanged, map[string]string{"action": "discovery"})
+		if message, warning, err := ReloadCaddy(RevisionAuthorDocker); err != nil {
+			log.Printf("Docker discovery: applying routes failed: %v", err)
+		} else if warning != "" {
+			log.Printf("Docker discovery: %s: %s", message, warning)
+		}
+	}
+	return changed, nil
+}
+
+// reconcileDocker must be called with discoveryMux held.
+func reconcileDocker(ctx context.Context) (changed bool, skipped []string, err error) {
+	containers, err := dockerClient.ListContainers(ctx, discoveryStatus.Network)
+	if err != nil {
+		return false, nil, err
+	}
+
+	desired, skipped := DesiredDockerRoutes(containers)
+
+	existing, err := database.GetRoutesBySource(models.RouteSourceDocker)
+	if err != nil {
+		return false, skipped, err
+	}
+	all, err := database.GetAllRoutes()
+	if err != nil {
+		return false, skipped, err
+	}
+
+	byRef := make(map[string]models.Route, len(existing))
+	for _, r := range existing {
+		byRef[r.SourceRef] = r
+	}
+
+	// Visit containers in name order so that when two claim the same
+	// hostname, the same one wins on every pass.
+	refs := make([]string, 0, len(desired))
+	for ref := range desired {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		want := desired[ref]
+		have, exists := byRef[ref]
+		if exists {
+			// Project membership and maintenance mode are assigned
+			// through the API, not labels.
+			want.ID = have.ID
+			want.ProjectID = have.ProjectID
+			want.Maintenance = have.Maintenance
+		}
+
+		if err := FindPathConflict(want, all); err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", want.SourceRef, err))
+			continue
+		}
+		if err := FindHostnameConflict(want, all); err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", want.SourceRef, err))
+			continue
+		}
+
+		switch {
+		case !exists:
+			if err := database.CreateRoute(&want); err != nil {
+				return changed, skipped, err
+			}
+			all = append(all, want)
+			log.Printf("Docker discovery: added %s -> %s", want.Domain, want.Target)
+			changed = true
+		case dockerRouteChanged(have, want):
+			if err := database.UpdateRoute(strconv.FormatInt(have.ID, 10), &want); err != nil {
+				return changed, skipped, err
+			}
+			replaceRoute(all, want)
+			log.Printf("Docker discovery: updated %s -> %s", want.Domain, want.Target)
+			changed = true
+		}
+	}
+
+	for ref, have := range byRef {
+		if _, ok := desired[ref]; ok || !have.Enabled {
+			continue
+		}
+		if err := database.SetRouteEnabled(have.ID, false); err != nil {
+			return changed, skipped, err
+		}
+		log.Printf("Docker discovery: disabled %s (container %s is gone)", have.Domain, ref)
+		changed = true
+	}
+
+	return changed, skipped, nil
+}
+
+// DesiredDockerRoutes builds routes from container labels, keyed by
+// container name. Containers with invalid labels are reported in skipped.
+func DesiredDockerRoutes(containers []docker.Container) (routes map[string]models.Route, skipped []string) {
+	routes = make(map[string]models.Route)
+	for _, c := range containers {
+		labels := c.Labels
+		if labels[LabelDomain] == "" || strings.EqualFold(labels[LabelEnable], "false") {
+			continue
+		}
+
+		r, err := routeFromLabels(c)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", c.Name(), err))
+			continue
+		}
+		routes[r.SourceRef] = r
+	}
+	return routes, skipped
+}
+
+func routeFromLabels(c docker.Container) (models.Route, error) {
+	labels := c.Labels
+	r := models.Route{
+		Name:      labels[LabelName],
+		Path:      NormalizePath(labels[LabelPath]),
+		Kind:      models.RouteKindProxy,
+		Enabled:   true,
+		Source:    models.RouteSourceDocker,
+		SourceRef: c.Name(),
+	}
+	if r.Name == "" {
+		r.Name = c.Name()
+	}
+
+	domain, err := NormalizeHostname(labels[LabelDomain])
+	if err != nil {
+		return r, err
+	}
+	r.Domain = domain
+
+	var aliases []string
+	if v := labels[LabelAliases]; v != "" {
+		aliases = strings.Split(v, ",")
+	}
+	if r.Aliases, err = NormalizeAliases(r.Domain, aliases); err != nil {
+		return r, err
+	}
+
+	port := labels[LabelPort]
+	if port == "" {
+		port = defaultContainerPort(c)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return r, fmt.Errorf("invalid %s %q", LabelPort, port)
+	}
+	r.Target = c.Name() + ":" + port
+
+	if v := labels[LabelPriority]; v != "" {
+		if r.Priority, err = strconv.Atoi(v); err != nil {
+			return r, fmt.Errorf("invalid %s %q", LabelPriority, v)
+		}
+	}
+	if v := labels[LabelStripPrefix]; v != "" {
+		if r.StripPrefix, err = strconv.ParseBool(v); err != nil {
+			return r, fmt.Errorf("invalid %s %q", LabelStripPrefix, v)
+		}
+	}
+
+	tls := labels[LabelTLS]
+	if on, err := strconv.ParseBool(tls); err == nil {
+		tls = models.TLSModeOff
+		if on {
+			tls = models.TLSModeInternal
+		}
+	}
+	if r.TLSMode, err = NormalizeTLSMode(tls); err != nil {
+		return r, err
+	}
+
+	hc := models.HealthCheck{
+		Path:           labels[LabelHealthPath],
+		Method:         labels[LabelHealthMethod],
+		ExpectedStatus: labels[LabelHealthStatus],
+		BodyContains:   labels[LabelHealthBody],
+	}
+	for label, field := range map[string]*int{LabelHealthTimeout: &hc.TimeoutSeconds, LabelHealthInterval: &hc.IntervalSeconds} {
+		if v := labels[label]; v != "" {
+			d, err := parseSeconds(v)
+			if err != nil {
+				return r, fmt.Errorf("invalid %s %q", label, v)
+			}
+			*field = d
+		}
+	}
+	if r.HealthCheck, err = NormalizeHealthCheck(hc); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// parseSeconds accepts a duration such as "10s" or "1m", or a plain number
+// of seconds.
+func parseSeconds(v string) (int, error) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+	return int(d.Seconds()), nil
+}
+
+// defaultContainerPort prefers port 80, then the lowest exposed TCP port.
+func defaultContainerPort(c docker.Container) string {
+	lowest := 0
+	for _, p := range c.Ports {
+		if p.Type != "" && p.Type != "tcp" {
+			continue
+		}
+		if p.PrivatePort == 80 {
+			return "80"
+		}
+		if lowest == 0 || p.PrivatePort < lowest {
+			lowest = p.PrivatePort
+		}
+	}
+	if lowest == 0 {
+		return "80"
+	}
+	return strconv.Itoa(lowest)
+}
+
+func dockerRouteChanged(have, want models.Route) bool {
+	return have.Name != want.Name ||
+		have.Domain != want.Domain ||
+		!reflect.DeepEqual(have.Aliases, want.Aliases) ||
+		have.Path != want.Path ||
+		have.Priority != want.Priority ||
+		have.StripPrefix != want.StripPrefix ||
+		have.Target != want.Target ||
+		have.TLSMode != want.TLSMode ||
+		have.HealthCheck != want.HealthCheck ||
+		have.Enabled != want.Enabled
+}
+
+func replaceRoute(routes []models.Route, r models.Route) {
+	for i := range routes {
+		if routes[i].ID == r.ID {
+			routes[i] = r
+			return
+		}
+	}
+}
+
+func setDiscoveryConnected(connected bool) {
+	discoveryMux.Lock()
+	discoveryStatus.Connected = connected
+	discoveryMux.Unlock()
+}
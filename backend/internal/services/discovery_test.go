@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"devproxy/internal/database"
+	"devproxy/internal/docker"
+	"devproxy/internal/docker/dockertest"
+	"devproxy/internal/models"
+)
+
+func TestRouteFromLabels(t *testing.T) {
+	c := dockertest.NewContainer("a1", "shop-api-1", "dev-proxy", map[string]string{
+		LabelDomain:         "Shop.test",
+		LabelAliases:        "store.test, *.shop.test",
+		LabelPath:           "/api/",
+		LabelStripPrefix:    "true",
+		LabelTLS:            "true",
+		LabelHealthPath:     "healthz",
+		LabelHealthInterval: "1m",
+	}, 9000, 8080)
+
+	r, err := routeFromLabels(c)
+	if err != nil {
+		t.Fatalf("routeFromLabels() error = %v", err)
+	}
+	if r.Domain != "shop.test" || r.Path != "/api" || !r.StripPrefix {
+		t.Errorf("unexpected route %+v", r)
+	}
+	if r.Target != "shop-api-1:8080" {
+		t.Errorf("Target = %q; want lowest exposed port", r.Target)
+	}
+	if r.TLSMode != models.TLSModeInternal {
+		t.Errorf("TLSMode = %q; want %q", r.TLSMode, models.TLSModeInternal)
+	}
+	if len(r.Aliases) != 2 || r.Aliases[1] != "*.shop.test" {
+		t.Errorf("Aliases = %v", r.Aliases)
+	}
+	if r.HealthCheck.Path != "/healthz" || r.HealthCheck.IntervalSeconds != 60 {
+		t.Errorf("HealthCheck = %+v", r.HealthCheck)
+	}
+
+	c.Labels[LabelPort] = "http"
+	if _, err := routeFromLabels(c); err == nil {
+		t.Error("expected an error for a non-numeric port label")
+	}
+}
+
+func TestReconcileDocker(t *testing.T) {
+	newTestEnv(t, nil)
+
+	srv, err := dockertest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	dockerClient = docker.NewClient(srv.SocketPath)
+	discoveryStatus = DiscoveryStatus{Enabled: true, Network: "dev-proxy"}
+	t.Cleanup(func() { dockerClient, discoveryStatus = nil, DiscoveryStatus{} })
+
+	labels := map[string]string{LabelDomain: "shop.test"}
+	srv.SetContainers(dockertest.NewContainer("a1", "shop-web-1", "dev-proxy", labels, 80))
+
+	if changed, err := ReconcileDocker(context.Background()); err != nil || !changed {
+		t.Fatalf("first ReconcileDocker() = %v, %v; want a change", changed, err)
+	}
+	routes, _ := database.GetRoutesBySource(models.RouteSourceDocker)
+	if len(routes) != 1 || routes[0].Target != "shop-web-1:80" || !routes[0].Enabled {
+		t.Fatalf("unexpected routes after create: %+v", routes)
+	}
+
+	if changed, _ := ReconcileDocker(context.Background()); changed {
+		t.Error("reconcile without label changes should be a no-op")
+	}
+
+	labels = map[string]string{LabelDomain: "shop.test", LabelPort: "8080"}
+	srv.SetContainers(dockertest.NewContainer("a1", "shop-web-1", "dev-proxy", labels, 80))
+	ReconcileDocker(context.Background())
+	routes, _ = database.GetRoutesBySource(models.RouteSourceDocker)
+	if len(routes) != 1 || routes[0].Target != "shop-web-1:8080" {
+		t.Fatalf("unexpected routes after label change: %+v", routes)
+	}
+
+	srv.SetContainers()
+	ReconcileDocker(context.Background())
+	routes, _ = database.GetRoutesBySource(models.RouteSourceDocker)
+	if len(routes) != 1 || routes[0].Enabled {
+		t.Fatalf("route should be disabled once the container is gone: %+v", routes)
+	}
+}
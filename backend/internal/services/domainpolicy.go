@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// allowedTLDs restricts the top-level domains routes may use. Any TLD is
+// allowed when it is empty.
+var allowedTLDs []string
+
+// hstsPreloadedTLDs are top-level domains on the HSTS preload list.
+// Browsers only reach them over HTTPS, so plain HTTP routes there never
+// load, and ".dev" in particular is a real, public TLD.
+var hstsPreloadedTLDs = map[string]bool{
+	"android": true, "app": true, "bank": true, "boo": true, "channel": true,
+	"chrome": true, "dad": true, "day": true, "dev": true, "eat": true,
+	"esq": true, "fly": true, "foo": true, "gle": true, "gmail": true,
+	"google": true, "hangout": true, "ing": true, "insurance": true,
+	"meet": true, "meme": true, "mov": true, "new": true, "nexus": true,
+	"page": true, "phd": true, "play": true, "prof": true, "rsvp": true,
+	"search": true, "youtube": true, "zip": true,
+}
+
+// ConfigureDomainPolicy restricts new and changed routes to the given
+// top-level domains, e.g. "test" and "localhost". An empty list allows any.
+func ConfigureDomainPolicy(tlds []string) {
+	allowedTLDs = nil
+	for _, tld := range tlds {
+		if tld = strings.Trim(strings.ToLower(strings.TrimSpace(tld)), "."); tld != "" {
+			allowedTLDs = append(allowedTLDs, tld)
+		}
+	}
+}
+
+// topLevelDomain returns the last label of a hostname.
+func topLevelDomain(h string) string {
+	return h[strings.LastIndex(h, ".")+1:]
+}
+
+// checkTLDPolicy reports a hostname whose TLD is not allowed.
+func checkTLDPolicy(h string) error {
+	if len(allowedTLDs) == 0 {
+		return nil
+	}
+	tld := topLevelDomain(h)
+	for _, allowed := range allowedTLDs {
+		if tld == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("top-level domain .%s is not allowed, use one of .%s", tld, strings.Join(allowedTLDs, ", ."))
+}
+
+// RouteWarnings returns problems that do not prevent saving a route, such
+// as hostnames on HSTS-preloaded TLDs.
+func RouteWarnings(r models.Route) []FieldError {
+	var warnings []FieldError
+	for i, h := range Hostnames(r) {
+		tld := topLevelDomain(h)
+		if !hstsPreloadedTLDs[tld] {
+			continue
+		}
+		field := "domain"
+		if i > 0 {
+			field = "aliases"
+		}
+		msg := fmt.Sprintf(".%s is on the HSTS preload list: browsers only connect over HTTPS and the name may exist publicly; .test or .localhost are reserved for local use", tld)
+		if r.TLSMode != models.TLSModeInternal {
+			msg = fmt.Sprintf(".%s is on the HSTS preload list: browsers refuse plain HTTP for %s, enable HTTPS or use .test or .localhost", tld, h)
+		}
+		warnings = append(warnings, FieldError{Field: field, Message: msg})
+	}
+	return warnings
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"devproxy/internal/models"
+)
+
+// errorPageRouteVar is the Caddy variable holding the ID of the route that
+// handled a request, so the error page knows which route failed.
+const errorPageRouteVar = "devproxy_route"
+
+// errorPagesAddress is the address Caddy reaches the error page server on.
+// Branded error pages are off when it is empty.
+var errorPagesAddress string
+
+// ConfigureErrorPages enables branded error pages. address is where Caddy
+// reaches the server started for ErrorPage handlers, e.g. "api:9301".
+func ConfigureErrorPages(address string) {
+	errorPagesAddress = address
+}
+
+// ErrorPagesEnabled reports whether branded error pages are configured.
+func ErrorPagesEnabled() bool {
+	return errorPagesAddress != ""
+}
+
+// errorPageStatuses are the statuses that get a branded page: the upstream
+// is unreachable, timed out or the route is in maintenance.
+var errorPageStatuses = []string{"502", "503", "504"}
+
+// maintenanceHandlers replace the handler chain of a route in maintenance.
+// With error pages the 503 is raised as an error so the branded page is
+// served; otherwise Caddy answers with a plain text page.
+func maintenanceHandlers() []map[string]interface{} {
+	if ErrorPagesEnabled() {
+		return []map[string]interface{}{{
+			"handler":     "error",
+			"status_code": 503,
+			"error":       "route is in maintenance",
+		}}
+	}
+	return []map[string]interface{}{{
+		"handler":     "static_response",
+		"status_code": 503,
+		"headers":     map[string][]string{"Content-Type": {"text/plain; charset=utf-8"}, "Retry-After": {"120"}},
+		"body":        "Service under maintenance",
+	}}
+}
+
+// errorPageVars tags a request with the route that handles it.
+func errorPageVars(r models.Route) map[string]interface{} {
+	return map[string]interface{}{
+		"handler":         "vars",
+		errorPageRouteVar: strconv.FormatInt(r.ID, 10),
+	}
+}
+
+// addErrorPages makes every server fetch the page for upstream errors from
+// the error page server. The request is rewritten to a GET carrying the
+// route and status; the page is answered with the original status.
+func addErrorPages(cfg *caddyConfig) {
+	for _, srv := range cfg.Apps.HTTP.Servers {
+		srv.Errors = &caddyErrors{Routes: []caddyRoute{{
+			Match: []map[string]interface{}{{
+				"vars": map[string][]string{"{http.error.status_code}": errorPageStatuses},
+			}},
+			Handle: []map[string]interface{}{
+				{
+					"handler": "rewrite",
+					"method":  "GET",
+					"uri":     "/error-page?route={http.vars." + errorPageRouteVar + "}&status={http.error.status_code}",
+				},
+				{
+					"handler":   "reverse_proxy",
+					"upstreams": []map[string]interface{}{{"dial": errorPagesAddress}},
+				},
+			},
+		}}}
+	}
+}
+
+// caddyfileErrorPageLines renders addErrorPages as a handle_errors block.
+func caddyfileErrorPageLines() []string {
+	return []string{
+		"handle_errors 502 503 504 {",
+		"    rewrite * /error-page?route={vars." + errorPageRouteVar + "}&status={err.status_code}",
+		"    method GET",
+		"    reverse_proxy " + errorPagesAddress,
+		"}",
+	}
+}
+
+// caddyfileMaintenanceLines renders maintenanceHandlers as directives.
+func caddyfileMaintenanceLines() []string {
+	if ErrorPagesEnabled() {
+		return []string{`error "route is in maintenance" 503`}
+	}
+	return []string{
+		"header Retry-After 120",
+		`respond "Service under maintenance" 503`,
+	}
+}
+
+// ErrorPageStatus parses the status passed to the error page, falling back
+// to 502 for anything that is not a 5xx code.
+func ErrorPageStatus(s string) int {
+	if n, err := strconv.Atoi(s); err == nil && n >= 500 && n <= 599 {
+		return n
+	}
+	return 502
+}
+
+// errorPageVarLine renders errorPageVars as a Caddyfile directive.
+func errorPageVarLine(r models.Route) string {
+	return fmt.Sprintf("vars %s %d", errorPageRouteVar, r.ID)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestErrorPages(t *testing.T) {
+	ConfigureErrorPages("api:9301")
+	defer ConfigureErrorPages("")
+
+	r := models.Route{ID: 7, Domain: "app.test", Target: "app:3000", AllowCIDRs: []string{"10.0.0.0/8"}}
+	handlers := routeHandlers(r)
+	if handlers[0]["handler"] != "vars" || handlers[0][errorPageRouteVar] != "7" {
+		t.Errorf("first handler = %v, want the route variable", handlers[0])
+	}
+	if last := handlers[len(handlers)-1]; last["handler"] != "reverse_proxy" {
+		t.Errorf("last handler = %v, want reverse_proxy", last["handler"])
+	}
+
+	r.Maintenance = true
+	handlers = routeHandlers(r)
+	access := accessHandlers(r)
+	if len(access) == 0 || len(handlers) != len(access)+2 || handlers[1]["handler"] != access[0]["handler"] {
+		t.Errorf("maintenance handlers = %v, want the access checks first", handlers)
+	}
+	if last := handlers[len(handlers)-1]; last["handler"] != "error" || last["status_code"] != 503 {
+		t.Errorf("last maintenance handler = %v, want a 503 error", last)
+	}
+
+	cfg := buildCaddyConfig([]models.Route{r})
+	errs := cfg.Apps.HTTP.Servers[caddyServerHTTP].Errors
+	if errs == nil || len(errs.Routes) != 1 {
+		t.Fatalf("errors = %+v, want one error route", errs)
+	}
+	if proxy := errs.Routes[0].Handle[1]; proxy["upstreams"].([]map[string]interface{})[0]["dial"] != "api:9301" {
+		t.Errorf("error route proxies to %v", proxy["upstreams"])
+	}
+
+	caddyfile := buildCaddyfile([]models.Route{r})
+	for _, want := range []string{"vars devproxy_route 7", `error "route is in maintenance" 503`, "handle_errors 502 503 504 {", "reverse_proxy api:9301"} {
+		if !strings.Contains(caddyfile, want) {
+			t.Errorf("Caddyfile missing %q:\n%s", want, caddyfile)
+		}
+	}
+	if !strings.Contains(caddyfile, "remote_ip") || strings.Index(caddyfile, "remote_ip") > strings.Index(caddyfile, "route is in maintenance") {
+		t.Errorf("Caddyfile of a route in maintenance must check access first:\n%s", caddyfile)
+	}
+}
+
+func TestMaintenanceWithoutErrorPages(t *testing.T) {
+	r := models.Route{ID: 1, Domain: "app.test", Target: "app:3000", Maintenance: true}
+	handlers := routeHandlers(r)
+	if len(handlers) != 1 || handlers[0]["handler"] != "static_response" || handlers[0]["status_code"] != 503 {
+		t.Errorf("handlers = %v, want a plain 503", handlers)
+	}
+	if cfg := buildCaddyConfig([]models.Route{r}); cfg.Apps.HTTP.Servers[caddyServerHTTP].Errors != nil {
+		t.Error("error routes added without an error page address")
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"devproxy/internal/models"
+	"devproxy/internal/version"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Export formats.
+const (
+	ExportJSON = "json"
+	ExportYAML = "yaml"
+)
+
+// importProjectColor is used for imported projects without a valid color.
+const importProjectColor = "#6b7280"
+
+var importProjectColorRe = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// exportMigrations upgrade a decoded export document by one schema version:
+// exportMigrations[v] turns version v into version v+1.
+var exportMigrations = []func(doc map[string]interface{}) error{
+	// Version 0 is the bare route array exported before documents were
+	// versioned. Its route fields are those of version 1.
+	func(doc map[string]interface{}) error {
+		doc["routes"] = doc["legacy_routes"]
+		delete(doc, "legacy_routes")
+		return nil
+	},
+}
+
+// NewExport builds an export document of the current schema version.
+func NewExport(routes []models.ImportRoute, projects []models.Project) models.ExportDocument {
+	doc := models.ExportDocument{
+		SchemaVersion:  models.ExportSchemaVersion,
+		BackendVersion: version.GetVersion(),
+		ExportedAt:     time.Now().UTC(),
+		Projects:       make([]models.ExportProject, 0, len(projects)),
+		Routes:         routes,
+	}
+	for _, p := range projects {
+		doc.Projects = append(doc.Projects, models.ExportProject{Name: p.Name, Color: p.Color, Description: p.Description})
+	}
+	return doc
+}
+
+// NormalizeExportFormat validates an export format; empty means JSON.
+func NormalizeExportFormat(format string) (string, error) {
+	switch format = strings.ToLower(strings.TrimSpace(format)); format {
+	case "", ExportJSON:
+		return ExportJSON, nil
+	case ExportYAML, "yml":
+		return ExportYAML, nil
+	}
+	return "", fmt.Errorf("invalid export format %q, use json or yaml", format)
+}
+
+// exportHeader keeps the metadata at the top of an encoded document.
+type exportHeader struct {
+	SchemaVersion  int         `json:"schema_version" yaml:"schema_version"`
+	BackendVersion string      `json:"backend_version" yaml:"backend_version"`
+	ExportedAt     string      `json:"exported_at" yaml:"exported_at"`
+	Projects       interface{} `json:"projects" yaml:"projects"`
+	Routes         interface{} `json:"routes" yaml:"routes"`
+	Settings       interface{} `json:"settings,omitempty" yaml:"settings,omitempty"`
+}
+
+// EncodeExport renders an export document as JSON or YAML. Empty objects,
+// such as the options of other route kinds, are left out.
+func EncodeExport(doc models.ExportDocument, format string) ([]byte, error) {
+	out := exportHeader{
+		SchemaVersion:  doc.SchemaVersion,
+		BackendVersion: doc.BackendVersion,
+		ExportedAt:     doc.ExportedAt.UTC().Format(time.RFC3339),
+	}
+	for _, part := range []struct {
+		dst *interface{}
+		src interface{}
+	}{{&out.Projects, doc.Projects}, {&out.Routes, doc.Routes}} {
+		data, err := json.Marshal(part.src)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, part.dst); err != nil {
+			return nil, err
+		}
+		*part.dst = pruneEmpty(*part.dst)
+	}
+	if len(doc.Settings) > 0 {
+		out.Settings = doc.Settings
+	}
+
+	if format == ExportYAML {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(out); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), enc.Close()
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// DecodeExport reads an export document in JSON or YAML, of the current or
+// an older schema version, and migrates it to the current version. It
+// returns the schema version the document was written with.
+func DecodeExport(data []byte) (models.ExportDocument, int, error) {
+	var doc models.ExportDocument
+	var tree interface{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		if err := json.Unmarshal(trimmed, &tree); err != nil {
+			return doc, 0, fmt.Errorf("invalid JSON: %v", err)
+		}
+	} else if err := yaml.Unmarshal(trimmed, &tree); err != nil {
+		return doc, 0, fmt.Errorf("invalid YAML: %v", err)
+	}
+
+	var fields map[string]interface{}
+	switch t := tree.(type) {
+	case []interface{}:
+		fields = map[string]interface{}{"schema_version": 0, "legacy_routes": t}
+	case map[string]interface{}:
+		fields = t
+	default:
+		return doc, 0, errors.New("expected an export document or a list of routes")
+	}
+
+	from, err := exportSchemaVersion(fields["schema_version"])
+	if err != nil {
+		return doc, 0, err
+	}
+	if from > models.ExportSchemaVersion {
+		return doc, from, fmt.Errorf("export schema version %d is newer than this backend supports (%d); update DevProxy", from, models.ExportSchemaVersion)
+	}
+	for v := from; v < models.ExportSchemaVersion; v++ {
+		if err := exportMigrations[v](fields); err != nil {
+			return doc, from, fmt.Errorf("migrating export from schema version %d: %v", v, err)
+		}
+	}
+	fields["schema_version"] = models.ExportSchemaVersion
+
+	// The exported_at and backend_version of old documents may be missing
+	// or malformed; they are informational only.
+	switch t := fields["exported_at"].(type) {
+	case time.Time:
+		fields["exported_at"] = t.Format(time.RFC3339)
+	case string:
+		if _, err := time.Parse(time.RFC3339, t); err != nil {
+			delete(fields, "exported_at")
+		}
+	default:
+		delete(fields, "exported_at")
+	}
+	if _, ok := fields["backend_version"].(string); !ok {
+		delete(fields, "backend_version")
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return doc, from, err
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return doc, from, fmt.Errorf("invalid export document: %v", err)
+	}
+	return doc, from, nil
+}
+
+// ImportProjects returns the projects an import needs: those listed in the
+// document plus any that routes refer to by name. Invalid colors fall back
+// to the default.
+func ImportProjects(doc models.ExportDocument) []models.ExportProject {
+	var projects []models.ExportProject
+	seen := make(map[string]bool)
+	add := func(p models.ExportProject) {
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" || seen[p.Name] {
+			return
+		}
+		seen[p.Name] = true
+		p.Description = strings.TrimSpace(p.Description)
+		p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+		if !importProjectColorRe.MatchString(p.Color) {
+			p.Color = importProjectColor
+		}
+		projects = append(projects, p)
+	}
+	for _, p := range doc.Projects {
+		add(p)
+	}
+	for _, r := range doc.Routes {
+		add(models.ExportProject{Name: r.Project})
+	}
+	return projects
+}
+
+// exportSchemaVersion reads the schema_version field of a document, which
+// decodes as a float from JSON and as an int from YAML.
+func exportSchemaVersion(v interface{}) (int, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, errors.New("export document has no schema_version")
+	case int:
+		if n >= 0 {
+			return n, nil
+		}
+	case float64:
+		if n >= 0 && n == float64(int(n)) {
+			return int(n), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid schema_version %v", v)
+}
+
+// pruneEmpty removes empty objects from a decoded JSON tree, recursively.
+func pruneEmpty(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			child = pruneEmpty(child)
+			if m, ok := child.(map[string]interface{}); ok && len(m) == 0 {
+				delete(t, k)
+				continue
+			}
+			t[k] = child
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = pruneEmpty(child)
+		}
+	}
+	return v
+}
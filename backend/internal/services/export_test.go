@@ -0,0 +1,89 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	doc := NewExport([]models.ImportRoute{
+		{Name: "app", Domain: "app.test", Target: "app-web-1:80", Enabled: true, Project: "shop",
+			BasicAuth: []models.BasicAuthUser{{Username: "dev", PasswordHash: "$2a$10$abc"}}},
+		{Name: "old", Domain: "old.test", Kind: models.RouteKindRedirect, Target: "https://new.test",
+			Redirect: models.RedirectOptions{Status: 301}},
+	}, []models.Project{{Name: "shop", Color: "#3b82f6"}})
+
+	for _, format := range []string{ExportJSON, ExportYAML} {
+		data, err := EncodeExport(doc, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if strings.Contains(string(data), "file_server") {
+			t.Errorf("%s: empty kind options were exported:\n%s", format, data)
+		}
+
+		got, from, err := DecodeExport(data)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if from != models.ExportSchemaVersion || got.BackendVersion != doc.BackendVersion || got.ExportedAt.IsZero() {
+			t.Errorf("%s: metadata = %d %q %v", format, from, got.BackendVersion, got.ExportedAt)
+		}
+		if len(got.Routes) != 2 || got.Routes[0].Project != "shop" || got.Routes[0].BasicAuth[0].PasswordHash != "$2a$10$abc" || got.Routes[1].Redirect.Status != 301 {
+			t.Errorf("%s: routes = %+v", format, got.Routes)
+		}
+		if len(got.Projects) != 1 || got.Projects[0].Color != "#3b82f6" {
+			t.Errorf("%s: projects = %+v", format, got.Projects)
+		}
+	}
+}
+
+func TestDecodeExportMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		routes  int
+		from    int
+		wantErr bool
+	}{
+		{"legacy array", `[{"name":"app","domain":"app.test","target":"app-web-1:80","enabled":true}]`, 1, 0, false},
+		{"legacy yaml", "- name: app\n  domain: app.test\n  target: app-web-1:80\n", 1, 0, false},
+		{"current", `{"schema_version":1,"routes":[{"name":"app","domain":"app.test"}]}`, 1, 1, false},
+		{"newer", `{"schema_version":99,"routes":[]}`, 0, 0, true},
+		{"unversioned object", `{"routes":[]}`, 0, 0, true},
+		{"scalar", `42`, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		doc, from, err := DecodeExport([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(doc.Routes) != tt.routes || from != tt.from || doc.SchemaVersion != models.ExportSchemaVersion {
+			t.Errorf("%s: got %d routes from version %d (now %d)", tt.name, len(doc.Routes), from, doc.SchemaVersion)
+		}
+	}
+}
+
+func TestImportProjects(t *testing.T) {
+	doc := models.ExportDocument{
+		Projects: []models.ExportProject{{Name: " shop ", Color: "#3B82F6"}, {Name: "bad", Color: "red"}},
+		Routes:   []models.ImportRoute{{Project: "shop"}, {Project: "blog"}, {}},
+	}
+	got := ImportProjects(doc)
+	want := []models.ExportProject{{Name: "shop", Color: "#3b82f6"}, {Name: "bad", Color: importProjectColor}, {Name: "blog", Color: importProjectColor}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("project %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"net"
+	"net/url"
+	"sort"
+
+	"devproxy/internal/models"
+	"devproxy/internal/version"
+)
+
+// HAR is an HTTP Archive 1.2 document
+// (http://www.softwareishard.com/blog/har-12-spec/).
+type HAR struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+	// Error is the non-standard field browsers use for failed requests.
+	Error string `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// ComposeHAR exports composer results as HAR, with the request through
+// Caddy and the direct request to the upstream as separate entries.
+func ComposeHAR(results ...models.ComposeResult) HAR {
+	h := HAR{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "DevProxy", Version: version.GetVersion()},
+		Entries: []harEntry{},
+	}}
+	for _, r := range results {
+		h.Log.Entries = append(h.Log.Entries,
+			harEntryFor(r.Request, r.Proxy, "Through Caddy"),
+			harEntryFor(r.Request, r.Direct, "Directly to the upstream"),
+		)
+	}
+	return h
+}
+
+func harEntryFor(req models.ComposeRequest, res models.ComposeResponse, comment string) harEntry {
+	e := harEntry{
+		StartedDateTime: res.StartedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+		Time:            res.Timings.Total,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         res.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(res.RequestHeaders),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(req.Body),
+		},
+		Response: harResponse{
+			Status:      res.Status,
+			StatusText:  res.StatusText,
+			HTTPVersion: res.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(res.Headers),
+			Content: harContent{
+				Size:     res.BodySize,
+				MimeType: firstHeader(res.Headers, "Content-Type"),
+				Text:     res.Body,
+				Encoding: res.BodyEncoding,
+			},
+			RedirectURL: firstHeader(res.Headers, "Location"),
+			HeadersSize: -1,
+			BodySize:    res.BodySize,
+		},
+		Timings: harTimings{
+			Blocked: -1,
+			DNS:     res.Timings.DNS,
+			Connect: res.Timings.Connect,
+			Send:    nonNegative(res.Timings.Send),
+			Wait:    nonNegative(res.Timings.TTFB),
+			Receive: nonNegative(res.Timings.Receive),
+			SSL:     res.Timings.TLS,
+		},
+		Comment: comment,
+		Error:   res.Error,
+	}
+	if req.Body != "" {
+		e.Request.PostData = &harPostData{MimeType: firstHeader(res.RequestHeaders, "Content-Type"), Text: req.Body}
+	}
+	if res.BodyTruncated {
+		e.Response.Content.Comment = "Body truncated"
+	}
+	// HAR counts the TLS handshake as part of connecting.
+	if e.Timings.Connect >= 0 && e.Timings.SSL > 0 {
+		e.Timings.Connect += e.Timings.SSL
+	}
+	if u, err := url.Parse(res.URL); err == nil {
+		for name, values := range u.Query() {
+			for _, v := range values {
+				e.Request.QueryString = append(e.Request.QueryString, harNameValue{name, v})
+			}
+		}
+		sort.Slice(e.Request.QueryString, func(i, j int) bool { return e.Request.QueryString[i].Name < e.Request.QueryString[j].Name })
+	}
+	if host, _, err := net.SplitHostPort(res.ServerAddress); err == nil {
+		e.ServerIPAddress = host
+	}
+	return e
+}
+
+func harHeaders(h map[string][]string) []harNameValue {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []harNameValue{}
+	for _, name := range names {
+		for _, v := range h[name] {
+			headers = append(headers, harNameValue{name, v})
+		}
+	}
+	return headers
+}
+
+func firstHeader(h map[string][]string, name string) string {
+	if values := h[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func nonNegative(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// managedHeaders are computed by the HTTP stack and cannot be changed by
+// header rules.
+var managedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// NormalizeHeaderRules validates route header rules and canonicalizes the
+// header names.
+func NormalizeHeaderRules(h models.HeaderRules) (models.HeaderRules, error) {
+	var err error
+	if h.Request, err = normalizeHeaderOps(h.Request, "request"); err != nil {
+		return h, err
+	}
+	if h.Response, err = normalizeHeaderOps(h.Response, "response"); err != nil {
+		return h, err
+	}
+	return h, nil
+}
+
+func normalizeHeaderOps(ops models.HeaderOps, side string) (models.HeaderOps, error) {
+	var out models.HeaderOps
+	seen := make(map[string]string)
+
+	normalizeMap := func(in map[string]string, op string) (map[string]string, error) {
+		if len(in) == 0 {
+			return nil, nil
+		}
+		m := make(map[string]string, len(in))
+		for name, value := range in {
+			key, err := normalizeHeaderName(name, side, op, seen)
+			if err != nil {
+				return nil, err
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("%s header %s: value must not contain line breaks", side, key)
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+
+	var err error
+	if out.Set, err = normalizeMap(ops.Set, "set"); err != nil {
+		return out, err
+	}
+	if out.Add, err = normalizeMap(ops.Add, "add"); err != nil {
+		return out, err
+	}
+	for _, name := range ops.Delete {
+		key, err := normalizeHeaderName(name, side, "delete", seen)
+		if err != nil {
+			return out, err
+		}
+		out.Delete = append(out.Delete, key)
+	}
+	sort.Strings(out.Delete)
+	return out, nil
+}
+
+// normalizeHeaderName canonicalizes a header name and rejects names that
+// are invalid, managed by the HTTP stack or already used by another
+// operation on the same side.
+func normalizeHeaderName(name, side, op string, seen map[string]string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !validHeaderName(name) {
+		return "", fmt.Errorf("%s header %q: invalid header name", side, name)
+	}
+	key := http.CanonicalHeaderKey(name)
+	if managedHeaders[key] {
+		return "", fmt.Errorf("%s header %s cannot be changed", side, key)
+	}
+	if prev, ok := seen[key]; ok {
+		return "", fmt.Errorf("%s header %s is used by both %s and %s", side, key, prev, op)
+	}
+	seen[key] = op
+	return key, nil
+}
+
+// validHeaderName reports whether name is an RFC 7230 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// caddyHeaders renders header rules for the "headers" option of Caddy's
+// reverse_proxy handler, or nil if there are none.
+func caddyHeaders(h models.HeaderRules) map[string]interface{} {
+	headers := make(map[string]interface{})
+	if ops := caddyHeaderOps(h.Request); ops != nil {
+		headers["request"] = ops
+	}
+	if ops := caddyHeaderOps(h.Response); ops != nil {
+		headers["response"] = ops
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+func caddyHeaderOps(ops models.HeaderOps) map[string]interface{} {
+	out := make(map[string]interface{})
+	values := func(m map[string]string) map[string][]string {
+		v := make(map[string][]string, len(m))
+		for name, value := range m {
+			v[name] = []string{value}
+		}
+		return v
+	}
+	if len(ops.Set) > 0 {
+		out["set"] = values(ops.Set)
+	}
+	if len(ops.Add) > 0 {
+		out["add"] = values(ops.Add)
+	}
+	if len(ops.Delete) > 0 {
+		out["delete"] = ops.Delete
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// caddyfileHeaderLines renders header rules as reverse_proxy subdirectives.
+func caddyfileHeaderLines(h models.HeaderRules) []string {
+	var lines []string
+	for _, side := range []struct {
+		directive string
+		ops       models.HeaderOps
+	}{{"header_up", h.Request}, {"header_down", h.Response}} {
+		for _, name := range sortedHeaderNames(side.ops.Set) {
+			lines = append(lines, fmt.Sprintf("%s %s %s", side.directive, name, caddyfileQuote(side.ops.Set[name])))
+		}
+		for _, name := range sortedHeaderNames(side.ops.Add) {
+			lines = append(lines, fmt.Sprintf("%s +%s %s", side.directive, name, caddyfileQuote(side.ops.Add[name])))
+		}
+		for _, name := range side.ops.Delete {
+			lines = append(lines, fmt.Sprintf("%s -%s", side.directive, name))
+		}
+	}
+	return lines
+}
+
+func sortedHeaderNames(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// caddyfileQuoter escapes the characters that could end a quoted
+// Caddyfile token early.
+var caddyfileQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// caddyfileQuote quotes a value as a single Caddyfile token. Values are
+// always quoted so newlines and braces in user input cannot open or close
+// blocks.
+func caddyfileQuote(s string) string {
+	return `"` + caddyfileQuoter.Replace(s) + `"`
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestNormalizeHeaderRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   models.HeaderRules
+		wantErr bool
+	}{
+		{"empty", models.HeaderRules{}, false},
+		{"set, add and delete", models.HeaderRules{
+			Request:  models.HeaderOps{Set: map[string]string{"host": "legacy.internal"}, Add: map[string]string{"x-debug-user": "dev"}},
+			Response: models.HeaderOps{Delete: []string{"server"}},
+		}, false},
+		{"invalid name", models.HeaderRules{Request: models.HeaderOps{Set: map[string]string{"X Debug": "1"}}}, true},
+		{"line break", models.HeaderRules{Request: models.HeaderOps{Set: map[string]string{"X-Debug": "1\r\nEvil: 1"}}}, true},
+		{"managed header", models.HeaderRules{Response: models.HeaderOps{Delete: []string{"content-length"}}}, true},
+		{"set and delete", models.HeaderRules{Response: models.HeaderOps{Set: map[string]string{"Server": "x"}, Delete: []string{"server"}}}, true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeHeaderRules(tt.rules)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.name == "set, add and delete" {
+			if got.Request.Set["Host"] != "legacy.internal" || got.Request.Add["X-Debug-User"] != "dev" || got.Response.Delete[0] != "Server" {
+				t.Errorf("%s: names not canonicalized: %+v", tt.name, got)
+			}
+		}
+	}
+}
+
+func TestRenderHeaderRules(t *testing.T) {
+	route := models.Route{ID: 1, Domain: "app.test", Target: "app:80", Headers: models.HeaderRules{
+		Request: models.HeaderOps{
+			Set: map[string]string{"X-Forwarded-Prefix": "/app", "Host": "legacy.internal"},
+			Add: map[string]string{"X-Debug-User": "dev user"},
+		},
+		Response: models.HeaderOps{Delete: []string{"Server"}},
+	}}
+
+	proxy := routeHandlers(route)[0]
+	headers, ok := proxy["headers"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("reverse_proxy has no headers: %+v", proxy)
+	}
+	request := headers["request"].(map[string]interface{})
+	if set := request["set"].(map[string][]string); set["Host"][0] != "legacy.internal" {
+		t.Errorf("request set = %v", set)
+	}
+	if del := headers["response"].(map[string]interface{})["delete"].([]string); del[0] != "Server" {
+		t.Errorf("response delete = %v", del)
+	}
+
+	if plain := routeHandlers(models.Route{Target: "app:80"})[0]; plain["headers"] != nil {
+		t.Errorf("routes without rules should not set headers: %+v", plain)
+	}
+
+	content := buildCaddyfile([]models.Route{route})
+	want := "    reverse_proxy app:80 {\n" +
+		"        header_up Host \"legacy.internal\"\n" +
+		"        header_up X-Forwarded-Prefix \"/app\"\n" +
+		"        header_up +X-Debug-User \"dev user\"\n" +
+		"        header_down -Server\n" +
+		"    }\n"
+	if !strings.Contains(content, want) {
+		t.Errorf("Caddyfile missing header rules:\n%s", content)
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
@@ -9,22 +10,39 @@ import (
 	"time"
 
 	"devproxy/internal/database"
+	"devproxy/internal/events"
 	"devproxy/internal/models"
 )
 
 var (
 	healthCache    = make(map[int64]*models.HealthStatus)
+	healthProbes   = make(map[int64]*healthProbe)
 	healthCacheMux sync.RWMutex
 )
 
-// StartHealthChecker starts the background health check loop.
+// healthTick is how often the scheduler looks for routes due for a check.
+const healthTick = time.Second
+
+// healthBodyLimit caps how much of a response body is searched for
+// BodyContains.
+const healthBodyLimit = 1 << 20
+
+// healthProbe tracks the schedule of one route's health check.
+type healthProbe struct {
+	next    time.Time
+	running bool
+}
+
+// StartHealthChecker starts the background health check loop. Each route is
+// checked on its own interval; checks run concurrently so a slow upstream
+// does not delay the others.
 func StartHealthChecker() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(healthTick)
 	defer ticker.Stop()
 
-	checkHealth()
-	for range ticker.C {
-		checkHealth()
+	checkHealth(time.Now())
+	for now := range ticker.C {
+		checkHealth(now)
 	}
 }
 
@@ -40,21 +58,129 @@ func GetHealthStatuses() []*models.HealthStatus {
 	return statuses
 }
 
-func checkHealth() {
+// GetRouteHealth returns the cached health status of a route, if it has
+// been checked.
+func GetRouteHealth(routeID int64) (models.HealthStatus, bool) {
+	healthCacheMux.RLock()
+	defer healthCacheMux.RUnlock()
+
+	status, ok := healthCache[routeID]
+	if !ok {
+		return models.HealthStatus{}, false
+	}
+	return *status, true
+}
+
+// checkHealth starts the checks that are due and forgets routes that were
+// disabled, deleted or changed to a kind that does not proxy.
+func checkHealth(now time.Time) {
 	routes, err := database.GetEnabledRoutes()
 	if err != nil {
 		return
 	}
 
+	healthCacheMux.Lock()
+	defer healthCacheMux.Unlock()
+
+	active := make(map[int64]bool, len(routes))
 	for _, route := range routes {
-		status := checkRouteHealth(route)
-		healthCacheMux.Lock()
-		healthCache[route.ID] = status
+		// Redirects, static responses and file servers have no upstream
+		// to probe.
+		if !IsProxyRoute(route) {
+			continue
+		}
+		active[route.ID] = true
+		probe := healthProbes[route.ID]
+		if probe == nil {
+			probe = &healthProbe{}
+			healthProbes[route.ID] = probe
+		}
+		if probe.running || now.Before(probe.next) {
+			continue
+		}
+
+		cfg := resolveHealthCheck(route.HealthCheck)
+		probe.running = true
+		probe.next = now.Add(cfg.Interval)
+		go runHealthCheck(route, cfg)
+	}
+
+	for id := range healthProbes {
+		if !active[id] {
+			if status := healthCache[id]; status != nil {
+				forgetRouteMetrics(id, status.Domain)
+			}
+			delete(healthProbes, id)
+			delete(healthCache, id)
+		}
+	}
+}
+
+func runHealthCheck(route models.Route, cfg healthCheckConfig) {
+	status := checkRouteHealth(route, cfg)
+	observeHealthCheck(status)
+
+	healthCacheMux.Lock()
+	probe, active := healthProbes[route.ID]
+	if !active {
 		healthCacheMux.Unlock()
+		return
+	}
+	probe.running = false
+	prev, known := healthCache[route.ID]
+	passed := status.Healthy
+	applyHealthThresholds(prev, status, cfg)
+	healthCache[route.ID] = status
+	healthCacheMux.Unlock()
+
+	changed := !known || prev.Healthy != status.Healthy
+	if recordHealthResult(route, status, passed, changed) {
+		events.Publish(events.HealthChanged, status)
 	}
 }
 
-func checkRouteHealth(route models.Route) *models.HealthStatus {
+// applyHealthThresholds updates the consecutive result counters of status,
+// which holds a fresh probe result, and decides whether the reported state
+// flips. The first result of a route is taken as is. State tells a probe
+// below the threshold apart from a settled state.
+func applyHealthThresholds(prev, status *models.HealthStatus, cfg healthCheckConfig) {
+	passed := status.Healthy
+	if prev != nil {
+		status.ConsecutiveFailures = prev.ConsecutiveFailures
+		status.ConsecutiveSuccesses = prev.ConsecutiveSuccesses
+	}
+	if passed {
+		status.ConsecutiveSuccesses++
+		status.ConsecutiveFailures = 0
+	} else {
+		status.ConsecutiveFailures++
+		status.ConsecutiveSuccesses = 0
+	}
+
+	switch {
+	case prev == nil:
+		status.Healthy = passed
+	case passed:
+		status.Healthy = prev.Healthy || status.ConsecutiveSuccesses >= cfg.SuccessThreshold
+	default:
+		status.Healthy = prev.Healthy && status.ConsecutiveFailures < cfg.FailureThreshold
+	}
+
+	switch {
+	case status.Healthy && passed:
+		status.State = models.HealthStateHealthy
+	case status.Healthy:
+		status.State = models.HealthStateDegraded
+	case passed:
+		status.State = models.HealthStateRecovering
+	default:
+		status.State = models.HealthStateUnhealthy
+	}
+}
+
+// checkRouteHealth probes a route's upstream once. Healthy reflects only
+// this probe; thresholds are applied by the caller.
+func checkRouteHealth(route models.Route, cfg healthCheckConfig) *models.HealthStatus {
 	status := &models.HealthStatus{
 		RouteID:   route.ID,
 		Domain:    route.Domain,
@@ -63,15 +189,17 @@ func checkRouteHealth(route models.Route) *models.HealthStatus {
 	}
 
 	// Prepare target URL
-	targetURL := route.Target
-	if !strings.HasPrefix(targetURL, "http") {
-		targetURL = "http://" + targetURL
+	dial, useTLS := upstreamDial(route.Target)
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
 	}
+	targetURL := scheme + "://" + dial + cfg.Path
 
 	// Extract hostname
-	hostname := route.Target
-	if colonIdx := strings.Index(hostname, ":"); colonIdx > 0 {
-		hostname = hostname[:colonIdx]
+	hostname, _, err := net.SplitHostPort(dial)
+	if err != nil {
+		hostname = dial
 	}
 
 	// DNS resolution check
@@ -92,14 +220,22 @@ func checkRouteHealth(route models.Route) *models.HealthStatus {
 
 	// HTTP connection check
 	client := &http.Client{
-		Timeout: 5 * time.Second,
+		Timeout: cfg.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
 
+	req, err := http.NewRequest(cfg.Method, targetURL, nil)
+	if err != nil {
+		status.Healthy = false
+		status.ErrorType = "connection_error"
+		status.Error = err.Error()
+		return status
+	}
+
 	start := time.Now()
-	resp, err := client.Get(targetURL)
+	resp, err := client.Do(req)
 	status.ResponseTime = time.Since(start).Milliseconds()
 
 	if err != nil {
@@ -112,19 +248,36 @@ func checkRouteHealth(route models.Route) *models.HealthStatus {
 
 	status.StatusCode = resp.StatusCode
 
-	if resp.StatusCode >= 500 {
+	switch {
+	case !statusExpected(resp.StatusCode, cfg.Expected) && resp.StatusCode >= 500:
 		status.Healthy = false
 		status.ErrorType = "server_error"
 		status.Error = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 		status.Tip = "Server returned an error. Check application logs inside the container."
-	} else {
-		// 2xx, 3xx, and 4xx are considered healthy (4xx often expected for health checks)
+	case !statusExpected(resp.StatusCode, cfg.Expected):
+		status.Healthy = false
+		status.ErrorType = "unexpected_status"
+		status.Error = fmt.Sprintf("HTTP %d on %s %s is not an expected status", resp.StatusCode, cfg.Method, cfg.Path)
+		status.Tip = "Check the health check path and expected status codes of the route."
+	case cfg.BodyContains != "" && !bodyContains(resp.Body, cfg.BodyContains):
+		status.Healthy = false
+		status.ErrorType = "body_mismatch"
+		status.Error = fmt.Sprintf("Response of %s %s does not contain %q", cfg.Method, cfg.Path, cfg.BodyContains)
+		status.Tip = "The upstream answered, but not with the expected content. Check the health check path."
+	default:
 		status.Healthy = true
 	}
 
 	return status
 }
 
+// bodyContains reads up to healthBodyLimit bytes of body and searches them
+// for s.
+func bodyContains(body io.Reader, s string) bool {
+	data, _ := io.ReadAll(io.LimitReader(body, healthBodyLimit))
+	return strings.Contains(string(data), s)
+}
+
 func categorizeError(status *models.HealthStatus, errStr string) {
 	switch {
 	case strings.Contains(errStr, "connection refused"):
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestNormalizeHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		hc      models.HealthCheck
+		wantErr bool
+	}{
+		{"defaults", models.HealthCheck{}, false},
+		{"full", models.HealthCheck{Path: "/healthz", Method: "head", ExpectedStatus: "200-299, 401", TimeoutSeconds: 10, IntervalSeconds: 15, FailureThreshold: 3, SuccessThreshold: 2}, false},
+		{"bad method", models.HealthCheck{Method: "DELETE"}, true},
+		{"bad status", models.HealthCheck{ExpectedStatus: "2xx"}, true},
+		{"reversed range", models.HealthCheck{ExpectedStatus: "299-200"}, true},
+		{"status out of range", models.HealthCheck{ExpectedStatus: "700"}, true},
+		{"interval too short", models.HealthCheck{IntervalSeconds: 1}, true},
+		{"timeout above interval", models.HealthCheck{TimeoutSeconds: 20, IntervalSeconds: 10}, true},
+		{"negative threshold", models.HealthCheck{FailureThreshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		_, err := NormalizeHealthCheck(tt.hc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: NormalizeHealthCheck() error = %v; wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	hc, _ := NormalizeHealthCheck(models.HealthCheck{Path: "healthz", Method: "head", ExpectedStatus: "200 - 299"})
+	if hc.Path != "/healthz" || hc.Method != http.MethodHead || hc.ExpectedStatus != "200-299" {
+		t.Errorf("NormalizeHealthCheck() = %+v", hc)
+	}
+}
+
+func TestApplyHealthThresholds(t *testing.T) {
+	cfg := resolveHealthCheck(models.HealthCheck{FailureThreshold: 3, SuccessThreshold: 2})
+
+	var prev *models.HealthStatus
+	results := []bool{true, false, false, true, false, false, false, true, true}
+	expected := []bool{true, true, true, true, true, true, false, false, true}
+	states := []string{"healthy", "degraded", "degraded", "healthy", "degraded", "degraded", "unhealthy", "recovering", "healthy"}
+	for i, passed := range results {
+		status := &models.HealthStatus{Healthy: passed}
+		applyHealthThresholds(prev, status, cfg)
+		if status.Healthy != expected[i] || status.State != states[i] {
+			t.Errorf("probe %d: Healthy = %v, State = %q; want %v, %q (failures %d, successes %d)",
+				i, status.Healthy, status.State, expected[i], states[i], status.ConsecutiveFailures, status.ConsecutiveSuccesses)
+		}
+		prev = status
+	}
+}
+
+func TestCheckRouteHealth(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("status: ok"))
+	}))
+	defer upstream.Close()
+	target := strings.TrimPrefix(upstream.URL, "http://")
+
+	tests := []struct {
+		name      string
+		hc        models.HealthCheck
+		healthy   bool
+		errorType string
+	}{
+		{"default treats 404 as healthy", models.HealthCheck{}, true, ""},
+		{"expected status", models.HealthCheck{ExpectedStatus: "200"}, false, "unexpected_status"},
+		{"health path", models.HealthCheck{Path: "/healthz", ExpectedStatus: "200"}, true, ""},
+		{"body match", models.HealthCheck{Path: "/healthz", BodyContains: "ok"}, true, ""},
+		{"body mismatch", models.HealthCheck{Path: "/healthz", BodyContains: "ready"}, false, "body_mismatch"},
+	}
+
+	for _, tt := range tests {
+		route := models.Route{ID: 1, Domain: "app.test", Target: target, HealthCheck: tt.hc}
+		status := checkRouteHealth(route, resolveHealthCheck(tt.hc))
+		if status.Healthy != tt.healthy || status.ErrorType != tt.errorType {
+			t.Errorf("%s: Healthy = %v, ErrorType = %q (%s); want %v, %q",
+				tt.name, status.Healthy, status.ErrorType, status.Error, tt.healthy, tt.errorType)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+// Health check defaults, used for fields a route leaves empty. Any status
+// below 500 counts as healthy because many apps answer "/" with a redirect
+// or a 404.
+const (
+	defaultHealthPath     = "/"
+	defaultHealthMethod   = http.MethodGet
+	defaultHealthStatus   = "100-499"
+	defaultHealthTimeout  = 5 * time.Second
+	defaultHealthInterval = 30 * time.Second
+)
+
+// Limits for user-supplied health check settings.
+const (
+	minHealthInterval  = 5
+	maxHealthInterval  = 3600
+	maxHealthTimeout   = 60
+	maxHealthThreshold = 100
+)
+
+var healthMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodOptions: true,
+}
+
+// statusRange is an inclusive range of HTTP status codes.
+type statusRange struct {
+	Min, Max int
+}
+
+// healthCheckConfig is a route's health check with defaults applied.
+type healthCheckConfig struct {
+	Path             string
+	Method           string
+	Expected         []statusRange
+	BodyContains     string
+	Timeout          time.Duration
+	Interval         time.Duration
+	FailureThreshold int
+	SuccessThreshold int
+}
+
+// NormalizeHealthCheck validates a route health check and cleans it in
+// place: the method is uppercased and the path gets a leading slash.
+func NormalizeHealthCheck(hc models.HealthCheck) (models.HealthCheck, error) {
+	hc.Path = strings.TrimSpace(hc.Path)
+	if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+		hc.Path = "/" + hc.Path
+	}
+	if strings.ContainsAny(hc.Path, " \t#") {
+		return hc, fmt.Errorf("invalid health check path %q", hc.Path)
+	}
+
+	hc.Method = strings.ToUpper(strings.TrimSpace(hc.Method))
+	if hc.Method != "" && !healthMethods[hc.Method] {
+		return hc, fmt.Errorf("health check method must be GET, HEAD, POST or OPTIONS, not %q", hc.Method)
+	}
+
+	hc.ExpectedStatus = strings.ReplaceAll(strings.TrimSpace(hc.ExpectedStatus), " ", "")
+	if hc.ExpectedStatus != "" {
+		if _, err := parseStatusRanges(hc.ExpectedStatus); err != nil {
+			return hc, err
+		}
+	}
+
+	if hc.TimeoutSeconds < 0 || hc.TimeoutSeconds > maxHealthTimeout {
+		return hc, fmt.Errorf("health check timeout must be between 1 and %d seconds", maxHealthTimeout)
+	}
+	if hc.IntervalSeconds != 0 && (hc.IntervalSeconds < minHealthInterval || hc.IntervalSeconds > maxHealthInterval) {
+		return hc, fmt.Errorf("health check interval must be between %d and %d seconds", minHealthInterval, maxHealthInterval)
+	}
+	cfg := resolveHealthCheck(hc)
+	if cfg.Timeout > cfg.Interval {
+		return hc, fmt.Errorf("health check timeout (%s) must not exceed the interval (%s)", cfg.Timeout, cfg.Interval)
+	}
+
+	if hc.FailureThreshold < 0 || hc.FailureThreshold > maxHealthThreshold ||
+		hc.SuccessThreshold < 0 || hc.SuccessThreshold > maxHealthThreshold {
+		return hc, fmt.Errorf("health check thresholds must be between 1 and %d", maxHealthThreshold)
+	}
+	return hc, nil
+}
+
+// resolveHealthCheck applies the defaults to a route health check. Invalid
+// values, which validation normally prevents, also fall back to defaults.
+func resolveHealthCheck(hc models.HealthCheck) healthCheckConfig {
+	cfg := healthCheckConfig{
+		Path:             hc.Path,
+		Method:           hc.Method,
+		BodyContains:     hc.BodyContains,
+		Timeout:          time.Duration(hc.TimeoutSeconds) * time.Second,
+		Interval:         time.Duration(hc.IntervalSeconds) * time.Second,
+		FailureThreshold: hc.FailureThreshold,
+		SuccessThreshold: hc.SuccessThreshold,
+	}
+	if cfg.Path == "" {
+		cfg.Path = defaultHealthPath
+	}
+	if !healthMethods[cfg.Method] {
+		cfg.Method = defaultHealthMethod
+	}
+	var err error
+	if cfg.Expected, err = parseStatusRanges(hc.ExpectedStatus); err != nil || hc.ExpectedStatus == "" {
+		cfg.Expected, _ = parseStatusRanges(defaultHealthStatus)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHealthTimeout
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultHealthInterval
+	}
+	if cfg.FailureThreshold < 1 {
+		cfg.FailureThreshold = 1
+	}
+	if cfg.SuccessThreshold < 1 {
+		cfg.SuccessThreshold = 1
+	}
+	return cfg
+}
+
+// parseStatusRanges parses a list such as "200-299,301,401".
+func parseStatusRanges(spec string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		min, err := parseStatusCode(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected status %q", part)
+		}
+		max := min
+		if isRange {
+			if max, err = parseStatusCode(hi); err != nil || max < min {
+				return nil, fmt.Errorf("invalid expected status %q", part)
+			}
+		}
+		ranges = append(ranges, statusRange{min, max})
+	}
+	return ranges, nil
+}
+
+func parseStatusCode(s string) (int, error) {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q", s)
+	}
+	return code, nil
+}
+
+// statusExpected reports whether code falls into one of the ranges.
+func statusExpected(code int, ranges []statusRange) bool {
+	for _, r := range ranges {
+		if code >= r.Min && code <= r.Max {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+)
+
+// Health history retention. Raw probe results are kept for rawRetention and
+// then downsampled into rollups of rollupInterval, which are kept together
+// with the transitions for retention.
+var (
+	healthRawRetention   = 24 * time.Hour
+	healthRetention      = 30 * 24 * time.Hour
+	healthRollupInterval = 5 * time.Minute
+)
+
+// maxHealthPoints caps the number of chart points of a history request.
+const maxHealthPoints = 1000
+
+// latencyBounds are the upper bounds in milliseconds of the latency
+// histogram kept in rollups. The last bucket counts everything slower.
+var latencyBounds = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// ConfigureHealthHistory sets the retention of health history. Zero values
+// keep the defaults.
+func ConfigureHealthHistory(rawRetention, retention, rollupInterval time.Duration) {
+	if rawRetention > 0 {
+		healthRawRetention = rawRetention
+	}
+	if retention > 0 {
+		healthRetention = retention
+	}
+	if rollupInterval > 0 {
+		healthRollupInterval = rollupInterval
+	}
+	if healthRetention < healthRawRetention {
+		healthRetention = healthRawRetention
+	}
+}
+
+// ParseDuration is time.ParseDuration with support for days, e.g. "30d".
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// StartHealthHistoryCompactor periodically downsamples and prunes the
+// health history.
+func StartHealthHistoryCompactor() {
+	ticker := time.NewTicker(healthRollupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := compactHealthHistory(time.Now()); err != nil {
+			log.Printf("Compacting health history failed: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// compactHealthHistory rolls up raw results older than the raw retention.
+// The cutoff is aligned to the rollup interval so that a bucket is always
+// rolled up in one go.
+func compactHealthHistory(now time.Time) error {
+	cutoff := now.Add(-healthRawRetention).Truncate(healthRollupInterval)
+	results, err := database.GetHealthResults(0, time.Time{}, cutoff)
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 {
+		if err := database.RollupHealthResults(cutoff, rollupHealthResults(results, healthRollupInterval)); err != nil {
+			return err
+		}
+	}
+	return database.PruneHealthHistory(now.Add(-healthRetention))
+}
+
+// rollupHealthResults aggregates results into buckets of interval per route.
+func rollupHealthResults(results []models.HealthResult, interval time.Duration) []models.HealthRollup {
+	type key struct {
+		routeID int64
+		start   time.Time
+	}
+	index := make(map[key]int)
+	var rollups []models.HealthRollup
+	for _, r := range results {
+		k := key{r.RouteID, r.CheckedAt.UTC().Truncate(interval)}
+		i, ok := index[k]
+		if !ok {
+			i = len(rollups)
+			index[k] = i
+			rollups = append(rollups, models.HealthRollup{
+				RouteID:       k.routeID,
+				BucketStart:   k.start,
+				BucketSeconds: int(interval.Seconds()),
+				Latency:       make([]int64, len(latencyBounds)+1),
+			})
+		}
+		rollups[i].Checks++
+		if r.Passed {
+			rollups[i].Passed++
+		}
+		if r.StatusCode != 0 {
+			rollups[i].Latency[latencyBucket(r.ResponseTime)]++
+		}
+	}
+	return rollups
+}
+
+// recordHealthResult persists a probe and, if the reported state changed,
+// a transition. passed is the outcome of the probe before thresholds. It
+// reports whether the probe was a transition.
+func recordHealthResult(route models.Route, status *models.HealthStatus, passed bool, changed bool) bool {
+	checkedAt, err := time.Parse(time.RFC3339, status.LastCheck)
+	if err != nil {
+		checkedAt = time.Now()
+	}
+	if err := database.CreateHealthResult(models.HealthResult{
+		RouteID:      route.ID,
+		CheckedAt:    checkedAt,
+		Passed:       passed,
+		Healthy:      status.Healthy,
+		StatusCode:   status.StatusCode,
+		ResponseTime: status.ResponseTime,
+		ErrorType:    status.ErrorType,
+	}); err != nil {
+		log.Printf("Error storing health result: %v", err)
+	}
+
+	if !changed {
+		return false
+	}
+	// After a restart the first result only counts as a transition if it
+	// differs from the last stored one.
+	if last, err := database.GetLastHealthTransition(route.ID); err == nil && last.Healthy == status.Healthy {
+		return false
+	} else if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error reading health transitions: %v", err)
+	}
+	t := models.HealthTransition{
+		RouteID: route.ID,
+		Domain:  route.Domain,
+		Healthy: status.Healthy,
+		At:      checkedAt,
+	}
+	if !status.Healthy {
+		t.ErrorType = status.ErrorType
+		t.Error = status.Error
+	}
+	if err := database.CreateHealthTransition(&t); err != nil {
+		log.Printf("Error storing health transition: %v", err)
+	}
+	return true
+}
+
+// GetHealthHistories summarises the health of every route between from and
+// to.
+func GetHealthHistories(from, to time.Time) ([]models.HealthHistory, error) {
+	routes, err := database.GetAllRoutes()
+	if err != nil {
+		return nil, err
+	}
+	results, err := database.GetHealthResults(0, from, to)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := database.GetHealthRollups(0, from, to)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := database.GetHealthTransitions(0, from, to, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[int64]*healthStats)
+	counts := make(map[int64]int)
+	get := func(id int64) *healthStats {
+		if stats[id] == nil {
+			stats[id] = &healthStats{}
+		}
+		return stats[id]
+	}
+	for _, r := range results {
+		get(r.RouteID).addResult(r)
+	}
+	for _, r := range rollups {
+		get(r.RouteID).addRollup(r)
+	}
+	for _, t := range transitions {
+		counts[t.RouteID]++
+	}
+
+	histories := []models.HealthHistory{}
+	for _, route := range routes {
+		h := models.HealthHistory{RouteID: route.ID, Name: route.Name, Domain: route.Domain, From: from, To: to, TransitionCount: counts[route.ID]}
+		if s := stats[route.ID]; s != nil {
+			h.Checks, h.UptimePercent, h.P50, h.P95 = s.summary()
+		}
+		histories = append(histories, h)
+	}
+	return histories, nil
+}
+
+// GetHealthHistory returns a route's health between from and to, including
+// its transitions and chart points of step. A zero step picks one that
+// yields about 100 points.
+func GetHealthHistory(route models.Route, from, to time.Time, step time.Duration) (models.HealthHistory, error) {
+	h := models.HealthHistory{RouteID: route.ID, Name: route.Name, Domain: route.Domain, From: from, To: to}
+
+	results, err := database.GetHealthResults(route.ID, from, to)
+	if err != nil {
+		return h, err
+	}
+	rollups, err := database.GetHealthRollups(route.ID, from, to)
+	if err != nil {
+		return h, err
+	}
+	if h.Transitions, err = database.GetHealthTransitions(route.ID, from, to, -1); err != nil {
+		return h, err
+	}
+	h.TransitionCount = len(h.Transitions)
+
+	window := to.Sub(from)
+	if step <= 0 {
+		step = (window / 100).Truncate(time.Minute)
+	}
+	if step < time.Minute {
+		step = time.Minute
+	}
+	if window/step > maxHealthPoints {
+		step = window / maxHealthPoints
+	}
+	n := int(window / step)
+	if window%step != 0 {
+		n++
+	}
+	points := make([]healthStats, n)
+	pointIndex := func(t time.Time) int {
+		i := int(t.Sub(from) / step)
+		if i >= n {
+			i = n - 1
+		}
+		return i
+	}
+
+	var total healthStats
+	for _, r := range results {
+		total.addResult(r)
+		points[pointIndex(r.CheckedAt)].addResult(r)
+	}
+	for _, r := range rollups {
+		total.addRollup(r)
+		points[pointIndex(r.BucketStart)].addRollup(r)
+	}
+	h.Checks, h.UptimePercent, h.P50, h.P95 = total.summary()
+
+	h.Points = make([]models.HealthPoint, n)
+	for i := range points {
+		p := models.HealthPoint{Time: from.Add(time.Duration(i) * step)}
+		p.Checks, p.UptimePercent, p.P50, p.P95 = points[i].summary()
+		h.Points[i] = p
+	}
+	return h, nil
+}
+
+// healthStats accumulates probe results and rollups. Response times of raw
+// results are kept exactly as long as no rollup is involved; otherwise
+// percentiles are estimated from the latency histogram.
+type healthStats struct {
+	checks, passed int
+	latencies      []int64
+	histogram      []int64
+}
+
+func (s *healthStats) addResult(r models.HealthResult) {
+	s.checks++
+	if r.Passed {
+		s.passed++
+	}
+	if r.StatusCode != 0 {
+		s.latencies = append(s.latencies, r.ResponseTime)
+	}
+}
+
+func (s *healthStats) addRollup(r models.HealthRollup) {
+	s.checks += r.Checks
+	s.passed += r.Passed
+	if s.histogram == nil {
+		s.histogram = make([]int64, len(latencyBounds)+1)
+	}
+	for i, n := range r.Latency {
+		if i < len(s.histogram) {
+			s.histogram[i] += n
+		}
+	}
+}
+
+func (s *healthStats) summary() (checks int, uptime float64, p50, p95 int64) {
+	if s.checks > 0 {
+		uptime = float64(s.passed) * 100 / float64(s.checks)
+	}
+	return s.checks, uptime, s.percentile(50), s.percentile(95)
+}
+
+func (s *healthStats) percentile(p float64) int64 {
+	if s.histogram == nil {
+		if len(s.latencies) == 0 {
+			return 0
+		}
+		sorted := append([]int64(nil), s.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		rank := int(p/100*float64(len(sorted))+0.5) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(sorted) {
+			rank = len(sorted) - 1
+		}
+		return sorted[rank]
+	}
+
+	hist := append([]int64(nil), s.histogram...)
+	var total int64
+	for _, ms := range s.latencies {
+		hist[latencyBucket(ms)]++
+	}
+	for _, n := range hist {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+
+	// Interpolate linearly within the bucket holding the rank.
+	rank := p / 100 * float64(total)
+	var seen int64
+	for i, n := range hist {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		lower := int64(0)
+		if i > 0 {
+			lower = latencyBounds[i-1]
+		}
+		if i == len(latencyBounds) {
+			return lower
+		}
+		fraction := (rank - float64(seen)) / float64(n)
+		return lower + int64(fraction*float64(latencyBounds[i]-lower))
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// latencyBucket returns the histogram bucket of a response time.
+func latencyBucket(ms int64) int {
+	return sort.Search(len(latencyBounds), func(i int) bool { return ms <= latencyBounds[i] })
+}
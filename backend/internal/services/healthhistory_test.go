@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+)
+
+func TestHealthStatsPercentile(t *testing.T) {
+	var exact healthStats
+	for _, ms := range []int64{40, 10, 30, 20, 100} {
+		exact.addResult(models.HealthResult{Passed: true, StatusCode: 200, ResponseTime: ms})
+	}
+	exact.addResult(models.HealthResult{ErrorType: "dns_failure"})
+
+	checks, uptime, p50, p95 := exact.summary()
+	if checks != 6 || int(uptime) != 83 || p50 != 30 || p95 != 100 {
+		t.Errorf("summary() = %d, %.1f, %d, %d; want 6, 83.3, 30, 100", checks, uptime, p50, p95)
+	}
+
+	// With rollups involved, percentiles come from the histogram.
+	var approx healthStats
+	approx.addRollup(models.HealthRollup{Checks: 10, Passed: 10, Latency: []int64{0, 0, 0, 10}})
+	if _, uptime, p50, _ := approx.summary(); uptime != 100 || p50 <= 25 || p50 > 50 {
+		t.Errorf("summary() uptime = %.1f, p50 = %d; want 100 and p50 in (25, 50]", uptime, p50)
+	}
+}
+
+func TestCompactHealthHistory(t *testing.T) {
+	newTestEnv(t, nil)
+
+	r := models.Route{Name: "app", Domain: "app.test", Target: "app-web-1:80", Enabled: true}
+	if err := database.CreateRoute(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-30 * time.Hour)
+	for i := 0; i < 10; i++ {
+		database.CreateHealthResult(models.HealthResult{
+			RouteID:      r.ID,
+			CheckedAt:    old.Add(time.Duration(i) * 30 * time.Second),
+			Passed:       i < 8,
+			Healthy:      i < 9,
+			StatusCode:   200,
+			ResponseTime: 20,
+		})
+	}
+	database.CreateHealthResult(models.HealthResult{RouteID: r.ID, CheckedAt: now.Add(-time.Hour), Passed: true, StatusCode: 200, ResponseTime: 40})
+	database.CreateHealthTransition(&models.HealthTransition{RouteID: r.ID, Healthy: false, At: old.Add(4 * time.Minute)})
+
+	if err := compactHealthHistory(now); err != nil {
+		t.Fatalf("compactHealthHistory() error = %v", err)
+	}
+
+	results, _ := database.GetHealthResults(r.ID, time.Time{}, now)
+	if len(results) != 1 {
+		t.Errorf("kept %d raw results; want 1", len(results))
+	}
+	rollups, _ := database.GetHealthRollups(r.ID, time.Time{}, now)
+	checks := 0
+	for _, rollup := range rollups {
+		checks += rollup.Checks
+	}
+	if checks != 10 {
+		t.Errorf("rollups hold %d checks; want 10", checks)
+	}
+
+	h, err := GetHealthHistory(r, now.Add(-48*time.Hour), now, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Checks != 11 || h.UptimePercent < 81 || h.UptimePercent > 82 {
+		t.Errorf("history checks = %d, uptime = %.1f; want 11, 81.8", h.Checks, h.UptimePercent)
+	}
+	if len(h.Points) != 48 || h.TransitionCount != 1 {
+		t.Errorf("history has %d points and %d transitions; want 48 and 1", len(h.Points), h.TransitionCount)
+	}
+
+	// Everything expires after the retention.
+	if err := compactHealthHistory(now.Add(healthRetention + 2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := GetHealthHistory(r, now.Add(-48*time.Hour), now.Add(time.Hour), 0); h.Checks != 0 || h.TransitionCount != 0 {
+		t.Errorf("history after retention has %d checks and %d transitions; want none", h.Checks, h.TransitionCount)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"devproxy/internal/models"
+
+	"golang.org/x/net/idna"
+)
+
+// HostnameConflictError reports a hostname claimed by routes on two
+// different domains.
+type HostnameConflictError struct {
+	Hostname string
+	Route    models.Route
+	Conflict models.Route
+}
+
+func (e *HostnameConflictError) Error() string {
+	return fmt.Sprintf("hostname %s is already used by route %q (id %d) on %s",
+		e.Hostname, e.Conflict.Name, e.Conflict.ID, e.Conflict.Domain)
+}
+
+// NormalizeHostname lowercases a domain or alias and checks that it is an
+// RFC 1123 hostname: dot-separated labels of letters, digits and inner
+// hyphens, each at most 63 characters, 253 in total. Unicode labels are
+// converted to punycode. A wildcard may only replace the whole leftmost
+// label ("*.shop.test"). Anything else, such as braces, whitespace or a
+// port, is rejected so it can never reach the Caddy config.
+func NormalizeHostname(h string) (string, error) {
+	h = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(h)), ".")
+	if h == "" {
+		return "", fmt.Errorf("hostname must not be empty")
+	}
+	if strings.Contains(h, "*") {
+		rest := strings.TrimPrefix(h, "*.")
+		if rest == h || rest == "" || strings.Contains(rest, "*") {
+			return "", fmt.Errorf("invalid wildcard %q: only a leading \"*.\" label is supported", h)
+		}
+	}
+
+	labels := strings.Split(h, ".")
+	for i, label := range labels {
+		if i == 0 && label == "*" {
+			continue
+		}
+		if !isASCII(label) {
+			ascii, err := idna.ToASCII(label)
+			if err != nil {
+				return "", fmt.Errorf("invalid hostname %q: %v", h, err)
+			}
+			label = ascii
+			labels[i] = ascii
+		}
+		if err := validateHostnameLabel(label); err != nil {
+			return "", fmt.Errorf("invalid hostname %q: %v", h, err)
+		}
+	}
+	h = strings.Join(labels, ".")
+	if len(h) > 253 {
+		return "", fmt.Errorf("invalid hostname %q: longer than 253 characters", h)
+	}
+	if net.ParseIP(h) != nil {
+		return "", fmt.Errorf("invalid hostname %q: IP addresses cannot be routed by name", h)
+	}
+	return h, nil
+}
+
+func validateHostnameLabel(label string) error {
+	if label == "" {
+		return fmt.Errorf("empty label")
+	}
+	if len(label) > 63 {
+		return fmt.Errorf("label %q is longer than 63 characters", label)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("label %q must not start or end with a hyphen", label)
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return fmt.Errorf("label %q contains %q; use letters, digits and hyphens", label, c)
+		}
+	}
+	return nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeAliases cleans a route's aliases, dropping duplicates and any
+// alias equal to the route's own domain.
+func NormalizeAliases(domain string, aliases []string) ([]string, error) {
+	seen := map[string]bool{domain: true}
+	result := []string{}
+	for _, a := range aliases {
+		if strings.TrimSpace(a) == "" {
+			continue
+		}
+		h, err := NormalizeHostname(a)
+		if err != nil {
+			return nil, err
+		}
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+// IsWildcard reports whether a hostname is a wildcard pattern.
+func IsWildcard(h string) bool {
+	return strings.HasPrefix(h, "*.")
+}
+
+// Hostnames returns the domain of a route followed by its aliases.
+func Hostnames(r models.Route) []string {
+	return append([]string{r.Domain}, r.Aliases...)
+}
+
+// FindHostnameConflict checks that none of the route's hostnames is served
+// by a route on another domain. Routes sharing the domain form one site and
+// may repeat each other's aliases.
+func FindHostnameConflict(r models.Route, others []models.Route) error {
+	for _, o := range others {
+		if o.ID == r.ID || o.Domain == r.Domain {
+			continue
+		}
+		taken := make(map[string]bool)
+		for _, h := range Hostnames(o) {
+			taken[h] = true
+		}
+		for _, h := range Hostnames(r) {
+			if taken[h] {
+				return &HostnameConflictError{Hostname: h, Route: r, Conflict: o}
+			}
+		}
+	}
+	return nil
+}
+
+// siteAddresses returns the unique hostnames served by a group of routes
+// sharing a domain, with the domain first.
+func siteAddresses(group []models.Route) []string {
+	var addrs []string
+	seen := make(map[string]bool)
+	for _, r := range group {
+		for _, h := range Hostnames(r) {
+			if !seen[h] {
+				seen[h] = true
+				addrs = append(addrs, h)
+			}
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// Import modes decide what happens to an imported route whose domain and
+// path are already used by a stored route.
+const (
+	// ImportSkipExisting keeps the stored route and reports a conflict.
+	ImportSkipExisting = "skip"
+	// ImportOverwrite updates the stored route in place, keeping its ID.
+	ImportOverwrite = "overwrite"
+	// ImportRename imports the route under a free domain such as
+	// "app-2.test".
+	ImportRename = "rename"
+	// ImportReplaceAll deletes every manual route before importing.
+	// Routes managed by Docker discovery are kept.
+	ImportReplaceAll = "replace"
+)
+
+// Import result statuses.
+const (
+	ImportCreated  = "created"
+	ImportUpdated  = "updated"
+	ImportConflict = "conflict"
+	ImportInvalid  = "invalid"
+)
+
+// renameAttempts bounds the search for a free domain in ImportRename mode.
+const renameAttempts = 100
+
+// ImportResult is the outcome of one imported route. Index is its position
+// in the import; RouteID is the stored route it updates or conflicts with.
+type ImportResult struct {
+	Index     int          `json:"index"`
+	Name      string       `json:"name"`
+	Domain    string       `json:"domain"`
+	Path      string       `json:"path"`
+	Status    string       `json:"status"`
+	RouteID   int64        `json:"route_id,omitempty"`
+	RenamedTo string       `json:"renamed_to,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// ImportReport describes an import, or what it would do on a dry run.
+type ImportReport struct {
+	Mode          string         `json:"mode"`
+	DryRun        bool           `json:"dry_run"`
+	Applied       bool           `json:"applied"`
+	SchemaVersion int            `json:"schema_version"`
+	Created       int            `json:"created"`
+	Updated       int            `json:"updated"`
+	Conflicts     int            `json:"conflicts"`
+	Invalid       int            `json:"invalid"`
+	Deleted       int            `json:"deleted"`
+	Routes        []ImportResult `json:"routes"`
+}
+
+// ImportPlan holds the validated writes of an import. Update is keyed by
+// the ID of the stored route to overwrite.
+type ImportPlan struct {
+	ReplaceAll bool
+	Projects   []models.ExportProject
+	Create     []models.ImportRoute
+	Update     map[int64]models.ImportRoute
+}
+
+// NormalizeImportMode validates an import mode; empty means skip.
+func NormalizeImportMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "", ImportSkipExisting, "skip-existing":
+		return ImportSkipExisting, nil
+	case ImportOverwrite, ImportRename:
+		return mode, nil
+	case ImportReplaceAll, "replace-all":
+		return ImportReplaceAll, nil
+	}
+	return "", fmt.Errorf("invalid import mode %q, use skip, overwrite, rename or replace", mode)
+}
+
+// PlanImport validates imported routes against the stored ones and decides,
+// route by route, what the mode does with them. Nothing is written; apply
+// the plan with database.ApplyImport.
+func PlanImport(routes []models.ImportRoute, existing []models.Route, mode string) (ImportReport, ImportPlan) {
+	report := ImportReport{Mode: mode, Routes: []ImportResult{}}
+	plan := ImportPlan{ReplaceAll: mode == ImportReplaceAll, Update: make(map[int64]models.ImportRoute)}
+
+	// current is the route table as it will be after the routes planned so
+	// far. Planned creations get negative IDs so they never match a stored
+	// route.
+	var current []models.Route
+	for _, r := range existing {
+		if plan.ReplaceAll && r.Source == models.RouteSourceManual {
+			report.Deleted++
+			continue
+		}
+		current = append(current, r)
+	}
+	imported := make(map[int64]int)
+
+	for i, in := range routes {
+		res := ImportResult{Index: i, Name: in.Name, Domain: in.Domain, Path: in.Path}
+		r, errs := normalizeImportRoute(in)
+		res.Domain, res.Path = r.Domain, r.Path
+		if len(errs) > 0 {
+			res.Status, res.Errors = ImportInvalid, errs
+			report.add(res)
+			continue
+		}
+		route := importToRoute(r)
+		route.ID = -int64(i + 1)
+
+		if same := findRoute(current, route.Domain, route.Path); same != nil {
+			switch {
+			case same.Source != models.RouteSourceManual:
+				res.Status, res.RouteID = ImportConflict, same.ID
+				res.Errors = []FieldError{{Field: "path", Message: fmt.Sprintf("route %q (id %d) is managed by %s", same.Name, same.ID, same.Source)}}
+			case mode == ImportRename:
+				if !renameImport(&route, current) {
+					res.Status = ImportConflict
+					res.Errors = []FieldError{{Field: "domain", Message: "no free domain found to rename to"}}
+				} else {
+					res.RenamedTo = route.Domain
+				}
+			case same.ID < 0:
+				res.Status = ImportConflict
+				res.Errors = []FieldError{{Field: "path", Message: fmt.Sprintf("duplicate of route %d in this import", imported[same.ID])}}
+			case mode == ImportOverwrite:
+				route.ID = same.ID
+				res.RouteID = same.ID
+			default:
+				res.Status, res.RouteID = ImportConflict, same.ID
+				res.Errors = []FieldError{{Field: "path", Message: fmt.Sprintf("route %q (id %d) already uses %s%s", same.Name, same.ID, same.Domain, same.Path)}}
+			}
+		} else if mode == ImportRename && len(RouteConflicts(route, current)) > 0 {
+			if renameImport(&route, current) {
+				res.RenamedTo = route.Domain
+			}
+		}
+
+		if res.Status == "" {
+			if conflicts := RouteConflicts(route, current); len(conflicts) > 0 {
+				res.Status, res.Errors = ImportConflict, conflicts
+				res.RouteID = conflicts[0].ConflictRouteID
+				if res.RouteID < 0 {
+					res.RouteID = 0
+				}
+			}
+		}
+		if res.Status != "" {
+			report.add(res)
+			continue
+		}
+
+		r.Domain = route.Domain
+		if route.ID > 0 {
+			res.Status = ImportUpdated
+			plan.Update[route.ID] = r
+			current = replaceOrAppend(current, route)
+		} else {
+			res.Status = ImportCreated
+			plan.Create = append(plan.Create, r)
+			imported[route.ID] = i
+			current = append(current, route)
+		}
+		report.add(res)
+	}
+	return report, plan
+}
+
+func (r *ImportReport) add(res ImportResult) {
+	switch res.Status {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportConflict:
+		r.Conflicts++
+	case ImportInvalid:
+		r.Invalid++
+	}
+	r.Routes = append(r.Routes, res)
+}
+
+// normalizeImportRoute validates an imported route like one submitted
+// through the API, plus its basic auth hashes.
+func normalizeImportRoute(in models.ImportRoute) (models.ImportRoute, []FieldError) {
+	route, errs := NormalizeRoute(importToRoute(in))
+	users, err := NormalizeImportedBasicAuth(in.BasicAuth)
+	if err != nil {
+		errs = append(errs, FieldError{Field: "basic_auth", Message: err.Error()})
+	}
+
+	out := in
+	out.Project = strings.TrimSpace(in.Project)
+	out.Domain, out.Aliases, out.Path = route.Domain, route.Aliases, route.Path
+	out.Kind, out.Target, out.TLSMode = route.Kind, route.Target, route.TLSMode
+	out.Redirect, out.Static, out.FileServer = route.Redirect, route.Static, route.FileServer
+	out.HealthCheck, out.Headers, out.AllowCIDRs = route.HealthCheck, route.Headers, route.AllowCIDRs
+	out.BasicAuth = users
+	return out, errs
+}
+
+func importToRoute(in models.ImportRoute) models.Route {
+	return models.Route{
+		Name:        in.Name,
+		Domain:      in.Domain,
+		Aliases:     in.Aliases,
+		Path:        in.Path,
+		Priority:    in.Priority,
+		StripPrefix: in.StripPrefix,
+		Kind:        in.Kind,
+		Target:      in.Target,
+		Redirect:    in.Redirect,
+		Static:      in.Static,
+		FileServer:  in.FileServer,
+		TLSMode:     in.TLSMode,
+		Enabled:     in.Enabled,
+		Maintenance: in.Maintenance,
+		HealthCheck: in.HealthCheck,
+		Headers:     in.Headers,
+		AllowCIDRs:  in.AllowCIDRs,
+		Source:      models.RouteSourceManual,
+	}
+}
+
+func findRoute(routes []models.Route, domain, path string) *models.Route {
+	for i := range routes {
+		if routes[i].Domain == domain && NormalizePath(routes[i].Path) == path {
+			return &routes[i]
+		}
+	}
+	return nil
+}
+
+func replaceOrAppend(routes []models.Route, r models.Route) []models.Route {
+	for i := range routes {
+		if routes[i].ID == r.ID {
+			routes[i] = r
+			return routes
+		}
+	}
+	return append(routes, r)
+}
+
+// renameImport moves a route to the first free domain with a numeric
+// suffix on its first label after any wildcard, e.g. "app-2.test".
+func renameImport(r *models.Route, current []models.Route) bool {
+	prefix, rest := "", r.Domain
+	if IsWildcard(rest) {
+		prefix, rest = "*.", strings.TrimPrefix(rest, "*.")
+	}
+	label, tail := rest, ""
+	if i := strings.Index(rest, "."); i >= 0 {
+		label, tail = rest[:i], rest[i:]
+	}
+
+	for n := 2; n < renameAttempts+2; n++ {
+		candidate := *r
+		candidate.Domain = prefix + label + "-" + strconv.Itoa(n) + tail
+		if _, err := NormalizeHostname(candidate.Domain); err != nil {
+			return false
+		}
+		if findRoute(current, candidate.Domain, candidate.Path) == nil && len(RouteConflicts(candidate, current)) == 0 {
+			*r = candidate
+			return true
+		}
+	}
+	return false
+}
+
+// ImportIssue is a part of a foreign proxy config that was not translated.
+// Line is the line in the source file; Context names the server or router.
+type ImportIssue struct {
+	Line    int    `json:"line,omitempty"`
+	Context string `json:"context,omitempty"`
+	Message string `json:"message"`
+}
+
+// ConfigPreview is a foreign proxy config translated to DevProxy routes.
+// Document is ready for POST /api/import; Issues list everything that was
+// left out, so a migration can be reviewed line by line.
+type ConfigPreview struct {
+	Format   string                `json:"format"`
+	Document models.ExportDocument `json:"document"`
+	Issues   []ImportIssue         `json:"issues"`
+	Report   ImportReport          `json:"report"`
+}
+
+// newConfigPreview wraps translated routes in an export document.
+func newConfigPreview(format string, routes []models.ImportRoute, issues []ImportIssue) ConfigPreview {
+	if routes == nil {
+		routes = []models.ImportRoute{}
+	}
+	if issues == nil {
+		issues = []ImportIssue{}
+	}
+	return ConfigPreview{Format: format, Document: NewExport(routes, nil), Issues: issues}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+)
+
+func TestPlanImport(t *testing.T) {
+	existing := []models.Route{
+		{ID: 1, Name: "app", Domain: "app.test", Target: "app-web-1:80"},
+		{ID: 2, Name: "docker", Domain: "shop.test", Target: "shop-web-1:80", Source: models.RouteSourceDocker},
+	}
+	routes := []models.ImportRoute{
+		{Name: "app", Domain: "APP.test", Target: "app-web-2:80"},
+		{Name: "new", Domain: "new.test", Target: "new-web-1:80"},
+		{Name: "shop", Domain: "shop.test", Target: "shop-web-2:80"},
+		{Name: "bad", Domain: "bad_domain.test", Target: "bad-web-1:80"},
+		{Name: "dup", Domain: "new.test", Target: "new-web-2:80"},
+	}
+
+	tests := []struct {
+		mode     string
+		statuses []string
+		renamed  string
+		deleted  int
+	}{
+		{ImportSkipExisting, []string{ImportConflict, ImportCreated, ImportConflict, ImportInvalid, ImportConflict}, "", 0},
+		{ImportOverwrite, []string{ImportUpdated, ImportCreated, ImportConflict, ImportInvalid, ImportConflict}, "", 0},
+		{ImportRename, []string{ImportCreated, ImportCreated, ImportConflict, ImportInvalid, ImportCreated}, "app-2.test", 0},
+		{ImportReplaceAll, []string{ImportCreated, ImportCreated, ImportConflict, ImportInvalid, ImportConflict}, "", 1},
+	}
+
+	for _, tt := range tests {
+		report, plan := PlanImport(routes, existing, tt.mode)
+		if len(report.Routes) != len(routes) {
+			t.Fatalf("%s: got %d results, want %d", tt.mode, len(report.Routes), len(routes))
+		}
+		for i, res := range report.Routes {
+			if res.Status != tt.statuses[i] {
+				t.Errorf("%s: route %d status = %q, want %q (%v)", tt.mode, i, res.Status, tt.statuses[i], res.Errors)
+			}
+		}
+		if report.Routes[0].RenamedTo != tt.renamed {
+			t.Errorf("%s: renamed to %q, want %q", tt.mode, report.Routes[0].RenamedTo, tt.renamed)
+		}
+		if report.Deleted != tt.deleted {
+			t.Errorf("%s: deleted = %d, want %d", tt.mode, report.Deleted, tt.deleted)
+		}
+		if len(plan.Create)+len(plan.Update) != report.Created+report.Updated {
+			t.Errorf("%s: plan has %d writes, report %d", tt.mode, len(plan.Create)+len(plan.Update), report.Created+report.Updated)
+		}
+	}
+
+	if _, plan := PlanImport(routes, existing, ImportOverwrite); plan.Update[1].Target != "app-web-2:80" {
+		t.Errorf("overwrite plan = %+v, want route 1 updated", plan.Update)
+	}
+}
+
+func TestNormalizeImportMode(t *testing.T) {
+	tests := []struct {
+		mode, want string
+		valid      bool
+	}{
+		{"", ImportSkipExisting, true},
+		{"Overwrite", ImportOverwrite, true},
+		{"replace-all", ImportReplaceAll, true},
+		{"merge", "", false},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeImportMode(tt.mode)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("NormalizeImportMode(%q) = %q, %v", tt.mode, got, err)
+		}
+	}
+}
+
+func TestApplyImportIsAtomic(t *testing.T) {
+	newTestEnv(t, nil)
+
+	r := models.Route{Name: "app", Domain: "app.test", Target: "app-web-1:80", Enabled: true}
+	if err := database.CreateRoute(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second route collides with the first, so nothing may be written.
+	create := []models.ImportRoute{
+		{Name: "new", Domain: "new.test", Target: "new-web-1:80"},
+		{Name: "dup", Domain: "new.test", Target: "new-web-2:80"},
+	}
+	update := map[int64]models.ImportRoute{r.ID: {Name: "app", Domain: "app.test", Target: "app-web-2:80"}}
+	if err := database.ApplyImport(true, nil, create, update); err == nil {
+		t.Fatal("expected duplicate route error")
+	}
+	routes, err := database.GetAllRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].Target != "app-web-1:80" {
+		t.Errorf("routes after failed import = %+v, want unchanged", routes)
+	}
+
+	if err := database.ApplyImport(false, nil, create[:1], update); err != nil {
+		t.Fatal(err)
+	}
+	if routes, _ = database.GetAllRoutes(); len(routes) != 2 {
+		t.Errorf("got %d routes, want 2", len(routes))
+	}
+}
+
+func TestApplyImportSwapsDomains(t *testing.T) {
+	newTestEnv(t, nil)
+
+	a := models.Route{Name: "a", Domain: "a.test", Target: "a:80", Enabled: true}
+	b := models.Route{Name: "b", Domain: "b.test", Path: "/api", Target: "b:80", Enabled: true}
+	for _, r := range []*models.Route{&a, &b} {
+		if err := database.CreateRoute(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Map order is random, so swap back and forth a few times.
+	for i := 0; i < 10; i++ {
+		update := map[int64]models.ImportRoute{
+			a.ID: {Name: "a", Domain: b.Domain, Path: b.Path, Target: "a:80"},
+			b.ID: {Name: "b", Domain: a.Domain, Path: a.Path, Target: "b:80"},
+		}
+		if err := database.ApplyImport(false, nil, nil, update); err != nil {
+			t.Fatalf("swap %d: %v", i, err)
+		}
+		a.Domain, a.Path, b.Domain, b.Path = b.Domain, b.Path, a.Domain, a.Path
+	}
+	got, err := database.GetRouteByID(strconv.FormatInt(a.ID, 10))
+	if err != nil || got.Domain != "a.test" || got.Path != "" {
+		t.Errorf("route a = %+v, %v; want a.test after an even number of swaps", got, err)
+	}
+}
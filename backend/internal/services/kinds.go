@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"devproxy/internal/models"
+)
+
+// NormalizeRouteKind validates the kind of a route and the fields that
+// depend on it, and fills in defaults. On error it also returns the name of
+// the offending field. Options of other kinds are cleared, and so are the
+// health check and header rules of routes that do not proxy.
+func NormalizeRouteKind(r models.Route) (models.Route, string, error) {
+	r.Kind = strings.TrimSpace(r.Kind)
+	if r.Kind == "" {
+		r.Kind = models.RouteKindProxy
+	}
+	r.Target = strings.TrimSpace(r.Target)
+
+	switch r.Kind {
+	case models.RouteKindProxy:
+		if err := ValidateTarget(r.Target); err != nil {
+			return r, "target", err
+		}
+	case models.RouteKindRedirect:
+		if err := validateRedirectTarget(r.Target); err != nil {
+			return r, "target", err
+		}
+		switch r.Redirect.Status {
+		case 0:
+			r.Redirect.Status = http.StatusFound
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return r, "redirect", fmt.Errorf("redirect status must be 301, 302, 307 or 308, got %d", r.Redirect.Status)
+		}
+	case models.RouteKindStatic:
+		if r.Target != "" {
+			return r, "target", errors.New("static routes have no target")
+		}
+		if r.Static.Status == 0 {
+			r.Static.Status = http.StatusOK
+		}
+		if r.Static.Status < 100 || r.Static.Status > 599 {
+			return r, "static", fmt.Errorf("status must be between 100 and 599, got %d", r.Static.Status)
+		}
+		headers, err := normalizeStaticHeaders(r.Static.Headers)
+		if err != nil {
+			return r, "static", err
+		}
+		r.Static.Headers = headers
+	case models.RouteKindFileServer:
+		if !strings.HasPrefix(r.Target, "/") {
+			return r, "target", errors.New("target must be an absolute directory path inside the Caddy container, e.g. /srv/site")
+		}
+		if strings.ContainsAny(r.Target, "{}") || strings.IndexFunc(r.Target, unicode.IsControl) >= 0 {
+			return r, "target", errors.New("target must not contain braces or control characters")
+		}
+		r.Target = path.Clean(r.Target)
+	default:
+		return r, "kind", fmt.Errorf("invalid kind %q, use proxy, redirect, static or file_server", r.Kind)
+	}
+
+	if r.Kind != models.RouteKindRedirect {
+		r.Redirect = models.RedirectOptions{}
+	}
+	if r.Kind != models.RouteKindStatic {
+		r.Static = models.StaticResponse{}
+	}
+	if r.Kind != models.RouteKindFileServer {
+		r.FileServer = models.FileServerOptions{}
+	}
+	if r.Kind != models.RouteKindProxy {
+		if !headerRulesEmpty(r.Headers) {
+			return r, "headers", errors.New("header rules only apply to proxy routes")
+		}
+		r.HealthCheck = models.HealthCheck{}
+	}
+	return r, "", nil
+}
+
+// IsProxyRoute reports whether a route forwards requests to an upstream.
+// Routes saved before kinds existed have an empty kind.
+func IsProxyRoute(r models.Route) bool {
+	return r.Kind == "" || r.Kind == models.RouteKindProxy
+}
+
+// validateRedirectTarget accepts an absolute http(s) URL or a path on the
+// same host.
+func validateRedirectTarget(target string) error {
+	if target == "" {
+		return errors.New("target is required")
+	}
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") {
+		return nil
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid redirect target %q: use an http(s) URL or a path starting with /", target)
+	}
+	return nil
+}
+
+func normalizeStaticHeaders(in map[string]string) (map[string]string, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(in))
+	for name, value := range in {
+		name = strings.TrimSpace(name)
+		if !validHeaderName(name) {
+			return nil, fmt.Errorf("header %q: invalid header name", name)
+		}
+		key := http.CanonicalHeaderKey(name)
+		if managedHeaders[key] {
+			return nil, fmt.Errorf("header %s cannot be set", key)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("header %s: value must not contain line breaks", key)
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+func headerRulesEmpty(h models.HeaderRules) bool {
+	return len(h.Request.Set)+len(h.Request.Add)+len(h.Request.Delete)+
+		len(h.Response.Set)+len(h.Response.Add)+len(h.Response.Delete) == 0
+}
+
+// redirectLocation is the Location of a redirect route, with Caddy's
+// placeholder for the request URI appended if the path is preserved.
+func redirectLocation(r models.Route, uriPlaceholder string) string {
+	if !r.Redirect.PreservePath {
+		return r.Target
+	}
+	return strings.TrimSuffix(r.Target, "/") + uriPlaceholder
+}
+
+// kindHandler returns the final Caddy handler of a route that does not
+// proxy.
+func kindHandler(r models.Route) map[string]interface{} {
+	switch r.Kind {
+	case models.RouteKindRedirect:
+		return map[string]interface{}{
+			"handler":     "static_response",
+			"status_code": r.Redirect.Status,
+			"headers":     map[string][]string{"Location": {redirectLocation(r, "{http.request.uri}")}},
+		}
+	case models.RouteKindStatic:
+		h := map[string]interface{}{
+			"handler":     "static_response",
+			"status_code": r.Static.Status,
+		}
+		if len(r.Static.Headers) > 0 {
+			headers := make(map[string][]string, len(r.Static.Headers))
+			for name, value := range r.Static.Headers {
+				headers[name] = []string{value}
+			}
+			h["headers"] = headers
+		}
+		if r.Static.Body != "" {
+			h["body"] = r.Static.Body
+		}
+		return h
+	case models.RouteKindFileServer:
+		h := map[string]interface{}{
+			"handler": "file_server",
+			"root":    r.Target,
+		}
+		if r.FileServer.Browse {
+			h["browse"] = map[string]interface{}{}
+		}
+		return h
+	}
+	return nil
+}
+
+// caddyfileKindLines renders the directives of a route that does not proxy.
+func caddyfileKindLines(r models.Route) []string {
+	switch r.Kind {
+	case models.RouteKindRedirect:
+		return []string{fmt.Sprintf("redir %s %d", caddyfileQuote(redirectLocation(r, "{uri}")), r.Redirect.Status)}
+	case models.RouteKindStatic:
+		var lines []string
+		for _, name := range sortedHeaderNames(r.Static.Headers) {
+			lines = append(lines, fmt.Sprintf("header %s %s", name, caddyfileQuote(r.Static.Headers[name])))
+		}
+		if r.Static.Body == "" {
+			return append(lines, fmt.Sprintf("respond %d", r.Static.Status))
+		}
+		return append(lines, fmt.Sprintf("respond %s %d", caddyfileQuote(r.Static.Body), r.Static.Status))
+	case models.RouteKindFileServer:
+		browse := ""
+		if r.FileServer.Browse {
+			browse = " browse"
+		}
+		return []string{"root * " + caddyfileQuote(r.Target), "file_server" + browse}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestNormalizeRouteKind(t *testing.T) {
+	tests := []struct {
+		name      string
+		route     models.Route
+		wantField string
+	}{
+		{"default proxy", models.Route{Target: "app:3000"}, ""},
+		{"proxy without target", models.Route{Kind: "proxy"}, "target"},
+		{"unknown kind", models.Route{Kind: "lambda", Target: "app:3000"}, "kind"},
+		{"redirect", models.Route{Kind: "redirect", Target: "https://example.com"}, ""},
+		{"redirect to path", models.Route{Kind: "redirect", Target: "/new"}, ""},
+		{"redirect to host", models.Route{Kind: "redirect", Target: "example.com"}, "target"},
+		{"redirect status", models.Route{Kind: "redirect", Target: "/new", Redirect: models.RedirectOptions{Status: 303}}, "redirect"},
+		{"static", models.Route{Kind: "static", Static: models.StaticResponse{Headers: map[string]string{"content-type": "text/plain"}, Body: "ok"}}, ""},
+		{"static with target", models.Route{Kind: "static", Target: "app:3000"}, "target"},
+		{"static status", models.Route{Kind: "static", Static: models.StaticResponse{Status: 600}}, "static"},
+		{"static header", models.Route{Kind: "static", Static: models.StaticResponse{Headers: map[string]string{"Content-Length": "1"}}}, "static"},
+		{"file server", models.Route{Kind: "file_server", Target: "/srv/site/"}, ""},
+		{"relative directory", models.Route{Kind: "file_server", Target: "site"}, "target"},
+		{"directory with braces", models.Route{Kind: "file_server", Target: "/srv/site {\n}"}, "target"},
+		{"directory with newline", models.Route{Kind: "file_server", Target: "/srv/site\nfile_server"}, "target"},
+		{"header rules", models.Route{Kind: "static", Headers: models.HeaderRules{Response: models.HeaderOps{Delete: []string{"Server"}}}}, "headers"},
+	}
+	for _, tt := range tests {
+		_, field, err := NormalizeRouteKind(tt.route)
+		if field != tt.wantField || (err != nil) != (tt.wantField != "") {
+			t.Errorf("%s: field = %q, error = %v, want field %q", tt.name, field, err, tt.wantField)
+		}
+	}
+
+	r, _, _ := NormalizeRouteKind(models.Route{Kind: "redirect", Target: "/new", HealthCheck: models.HealthCheck{Path: "/health"}})
+	if r.Redirect.Status != 302 || r.HealthCheck.Path != "" {
+		t.Errorf("redirect defaults: status %d, health check %+v", r.Redirect.Status, r.HealthCheck)
+	}
+	r, _, _ = NormalizeRouteKind(models.Route{Kind: "static", Static: models.StaticResponse{Headers: map[string]string{"content-type": "text/plain"}}})
+	if r.Static.Status != 200 || r.Static.Headers["Content-Type"] != "text/plain" {
+		t.Errorf("static defaults: %+v", r.Static)
+	}
+}
+
+func TestRenderRouteKinds(t *testing.T) {
+	tests := []struct {
+		route     models.Route
+		handler   string
+		caddyfile string
+	}{
+		{
+			models.Route{ID: 1, Domain: "old.test", Kind: "redirect", Target: "https://new.test/", Redirect: models.RedirectOptions{Status: 308, PreservePath: true}},
+			"static_response",
+			`redir "https://new.test{uri}" 308`,
+		},
+		{
+			models.Route{ID: 2, Domain: "static.test", Kind: "static", Static: models.StaticResponse{Status: 503, Body: "Be right back"}},
+			"static_response",
+			`respond "Be right back" 503`,
+		},
+		{
+			models.Route{ID: 3, Domain: "files.test", Kind: "file_server", Target: "/srv/files", FileServer: models.FileServerOptions{Browse: true}},
+			"file_server",
+			"file_server browse",
+		},
+		{
+			models.Route{ID: 4, Domain: "inject.test", Kind: "static", Static: models.StaticResponse{Status: 200, Body: "ok\n}\nhttp://evil.test {"}},
+			"static_response",
+			"respond \"ok\n}\nhttp://evil.test {\" 200",
+		},
+		{
+			models.Route{ID: 5, Domain: "escape.test", Kind: "static", Static: models.StaticResponse{Status: 200, Body: `say "hi" \`}},
+			"static_response",
+			`respond "say \"hi\" \\" 200`,
+		},
+	}
+	for _, tt := range tests {
+		handlers := routeHandlers(tt.route)
+		if last := handlers[len(handlers)-1]; last["handler"] != tt.handler {
+			t.Errorf("%s: last handler = %v, want %s", tt.route.Domain, last["handler"], tt.handler)
+		}
+		if caddyfile := buildCaddyfile([]models.Route{tt.route}); !strings.Contains(caddyfile, tt.caddyfile) || strings.Contains(caddyfile, "reverse_proxy") {
+			t.Errorf("%s: Caddyfile does not contain %q:\n%s", tt.route.Domain, tt.caddyfile, caddyfile)
+		}
+	}
+
+	redirect := routeHandlers(tests[0].route)[0]
+	if loc := redirect["headers"].(map[string][]string)["Location"][0]; loc != "https://new.test{http.request.uri}" {
+		t.Errorf("Location = %q", loc)
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"devproxy/internal/database"
+	"devproxy/internal/metrics"
+	"devproxy/internal/models"
+)
+
+var (
+	routeResponseTime = metrics.NewHistogramVec("devproxy_route_response_time_seconds",
+		"Response time of route health checks that got an HTTP response.",
+		metrics.DefBuckets, "route_id", "domain")
+	routeDNSFailures = metrics.NewCounterVec("devproxy_route_dns_failures_total",
+		"Health checks that failed because the route target did not resolve.",
+		"route_id", "domain")
+	caddyReloads = metrics.NewCounterVec("devproxy_caddy_reload_attempts_total",
+		"Attempts to apply the route configuration to Caddy.")
+	caddyReloadFailures = metrics.NewCounterVec("devproxy_caddy_reload_failures_total",
+		"Applies that failed validation or were rejected by Caddy.", "reason")
+	caddyReloadDuration = metrics.NewHistogramVec("devproxy_caddy_reload_duration_seconds",
+		"Time taken to apply the route configuration to Caddy.",
+		metrics.DefBuckets)
+)
+
+func init() {
+	metrics.NewGaugeFunc("devproxy_routes", "Number of routes by state.", []string{"state"}, func() []metrics.Sample {
+		routes, err := database.GetAllRoutes()
+		if err != nil {
+			return nil
+		}
+		var enabled, disabled float64
+		for _, r := range routes {
+			if r.Enabled {
+				enabled++
+			} else {
+				disabled++
+			}
+		}
+		return []metrics.Sample{
+			{LabelValues: []string{"enabled"}, Value: enabled},
+			{LabelValues: []string{"disabled"}, Value: disabled},
+		}
+	})
+
+	metrics.NewGaugeFunc("devproxy_route_healthy", "Whether an enabled route is healthy (1) or not (0).", []string{"route_id", "domain"}, func() []metrics.Sample {
+		statuses := GetHealthStatuses()
+		samples := make([]metrics.Sample, 0, len(statuses))
+		for _, s := range statuses {
+			value := 0.0
+			if s.Healthy {
+				value = 1
+			}
+			samples = append(samples, metrics.Sample{LabelValues: routeLabels(s.RouteID, s.Domain), Value: value})
+		}
+		return samples
+	})
+}
+
+// observeHealthCheck records the metrics of a health probe.
+func observeHealthCheck(status *models.HealthStatus) {
+	labels := routeLabels(status.RouteID, status.Domain)
+	if status.StatusCode != 0 {
+		routeResponseTime.Observe(float64(status.ResponseTime)/1000, labels...)
+	}
+	if status.ErrorType == "dns_failure" {
+		routeDNSFailures.Inc(labels...)
+	}
+}
+
+// forgetRouteMetrics drops the per-route series of a route that is no
+// longer checked.
+func forgetRouteMetrics(routeID int64, domain string) {
+	labels := routeLabels(routeID, domain)
+	routeResponseTime.Delete(labels...)
+	routeDNSFailures.Delete(labels...)
+}
+
+// observeReload records the metrics of an apply.
+func observeReload(start time.Time, err error) {
+	caddyReloads.Inc()
+	caddyReloadDuration.Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+
+	reason := "error"
+	var applyErr *ApplyError
+	if errors.As(err, &applyErr) {
+		reason = "validation"
+		if applyErr.Rejected {
+			reason = "rejected"
+		}
+	}
+	caddyReloadFailures.Inc(reason)
+}
+
+func routeLabels(routeID int64, domain string) []string {
+	return []string{strconv.FormatInt(routeID, 10), domain}
+}
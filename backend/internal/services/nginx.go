@@ -0,0 +1,559 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// nginxDirective is a directive of an nginx config with its block, if any.
+type nginxDirective struct {
+	Name  string
+	Args  []string
+	Line  int
+	Block []nginxDirective
+}
+
+// nginxProcessDirectives configure the nginx process rather than routing
+// and have no DevProxy counterpart worth reporting.
+var nginxProcessDirectives = map[string]bool{
+	"user": true, "worker_processes": true, "worker_rlimit_nofile": true,
+	"pid": true, "error_log": true, "events": true, "load_module": true,
+}
+
+// nginxIgnoredDirectives are handled by Caddy the same way or are
+// translated as part of the server block.
+var nginxIgnoredDirectives = map[string]bool{
+	"listen": true, "server_name": true, "access_log": true, "error_log": true,
+	"proxy_http_version": true, "ssl_certificate": true, "ssl_certificate_key": true,
+}
+
+// nginxForwardedHeaders are request headers Caddy sets on its own, so
+// proxy_set_header lines for them need no header rule.
+var nginxForwardedHeaders = map[string]bool{
+	"host": true, "x-real-ip": true, "x-forwarded-for": true, "x-forwarded-proto": true,
+	"x-forwarded-host": true, "upgrade": true, "connection": true,
+}
+
+// nginxContext collects the directives of a server or location that become
+// route settings.
+type nginxContext struct {
+	proxyPass      *nginxDirective
+	ret            *nginxDirective
+	requestHeaders []nginxDirective
+	addHeaders     []nginxDirective
+	access         []nginxDirective
+}
+
+// ParseNginx translates the server blocks of an nginx config to routes:
+// server_name becomes the domain and aliases, every location with a
+// proxy_pass or return becomes a route. Anything else is reported as an
+// issue with its line.
+func ParseNginx(data []byte) (ConfigPreview, error) {
+	directives, err := parseNginxConfig(string(data))
+	if err != nil {
+		return ConfigPreview{}, err
+	}
+
+	t := &nginxTranslator{upstreams: make(map[string][]nginxDirective)}
+	var servers []nginxDirective
+	var walk func(ds []nginxDirective, inHTTP bool)
+	walk = func(ds []nginxDirective, inHTTP bool) {
+		for _, d := range ds {
+			switch {
+			case d.Name == "http":
+				walk(d.Block, true)
+			case d.Name == "server":
+				servers = append(servers, d)
+			case d.Name == "upstream" && len(d.Args) == 1:
+				t.upstreams[d.Args[0]] = d.Block
+			case d.Name == "include":
+				t.issue(d.Line, "", "include %s is not followed; import the included file separately", strings.Join(d.Args, " "))
+			case d.Name == "stream":
+				t.issue(d.Line, "", "stream (TCP/UDP) proxying is not supported")
+			case !inHTTP && nginxProcessDirectives[d.Name]:
+			default:
+				t.issue(d.Line, "", "%s is not translated", d.Name)
+			}
+		}
+	}
+	walk(directives, false)
+
+	for _, s := range servers {
+		t.server(s)
+	}
+	return newConfigPreview("nginx", t.routes, t.issues), nil
+}
+
+type nginxTranslator struct {
+	upstreams map[string][]nginxDirective
+	routes    []models.ImportRoute
+	issues    []ImportIssue
+}
+
+// issue reports an untranslated line once, even when server directives are
+// inherited by several locations.
+func (t *nginxTranslator) issue(line int, context, format string, args ...interface{}) {
+	issue := ImportIssue{Line: line, Context: context, Message: fmt.Sprintf(format, args...)}
+	for _, i := range t.issues {
+		if i.Line == issue.Line && i.Message == issue.Message {
+			return
+		}
+	}
+	t.issues = append(t.issues, issue)
+}
+
+func (t *nginxTranslator) server(s nginxDirective) {
+	var names []string
+	var tls bool
+	var locations []nginxDirective
+	var ctx nginxContext
+
+	for _, d := range s.Block {
+		switch d.Name {
+		case "server_name":
+			names = append(names, d.Args...)
+		case "listen":
+			for _, a := range d.Args {
+				if a == "ssl" || a == "443" || strings.HasSuffix(a, ":443") {
+					tls = true
+				}
+			}
+		case "location":
+			locations = append(locations, d)
+		}
+	}
+
+	domains := t.serverNames(s.Line, names)
+	context := "server"
+	if len(domains) > 0 {
+		context = "server " + domains[0]
+	}
+	t.collect(&ctx, s.Block, context)
+	if len(domains) == 0 {
+		t.issue(s.Line, context, "server without a usable server_name is skipped")
+		return
+	}
+	if tls {
+		for _, d := range s.Block {
+			if d.Name == "ssl_certificate" {
+				t.issue(d.Line, context, "certificates are not imported; the route uses DevProxy's local CA")
+				break
+			}
+		}
+	}
+
+	base := models.ImportRoute{Domain: domains[0], Aliases: domains[1:], Enabled: true}
+	if tls {
+		base.TLSMode = models.TLSModeInternal
+	}
+
+	// A return in the server block answers before any location matches.
+	if ctx.ret != nil {
+		for _, loc := range locations {
+			t.issue(loc.Line, context, "location is never reached: the server block returns first")
+		}
+		if route, ok := t.route(base, "", ctx, context); ok {
+			t.routes = append(t.routes, route)
+		}
+		return
+	}
+
+	translated := 0
+	for _, loc := range locations {
+		if t.location(base, ctx, loc, context) {
+			translated++
+		}
+	}
+	if translated == 0 {
+		t.issue(s.Line, context, "server has no proxy_pass or return that can be translated")
+	}
+}
+
+// serverNames turns server_name arguments into a domain and aliases.
+func (t *nginxTranslator) serverNames(line int, names []string) []string {
+	var domains []string
+	seen := make(map[string]bool)
+	add := func(h string) {
+		if !seen[h] {
+			seen[h] = true
+			domains = append(domains, h)
+		}
+	}
+	for _, n := range names {
+		switch {
+		case n == "_" || n == "" || n == `""`:
+		case strings.HasPrefix(n, "~"):
+			t.issue(line, "", "regular expression server_name %s is not supported", n)
+		case strings.HasSuffix(n, ".*"):
+			t.issue(line, "", "server_name %s with a trailing wildcard is not supported", n)
+		case strings.HasPrefix(n, "."):
+			add(n[1:])
+			add("*" + n)
+		default:
+			add(strings.ToLower(n))
+		}
+	}
+	return domains
+}
+
+// collect sorts the directives of a server or location block into ctx and
+// reports the ones that are not translated. Locations are handled by the
+// caller.
+func (t *nginxTranslator) collect(ctx *nginxContext, block []nginxDirective, context string) {
+	for i, d := range block {
+		switch d.Name {
+		case "location":
+		case "proxy_pass":
+			ctx.proxyPass = &block[i]
+		case "return":
+			ctx.ret = &block[i]
+		case "proxy_set_header":
+			ctx.requestHeaders = append(ctx.requestHeaders, d)
+		case "add_header":
+			ctx.addHeaders = append(ctx.addHeaders, d)
+		case "allow", "deny":
+			ctx.access = append(ctx.access, d)
+		default:
+			switch {
+			case nginxIgnoredDirectives[d.Name]:
+			case strings.HasPrefix(d.Name, "ssl_"):
+			case d.Name == "auth_basic" || d.Name == "auth_basic_user_file":
+				t.issue(d.Line, context, "%s is not imported; add users with PUT /api/routes/:id/users/:username", d.Name)
+			case d.Name == "root" || d.Name == "alias":
+				t.issue(d.Line, context, "%s serves files from the nginx host; use a file_server route with a directory mounted into Caddy", d.Name)
+			default:
+				t.issue(d.Line, context, "%s is not translated", d.Name)
+			}
+		}
+	}
+}
+
+// location translates one location block. Directives of the server apply
+// unless the location sets its own, as in nginx.
+func (t *nginxTranslator) location(base models.ImportRoute, server nginxContext, loc nginxDirective, context string) bool {
+	args := loc.Args
+	modifier := ""
+	if len(args) == 2 {
+		modifier, args = args[0], args[1:]
+	}
+	if len(args) != 1 {
+		t.issue(loc.Line, context, "location %s is not understood", strings.Join(loc.Args, " "))
+		return false
+	}
+	path := args[0]
+	context = context + " location " + strings.Join(loc.Args, " ")
+
+	switch {
+	case strings.HasPrefix(path, "@"):
+		t.issue(loc.Line, context, "named locations are not supported")
+		return false
+	case modifier == "~" || modifier == "~*":
+		t.issue(loc.Line, context, "regular expression locations are not supported")
+		return false
+	case modifier == "=":
+		t.issue(loc.Line, context, "exact match becomes a prefix match on %s", path)
+	case modifier != "" && modifier != "^~":
+		t.issue(loc.Line, context, "location modifier %s is not supported", modifier)
+		return false
+	}
+
+	ctx := nginxContext{}
+	t.collect(&ctx, loc.Block, context)
+	for _, d := range loc.Block {
+		if d.Name == "location" {
+			t.issue(d.Line, context, "nested locations are not supported")
+		}
+	}
+	if ctx.requestHeaders == nil {
+		ctx.requestHeaders = server.requestHeaders
+	}
+	if ctx.addHeaders == nil {
+		ctx.addHeaders = server.addHeaders
+	}
+	if ctx.access == nil {
+		ctx.access = server.access
+	}
+	if ctx.proxyPass == nil && ctx.ret == nil {
+		t.issue(loc.Line, context, "location has no proxy_pass or return and is skipped")
+		return false
+	}
+
+	route, ok := t.route(base, path, ctx, context)
+	if ok {
+		t.routes = append(t.routes, route)
+	}
+	return ok
+}
+
+// route builds the route of a location, or of a server that only returns.
+func (t *nginxTranslator) route(route models.ImportRoute, path string, ctx nginxContext, context string) (models.ImportRoute, bool) {
+	route.Path = NormalizePath(path)
+	route.Name = route.Domain + route.Path
+
+	if ctx.proxyPass != nil {
+		target, strip, ok := t.proxyTarget(*ctx.proxyPass, route.Path, context)
+		if !ok {
+			return route, false
+		}
+		route.Kind, route.Target, route.StripPrefix = models.RouteKindProxy, target, strip
+	} else if !t.returnRoute(&route, *ctx.ret, context) {
+		return route, false
+	}
+
+	for _, d := range ctx.requestHeaders {
+		if len(d.Args) != 2 {
+			t.issue(d.Line, context, "proxy_set_header %s is not understood", strings.Join(d.Args, " "))
+			continue
+		}
+		name, value := d.Args[0], d.Args[1]
+		switch {
+		case nginxForwardedHeaders[strings.ToLower(name)]:
+		case strings.Contains(value, "$"):
+			t.issue(d.Line, context, "proxy_set_header %s uses nginx variables", name)
+		case route.Kind != models.RouteKindProxy:
+		case value == "":
+			route.Headers.Request.Delete = append(route.Headers.Request.Delete, name)
+		default:
+			if route.Headers.Request.Set == nil {
+				route.Headers.Request.Set = make(map[string]string)
+			}
+			route.Headers.Request.Set[name] = value
+		}
+	}
+	for _, d := range ctx.addHeaders {
+		args := d.Args
+		if len(args) == 3 && args[2] == "always" {
+			args = args[:2]
+		}
+		switch {
+		case len(args) != 2:
+			t.issue(d.Line, context, "add_header %s is not understood", strings.Join(d.Args, " "))
+		case strings.Contains(args[1], "$"):
+			t.issue(d.Line, context, "add_header %s uses nginx variables", args[0])
+		case route.Kind != models.RouteKindProxy:
+			t.issue(d.Line, context, "add_header %s is dropped: header rules only apply to proxy routes", args[0])
+		default:
+			if route.Headers.Response.Set == nil {
+				route.Headers.Response.Set = make(map[string]string)
+			}
+			route.Headers.Response.Set[args[0]] = args[1]
+		}
+	}
+	for _, d := range ctx.access {
+		switch {
+		case d.Name == "deny" && len(d.Args) == 1 && d.Args[0] == "all":
+		case d.Name == "allow" && len(d.Args) == 1 && d.Args[0] != "all":
+			route.AllowCIDRs = append(route.AllowCIDRs, d.Args[0])
+		default:
+			t.issue(d.Line, context, "%s %s is not translated; DevProxy only has allowlists", d.Name, strings.Join(d.Args, " "))
+		}
+	}
+	return route, true
+}
+
+// proxyTarget translates a proxy_pass URL, resolving upstream blocks. A URI
+// of "/" replaces the location prefix, which is strip_prefix.
+func (t *nginxTranslator) proxyTarget(d nginxDirective, path, context string) (string, bool, bool) {
+	if len(d.Args) != 1 || strings.Contains(d.Args[0], "$") {
+		t.issue(d.Line, context, "proxy_pass %s with variables is not supported", strings.Join(d.Args, " "))
+		return "", false, false
+	}
+	u, err := url.Parse(d.Args[0])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		t.issue(d.Line, context, "proxy_pass %s is not an http(s) URL", d.Args[0])
+		return "", false, false
+	}
+
+	host := u.Host
+	if servers, ok := t.upstreams[u.Hostname()]; ok {
+		if host, ok = t.upstreamServer(servers, context); !ok {
+			t.issue(d.Line, context, "upstream %s has no server", u.Hostname())
+			return "", false, false
+		}
+	}
+
+	strip := false
+	switch {
+	case u.Path == "" && u.RawQuery == "":
+	case u.Path == "/" && u.RawQuery == "" && path != "":
+		strip = true
+	default:
+		t.issue(d.Line, context, "the URI %s of proxy_pass is not translated", u.RequestURI())
+	}
+
+	if u.Scheme == "https" {
+		return "https://" + host, strip, true
+	}
+	return host, strip, true
+}
+
+// upstreamServer returns the first server of an upstream block.
+func (t *nginxTranslator) upstreamServer(block []nginxDirective, context string) (string, bool) {
+	var host string
+	for _, d := range block {
+		if d.Name != "server" || len(d.Args) == 0 {
+			continue
+		}
+		if host != "" {
+			t.issue(d.Line, context, "only the first upstream server is used; %s is dropped", d.Args[0])
+			continue
+		}
+		host = d.Args[0]
+		if strings.HasPrefix(host, "unix:") {
+			t.issue(d.Line, context, "unix socket upstreams are not supported")
+			return "", false
+		}
+		if len(d.Args) > 1 {
+			t.issue(d.Line, context, "upstream server options %s are not translated", strings.Join(d.Args[1:], " "))
+		}
+	}
+	return host, host != ""
+}
+
+// returnRoute translates "return 301 URL" to a redirect route and other
+// codes to a static response.
+func (t *nginxTranslator) returnRoute(route *models.ImportRoute, d nginxDirective, context string) bool {
+	if len(d.Args) == 0 || len(d.Args) > 2 {
+		t.issue(d.Line, context, "return %s is not understood", strings.Join(d.Args, " "))
+		return false
+	}
+	status, err := strconv.Atoi(d.Args[0])
+	if err != nil {
+		// "return URL" is a 302 redirect.
+		status, d.Args = 302, []string{"302", d.Args[0]}
+	}
+	text := ""
+	if len(d.Args) == 2 {
+		text = d.Args[1]
+	}
+
+	switch status {
+	case 301, 302, 307, 308:
+		location, preserve := strings.CutSuffix(text, "$request_uri")
+		if strings.HasPrefix(location, "https://$host") || strings.HasPrefix(location, "https://$server_name") {
+			t.issue(d.Line, context, "HTTP to HTTPS redirects are not needed; the route is served over HTTPS instead")
+			return false
+		}
+		if location == "" || strings.Contains(location, "$") {
+			t.issue(d.Line, context, "return %s %s uses nginx variables", d.Args[0], text)
+			return false
+		}
+		route.Kind, route.Target = models.RouteKindRedirect, location
+		route.Redirect = models.RedirectOptions{Status: status, PreservePath: preserve}
+	default:
+		if strings.Contains(text, "$") {
+			t.issue(d.Line, context, "return %d uses nginx variables", status)
+			return false
+		}
+		route.Kind = models.RouteKindStatic
+		route.Static = models.StaticResponse{Status: status, Body: text}
+	}
+	return true
+}
+
+// parseNginxConfig tokenizes an nginx config into directives, keeping the
+// line of each.
+func parseNginxConfig(src string) ([]nginxDirective, error) {
+	type token struct {
+		text   string
+		line   int
+		quoted bool
+	}
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '{' || c == '}' || c == ';':
+			tokens = append(tokens, token{text: string(c), line: line})
+			i++
+		case c == '"' || c == '\'':
+			start := line
+			var sb strings.Builder
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				if src[i] == '\n' {
+					line++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", start)
+			}
+			i++
+			tokens = append(tokens, token{text: sb.String(), line: start, quoted: true})
+		default:
+			start := i
+			for i < len(src) && !strings.ContainsRune(" \t\r\n{};#", rune(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{text: src[start:i], line: line})
+		}
+	}
+
+	pos := 0
+	var parse func(depth int) ([]nginxDirective, error)
+	parse = func(depth int) ([]nginxDirective, error) {
+		var ds []nginxDirective
+		for pos < len(tokens) {
+			tok := tokens[pos]
+			if !tok.quoted && tok.text == "}" {
+				if depth == 0 {
+					return nil, fmt.Errorf("line %d: unexpected }", tok.line)
+				}
+				pos++
+				return ds, nil
+			}
+			if !tok.quoted && (tok.text == "{" || tok.text == ";") {
+				return nil, fmt.Errorf("line %d: unexpected %s", tok.line, tok.text)
+			}
+			d := nginxDirective{Name: tok.text, Line: tok.line}
+			pos++
+			for {
+				if pos >= len(tokens) {
+					return nil, fmt.Errorf("line %d: %s is not terminated by ; or {", d.Line, d.Name)
+				}
+				tok := tokens[pos]
+				pos++
+				if !tok.quoted && tok.text == ";" {
+					break
+				}
+				if !tok.quoted && tok.text == "{" {
+					block, err := parse(depth + 1)
+					if err != nil {
+						return nil, err
+					}
+					d.Block = block
+					break
+				}
+				if !tok.quoted && tok.text == "}" {
+					return nil, fmt.Errorf("line %d: unexpected }", tok.line)
+				}
+				d.Args = append(d.Args, tok.text)
+			}
+			ds = append(ds, d)
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("unexpected end of file: missing }")
+		}
+		return ds, nil
+	}
+	return parse(0)
+}
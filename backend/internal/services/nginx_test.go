@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+const testNginxConfig = `worker_processes 1;
+http {
+    upstream api_backend {
+        server shop-api-1:3000 weight=2;
+        server shop-api-2:3000;
+    }
+
+    server {
+        listen 80;
+        server_name shop.test www.shop.test;
+        add_header X-Env dev;
+
+        location / {
+            proxy_pass http://shop-web-1:8080;
+            proxy_set_header Host $host;
+        }
+        location /api/ {
+            proxy_pass http://api_backend/;
+            allow 10.0.0.0/8;
+            deny all;
+        }
+        location ~ \.php$ {
+            fastcgi_pass php:9000;
+        }
+        location /static {
+            root /var/www;
+        }
+    }
+
+    server {
+        listen 443 ssl;
+        server_name .docs.test;
+        ssl_certificate /etc/ssl/docs.pem;
+        gzip on;
+        location = /old {
+            return 301 https://docs.test/new$request_uri;
+        }
+        location / {
+            proxy_pass http://docs-web-1;
+            proxy_read_timeout 300s;
+        }
+    }
+
+    server {
+        listen 80;
+        server_name docs.test;
+        return 301 https://$host$request_uri;
+    }
+}
+`
+
+func TestParseNginx(t *testing.T) {
+	preview, err := ParseNginx([]byte(testNginxConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.ImportRoute{
+		{Name: "shop.test", Domain: "shop.test", Aliases: []string{"www.shop.test"}, Kind: models.RouteKindProxy, Target: "shop-web-1:8080"},
+		{Name: "shop.test/api", Domain: "shop.test", Aliases: []string{"www.shop.test"}, Path: "/api", StripPrefix: true, Kind: models.RouteKindProxy, Target: "shop-api-1:3000"},
+		{Name: "docs.test/old", Domain: "docs.test", Aliases: []string{"*.docs.test"}, Path: "/old", Kind: models.RouteKindRedirect, Target: "https://docs.test/new", TLSMode: models.TLSModeInternal},
+		{Name: "docs.test", Domain: "docs.test", Aliases: []string{"*.docs.test"}, Kind: models.RouteKindProxy, Target: "docs-web-1", TLSMode: models.TLSModeInternal},
+	}
+	routes := preview.Document.Routes
+	if len(routes) != len(want) {
+		t.Fatalf("got %d routes: %+v", len(routes), routes)
+	}
+	for i, w := range want {
+		r := routes[i]
+		if r.Name != w.Name || r.Domain != w.Domain || strings.Join(r.Aliases, ",") != strings.Join(w.Aliases, ",") ||
+			r.Path != w.Path || r.StripPrefix != w.StripPrefix || r.Kind != w.Kind || r.Target != w.Target || r.TLSMode != w.TLSMode {
+			t.Errorf("route %d = %+v, want %+v", i, r, w)
+		}
+	}
+	if got := routes[0].Headers.Response.Set["X-Env"]; got != "dev" {
+		t.Errorf("inherited add_header = %q, want dev", got)
+	}
+	if len(routes[1].AllowCIDRs) != 1 || routes[1].Headers.Response.Set["X-Env"] != "dev" {
+		t.Errorf("api route = %+v", routes[1])
+	}
+	if r := routes[2].Redirect; r.Status != 301 || !r.PreservePath {
+		t.Errorf("redirect = %+v", r)
+	}
+
+	issues := map[int]string{
+		5:  "only the first upstream server",
+		4:  "upstream server options",
+		22: "regular expression locations",
+		26: "root serves files",
+		33: "certificates are not imported",
+		34: "gzip is not translated",
+		35: "exact match",
+		40: "proxy_read_timeout is not translated",
+		47: "HTTP to HTTPS redirects",
+	}
+	for line, msg := range issues {
+		found := false
+		for _, i := range preview.Issues {
+			if i.Line == line && strings.Contains(i.Message, msg) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("no issue %q on line %d in %+v", msg, line, preview.Issues)
+		}
+	}
+}
+
+func TestParseNginxSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		config, err string
+	}{
+		{"server {\n  listen 80;\n", "missing }"},
+		{"server {\n  listen 80\n}\n", "line 3: unexpected }"},
+		{"}\n", "line 1: unexpected }"},
+		{"server_name 'open;\n", "line 1: unterminated string"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseNginx([]byte(tt.config)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseNginx(%q) error = %v, want %q", tt.config, err, tt.err)
+		}
+	}
+}
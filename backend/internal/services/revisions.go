@@ -0,0 +1,168 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"devproxy/internal/database"
+	"devproxy/internal/events"
+	"devproxy/internal/models"
+)
+
+// Authors recorded for revisions that were not applied through the API.
+const (
+	RevisionAuthorSystem = "system"
+	RevisionAuthorDocker = "docker-discovery"
+)
+
+// recordRevision stores the applied config and the full route table as a
+// new revision. Nothing is stored when both are unchanged since the latest
+// revision, so repeated applies do not flood the history.
+func recordRevision(cfg *caddyConfig, author, message string) error {
+	routes, err := database.GetAllRoutes()
+	if err != nil {
+		return err
+	}
+	config, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	latest, err := database.GetLatestRevision()
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if latest != nil && bytes.Equal(latest.Config, config) && sameRouteSet(latest.Routes, routes) {
+		return nil
+	}
+
+	return database.CreateRevision(&models.Revision{
+		Author:  author,
+		Message: message,
+		Routes:  routes,
+		Config:  config,
+	})
+}
+
+// RollbackToRevision restores the route table of a revision and applies it.
+// If Caddy does not accept it, the route table is restored as it was. The
+// rollback itself is recorded as a new revision.
+func RollbackToRevision(id string, author string) (message string, warning string, err error) {
+	reloadMux.Lock()
+	defer reloadMux.Unlock()
+
+	rev, err := database.GetRevision(id)
+	if err != nil {
+		return "", "", err
+	}
+	current, err := database.GetAllRoutes()
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := database.ReplaceRoutes(rev.Routes); err != nil {
+		return "", "", err
+	}
+	message, warning, err = reloadCaddy(author, fmt.Sprintf("Rollback to revision %d", rev.ID))
+	if err != nil {
+		if restoreErr := database.ReplaceRoutes(current); restoreErr != nil {
+			return "", "", fmt.Errorf("%v (restoring routes failed: %v)", err, restoreErr)
+		}
+		return "", "", err
+	}
+	events.Publish(events.RoutesChanged, map[string]interface{}{"action": "rollback", "revision": rev.ID})
+	return fmt.Sprintf("Rolled back to revision %d", rev.ID), warning, nil
+}
+
+// DiffRevisions compares the route sets of two revisions.
+func DiffRevisions(from, to *models.Revision) models.RevisionDiff {
+	diff := models.RevisionDiff{
+		From:          from.ID,
+		To:            to.ID,
+		Added:         []models.Route{},
+		Removed:       []models.Route{},
+		Changed:       []models.RouteChange{},
+		ConfigChanged: !jsonEqual(from.Config, to.Config),
+	}
+
+	before := make(map[int64]models.Route, len(from.Routes))
+	for _, r := range from.Routes {
+		before[r.ID] = r
+	}
+
+	seen := make(map[int64]bool, len(to.Routes))
+	for _, r := range to.Routes {
+		seen[r.ID] = true
+		old, ok := before[r.ID]
+		if !ok {
+			diff.Added = append(diff.Added, r)
+			continue
+		}
+		if fields := changedRouteFields(old, r); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, models.RouteChange{RouteID: r.ID, Fields: fields, Before: old, After: r})
+		}
+	}
+
+	for _, r := range from.Routes {
+		if !seen[r.ID] {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+	return diff
+}
+
+// changedRouteFields returns the JSON names of the fields that differ
+// between two versions of a route, ignoring timestamps.
+func changedRouteFields(a, b models.Route) []string {
+	var fields []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		f := va.Type().Field(i)
+		if f.Name == "ID" || f.Name == "CreatedAt" || f.Name == "UpdatedAt" {
+			continue
+		}
+		if !reflect.DeepEqual(normalizeEmpty(va.Field(i).Interface()), normalizeEmpty(vb.Field(i).Interface())) {
+			fields = append(fields, jsonFieldName(f))
+		}
+	}
+	return fields
+}
+
+// sameRouteSet reports whether two route tables are equal apart from
+// timestamps.
+func sameRouteSet(a, b []models.Route) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byID := make(map[int64]models.Route, len(a))
+	for _, r := range a {
+		byID[r.ID] = r
+	}
+	for _, r := range b {
+		old, ok := byID[r.ID]
+		if !ok || len(changedRouteFields(old, r)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeEmpty treats nil and empty slices as equal.
+func normalizeEmpty(v interface{}) interface{} {
+	if s, ok := v.([]string); ok && len(s) == 0 {
+		return []string(nil)
+	}
+	return v
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
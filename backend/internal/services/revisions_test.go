@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	from := &models.Revision{ID: 1, Routes: []models.Route{
+		{ID: 1, Domain: "a.test", Target: "a-web-1:80", Aliases: []string{}},
+		{ID: 2, Domain: "b.test", Target: "b-web-1:80"},
+	}}
+	to := &models.Revision{ID: 2, Routes: []models.Route{
+		{ID: 1, Domain: "a.test", Target: "a-web-1:8080", Enabled: true},
+		{ID: 3, Domain: "c.test", Target: "c-web-1:80"},
+	}}
+
+	diff := DiffRevisions(from, to)
+	if len(diff.Added) != 1 || diff.Added[0].ID != 3 {
+		t.Errorf("Added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != 2 {
+		t.Errorf("Removed = %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || len(diff.Changed[0].Fields) != 2 ||
+		diff.Changed[0].Fields[0] != "target" || diff.Changed[0].Fields[1] != "enabled" {
+		t.Errorf("Changed = %+v; want target and enabled", diff.Changed)
+	}
+}
+
+func TestRollbackToRevision(t *testing.T) {
+	newTestEnv(t, nil)
+
+	r := models.Route{Name: "app", Domain: "app.test", Target: "app-web-1:80", Enabled: true}
+	if err := database.CreateRoute(&r); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReloadCaddy("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReloadCaddy("alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Target = "app-web-1:3000"
+	database.UpdateRoute("1", &r)
+	extra := models.Route{Name: "api", Domain: "api.test", Target: "api-web-1:80", Enabled: true}
+	database.CreateRoute(&extra)
+	if _, _, err := ReloadCaddy("bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, _ := database.GetRevisions(10)
+	if len(revisions) != 2 || revisions[0].Author != "bob" || revisions[0].RouteCount != 2 {
+		t.Fatalf("revisions = %+v; want two, newest by bob", revisions)
+	}
+
+	if _, _, err := RollbackToRevision("1", "carol"); err != nil {
+		t.Fatalf("RollbackToRevision() error = %v", err)
+	}
+	routes, _ := database.GetAllRoutes()
+	if len(routes) != 1 || routes[0].Target != "app-web-1:80" {
+		t.Errorf("routes after rollback = %+v", routes)
+	}
+
+	latest, _ := database.GetLatestRevision()
+	if latest.ID != 3 || latest.Author != "carol" || latest.Message != "Rollback to revision 1" {
+		t.Errorf("latest revision = %+v", latest)
+	}
+}
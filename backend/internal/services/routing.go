@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// PathConflictError reports two routes on the same domain whose path
+// matchers overlap without a clear winner.
+type PathConflictError struct {
+	Route    models.Route
+	Conflict models.Route
+}
+
+func (e *PathConflictError) Error() string {
+	path := e.Route.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("path %q on %s is ambiguous with route %q (id %d); change the path or give one route a higher priority",
+		path, e.Route.Domain, e.Conflict.Name, e.Conflict.ID)
+}
+
+// NormalizePath cleans a route path matcher. The catch-all path is stored as
+// an empty string, prefixes lose their trailing slash, and globs are kept
+// verbatim apart from the leading slash.
+func NormalizePath(p string) string {
+	p = strings.TrimSpace(p)
+	if p == "" || p == "/" || p == "*" || p == "/*" {
+		return ""
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	if !isGlob(p) {
+		p = strings.TrimRight(p, "/")
+	}
+	return p
+}
+
+// ValidatePath checks a normalized path matcher. Whitespace, control
+// characters, quotes, braces and query or fragment markers are rejected;
+// they are not part of a request path and could break the Caddyfile.
+func ValidatePath(p string) error {
+	for _, c := range p {
+		if c <= ' ' || c == 0x7f || strings.ContainsRune("{}\"'`\\?#", c) {
+			return fmt.Errorf("invalid path %q: %q is not allowed", p, c)
+		}
+	}
+	return nil
+}
+
+// FindPathConflict checks a route against the other routes on its domain.
+// Routes with the same ID as r are ignored so updates don't conflict with
+// themselves.
+func FindPathConflict(r models.Route, others []models.Route) error {
+	for _, o := range others {
+		if o.ID == r.ID || o.Domain != r.Domain {
+			continue
+		}
+		if pathsAmbiguous(r, o) {
+			return &PathConflictError{Route: r, Conflict: o}
+		}
+	}
+	return nil
+}
+
+// groupByDomain groups routes by domain, keeping the order in which each
+// domain first appears.
+func groupByDomain(routes []models.Route) (domains []string, byDomain map[string][]models.Route) {
+	byDomain = make(map[string][]models.Route)
+	for _, r := range routes {
+		if _, ok := byDomain[r.Domain]; !ok {
+			domains = append(domains, r.Domain)
+		}
+		byDomain[r.Domain] = append(byDomain[r.Domain], r)
+	}
+	return domains, byDomain
+}
+
+// SortRoutesForDomain orders routes sharing a domain in evaluation order:
+// highest priority first, then the most specific path.
+func SortRoutesForDomain(routes []models.Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Priority != routes[j].Priority {
+			return routes[i].Priority > routes[j].Priority
+		}
+		return len(pathLiteral(routes[i].Path)) > len(pathLiteral(routes[j].Path))
+	})
+}
+
+// pathsAmbiguous reports whether two routes would match the same requests
+// with the same precedence. Identical paths always conflict; prefix-style
+// matchers conflict when they share a literal prefix and priority.
+func pathsAmbiguous(a, b models.Route) bool {
+	if a.Path == b.Path {
+		return true
+	}
+	if a.Priority != b.Priority {
+		return false
+	}
+	if !isPrefixLike(a.Path) || !isPrefixLike(b.Path) {
+		return false
+	}
+	return pathLiteral(a.Path) == pathLiteral(b.Path)
+}
+
+// pathMatchers returns the Caddy path matcher arguments for a route path.
+// A prefix "/api" matches both "/api" itself and everything below it.
+func pathMatchers(p string) []string {
+	switch {
+	case p == "":
+		return nil
+	case isGlob(p):
+		return []string{p}
+	default:
+		return []string{p, p + "/*"}
+	}
+}
+
+// stripPrefixFor returns the prefix removed from the request URI when a
+// route has StripPrefix set. Globs strip up to the last slash before the
+// first wildcard.
+func stripPrefixFor(p string) string {
+	if !isGlob(p) {
+		return p
+	}
+	lit := p[:strings.Index(p, "*")]
+	if idx := strings.LastIndex(lit, "/"); idx >= 0 {
+		lit = lit[:idx]
+	}
+	return lit
+}
+
+// pathLiteral returns the non-wildcard leading part of a path, without a
+// trailing slash. It is used as the specificity of a matcher.
+func pathLiteral(p string) string {
+	if idx := strings.Index(p, "*"); idx >= 0 {
+		p = p[:idx]
+	}
+	return strings.TrimRight(p, "/")
+}
+
+// isPrefixLike reports whether a path matches everything under a literal
+// prefix, i.e. it is a plain prefix or a glob with a single trailing "*".
+func isPrefixLike(p string) bool {
+	return !isGlob(p) || strings.Index(p, "*") == len(p)-1
+}
+
+func isGlob(p string) bool {
+	return strings.Contains(p, "*")
+}
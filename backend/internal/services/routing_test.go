@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"/*", ""},
+		{"api", "/api"},
+		{"/api/", "/api"},
+		{" /api ", "/api"},
+		{"/api/*", "/api/*"},
+		{"static/*.css", "/static/*.css"},
+	}
+
+	for _, tt := range tests {
+		if result := NormalizePath(tt.in); result != tt.expected {
+			t.Errorf("NormalizePath(%q) = %q; want %q", tt.in, result, tt.expected)
+		}
+	}
+}
+
+func TestFindPathConflict(t *testing.T) {
+	existing := []models.Route{
+		{ID: 1, Name: "frontend", Domain: "app.test", Path: ""},
+		{ID: 2, Name: "api", Domain: "app.test", Path: "/api"},
+		{ID: 3, Name: "css", Domain: "app.test", Path: "/static/*.css"},
+	}
+
+	tests := []struct {
+		name     string
+		route    models.Route
+		conflict bool
+	}{
+		{"same catch-all", models.Route{Domain: "app.test", Path: ""}, true},
+		{"same prefix", models.Route{Domain: "app.test", Path: "/api"}, true},
+		{"equivalent glob", models.Route{Domain: "app.test", Path: "/api/*"}, true},
+		{"equivalent glob with priority", models.Route{Domain: "app.test", Path: "/api/*", Priority: 10}, false},
+		{"more specific prefix", models.Route{Domain: "app.test", Path: "/api/v2"}, false},
+		{"different suffix glob", models.Route{Domain: "app.test", Path: "/static/*.js"}, false},
+		{"other domain", models.Route{Domain: "other.test", Path: "/api"}, false},
+		{"update of itself", models.Route{ID: 2, Domain: "app.test", Path: "/api"}, false},
+	}
+
+	for _, tt := range tests {
+		err := FindPathConflict(tt.route, existing)
+		if (err != nil) != tt.conflict {
+			t.Errorf("%s: FindPathConflict() error = %v; want conflict %v", tt.name, err, tt.conflict)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"devproxy/internal/database"
+)
+
+// newTestEnv gives a test a fresh database and a stub Caddy admin API that
+// answers with caddy, or accepts everything if caddy is nil. It returns the
+// path the Caddy config is written to. The database is closed and the Caddy
+// state, including the applied config, is reset when the test ends.
+func newTestEnv(t *testing.T, caddy http.HandlerFunc) string {
+	t.Helper()
+	if err := database.Init(filepath.Join(t.TempDir(), "devproxy.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	if caddy == nil {
+		caddy = func(http.ResponseWriter, *http.Request) {}
+	}
+	srv := httptest.NewServer(caddy)
+	t.Cleanup(srv.Close)
+
+	config, caddyfile, api := configPath, caddyfilePath, caddyAPI
+	configFile := filepath.Join(t.TempDir(), "caddy.json")
+	InitCaddy(configFile, "", srv.URL)
+	t.Cleanup(func() {
+		InitCaddy(config, caddyfile, api)
+		appliedStateMux.Lock()
+		appliedConfig, appliedState = nil, nil
+		appliedStateMux.Unlock()
+	})
+	return configFile
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+// RootCA describes Caddy's local certificate authority used for internal TLS.
+type RootCA struct {
+	Name        string    `json:"name"`
+	CommonName  string    `json:"common_name"`
+	Fingerprint string    `json:"fingerprint_sha256"`
+	NotAfter    time.Time `json:"not_after"`
+	PEM         string    `json:"pem"`
+}
+
+// NormalizeTLSMode validates a route TLS mode. "off" is accepted as an alias
+// for plain HTTP.
+func NormalizeTLSMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case models.TLSModeOff, "off":
+		return models.TLSModeOff, nil
+	case models.TLSModeInternal:
+		return models.TLSModeInternal, nil
+	default:
+		return "", fmt.Errorf("unsupported tls_mode %q (use \"\" or %q)", mode, models.TLSModeInternal)
+	}
+}
+
+// GetRootCA fetches the root certificate of Caddy's local CA from the admin
+// API. Caddy only creates the CA once a site uses "tls internal", so this
+// fails until at least one TLS route has been applied.
+func GetRootCA() (*RootCA, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(caddyAPI + "/pki/ca/local")
+	if err != nil {
+		return nil, fmt.Errorf("connect to Caddy admin API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Caddy returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var info struct {
+		Name            string `json:"name"`
+		RootCommonName  string `json:"root_common_name"`
+		RootCertificate string `json:"root_certificate"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("decode Caddy CA info: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(info.RootCertificate))
+	if block == nil {
+		return nil, fmt.Errorf("Caddy returned no root certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse root certificate: %w", err)
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	return &RootCA{
+		Name:        info.Name,
+		CommonName:  info.RootCommonName,
+		Fingerprint: hex.EncodeToString(sum[:]),
+		NotAfter:    cert.NotAfter,
+		PEM:         info.RootCertificate,
+	}, nil
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"devproxy/internal/models"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Traefik dynamic config formats.
+const (
+	TraefikYAML = "yaml"
+	TraefikTOML = "toml"
+)
+
+var (
+	traefikMatcherRe  = regexp.MustCompile("^(\\w+)\\((.*)\\)$")
+	traefikArgRe      = regexp.MustCompile("`([^`]*)`|\"([^\"]*)\"")
+	tomlTableRe       = regexp.MustCompile(`^\[\[?\s*([^\]]+?)\s*\]\]?`)
+	tomlKeyRe         = regexp.MustCompile(`^([A-Za-z0-9_."-]+?)\s*=`)
+	traefikIgnoredKey = map[string]bool{"entrypoints": true, "observability": true}
+)
+
+// traefikConfig is a decoded dynamic config with the line of every key,
+// keyed by the lowercased dotted path such as "http.routers.app.rule".
+type traefikConfig struct {
+	root  map[string]interface{}
+	lines map[string]int
+}
+
+// line returns the line of a key, or of its closest parent that has one.
+func (c traefikConfig) line(path ...string) int {
+	for n := len(path); n > 0; n-- {
+		if l, ok := c.lines[strings.ToLower(strings.Join(path[:n], "."))]; ok {
+			return l
+		}
+	}
+	return 0
+}
+
+// DetectTraefikFormat guesses whether a dynamic config is TOML or YAML from
+// its first table header or key.
+func DetectTraefikFormat(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") || tomlKeyRe.MatchString(line) {
+			return TraefikTOML
+		}
+		return TraefikYAML
+	}
+	return TraefikYAML
+}
+
+// ParseTraefik translates the HTTP routers of a Traefik dynamic config, in
+// YAML or TOML, to routes. Host and PathPrefix rules, load balancer
+// services and the stripPrefix, headers, ipAllowList and basicAuth
+// middlewares are translated; everything else is reported as an issue with
+// its line.
+func ParseTraefik(data []byte, format string) (ConfigPreview, error) {
+	cfg := traefikConfig{lines: make(map[string]int)}
+	switch format {
+	case TraefikTOML:
+		if err := toml.Unmarshal(data, &cfg.root); err != nil {
+			return ConfigPreview{}, fmt.Errorf("invalid TOML: %v", err)
+		}
+		indexTOMLLines(data, cfg.lines)
+	case TraefikYAML:
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return ConfigPreview{}, fmt.Errorf("invalid YAML: %v", err)
+		}
+		if err := node.Decode(&cfg.root); err != nil {
+			return ConfigPreview{}, fmt.Errorf("invalid YAML: %v", err)
+		}
+		indexYAMLLines(&node, "", cfg.lines)
+	default:
+		return ConfigPreview{}, fmt.Errorf("invalid format %q, use yaml or toml", format)
+	}
+
+	t := &traefikTranslator{cfg: cfg}
+	for _, key := range sortedKeys(cfg.root) {
+		switch strings.ToLower(key) {
+		case "http":
+			http, _ := cfg.root[key].(map[string]interface{})
+			t.http(key, http)
+		case "tcp", "udp":
+			t.issue(cfg.line(key), "", "%s routers are not supported", strings.ToUpper(key))
+		default:
+			t.issue(cfg.line(key), "", "%s is not translated", key)
+		}
+	}
+	return newConfigPreview("traefik", t.routes, t.issues), nil
+}
+
+type traefikTranslator struct {
+	cfg         traefikConfig
+	services    map[string]interface{}
+	middlewares map[string]interface{}
+	routes      []models.ImportRoute
+	issues      []ImportIssue
+}
+
+func (t *traefikTranslator) issue(line int, context, format string, args ...interface{}) {
+	t.issues = append(t.issues, ImportIssue{Line: line, Context: context, Message: fmt.Sprintf(format, args...)})
+}
+
+func (t *traefikTranslator) http(key string, http map[string]interface{}) {
+	var routers map[string]interface{}
+	for _, k := range sortedKeys(http) {
+		m, _ := http[k].(map[string]interface{})
+		switch strings.ToLower(k) {
+		case "routers":
+			routers = m
+		case "services":
+			t.services = m
+		case "middlewares":
+			t.middlewares = m
+		default:
+			t.issue(t.cfg.line(key, k), "", "http.%s is not translated", k)
+		}
+	}
+	for _, name := range sortedKeys(routers) {
+		router, _ := routers[name].(map[string]interface{})
+		t.router([]string{key, "routers", name}, name, router)
+	}
+}
+
+// router translates one router and its service and middlewares.
+func (t *traefikTranslator) router(path []string, name string, router map[string]interface{}) {
+	context := "router " + name
+	at := func(keys ...string) int { return t.cfg.line(append(append([]string{}, path...), keys...)...) }
+
+	rule, _ := lookup(router, "rule").(string)
+	hosts, routePath, err := parseTraefikRule(rule)
+	if err != nil {
+		t.issue(at("rule"), context, "rule %s: %v", rule, err)
+		return
+	}
+	if len(hosts) == 0 {
+		t.issue(at("rule"), context, "rule %s has no Host matcher", rule)
+		return
+	}
+	if strings.Contains(rule, "Path(") {
+		t.issue(at("rule"), context, "Path is an exact match and becomes a prefix match")
+	}
+
+	route := models.ImportRoute{
+		Name:    name,
+		Domain:  hosts[0],
+		Aliases: hosts[1:],
+		Path:    routePath,
+		Kind:    models.RouteKindProxy,
+		Enabled: true,
+	}
+
+	for _, key := range sortedKeys(router) {
+		value := router[key]
+		switch strings.ToLower(key) {
+		case "rule", "service", "middlewares":
+		case "priority":
+			if p, ok := toInt(value); ok {
+				route.Priority = p
+			} else {
+				t.issue(at(key), context, "priority %v is not a number", value)
+			}
+		case "tls":
+			route.TLSMode = models.TLSModeInternal
+			if tls, ok := value.(map[string]interface{}); ok && len(tls) > 0 {
+				t.issue(at(key), context, "TLS options are not imported; the route uses DevProxy's local CA")
+			}
+		default:
+			if !traefikIgnoredKey[strings.ToLower(key)] {
+				t.issue(at(key), context, "%s is not translated", key)
+			}
+		}
+	}
+
+	service, _ := lookup(router, "service").(string)
+	if !t.service(&route, service, context, at("service")) {
+		return
+	}
+	middlewares, _ := lookup(router, "middlewares").([]interface{})
+	for _, m := range middlewares {
+		t.middleware(&route, fmt.Sprint(m), context, at("middlewares"))
+	}
+	t.routes = append(t.routes, route)
+}
+
+// service sets the target of a route from its load balancer service.
+func (t *traefikTranslator) service(route *models.ImportRoute, name, context string, line int) bool {
+	name, _, _ = strings.Cut(name, "@")
+	if name == "" {
+		t.issue(line, context, "router has no service")
+		return false
+	}
+	def, _ := t.services[name].(map[string]interface{})
+	if def == nil {
+		t.issue(line, context, "service %s is not defined in this file", name)
+		return false
+	}
+	path := []string{"http", "services", name}
+	context = context + " service " + name
+
+	lb, _ := lookup(def, "loadBalancer").(map[string]interface{})
+	if lb == nil {
+		for _, kind := range sortedKeys(def) {
+			t.issue(t.cfg.line(append(path, kind)...), context, "%s services are not supported", kind)
+		}
+		return false
+	}
+	servers, _ := lookup(lb, "servers").([]interface{})
+	if len(servers) == 0 {
+		t.issue(t.cfg.line(append(path, "loadBalancer")...), context, "load balancer has no servers")
+		return false
+	}
+	if len(servers) > 1 {
+		t.issue(t.cfg.line(append(path, "loadBalancer", "servers")...), context, "only the first of %d servers is used", len(servers))
+	}
+	server, _ := servers[0].(map[string]interface{})
+	raw, _ := lookup(server, "url").(string)
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		t.issue(t.cfg.line(append(path, "loadBalancer", "servers")...), context, "server URL %q is not an http(s) URL", raw)
+		return false
+	}
+	if u.Path != "" && u.Path != "/" {
+		t.issue(t.cfg.line(append(path, "loadBalancer", "servers")...), context, "the path %s of the server URL is not translated", u.Path)
+	}
+	route.Target = u.Host
+	if u.Scheme == "https" {
+		route.Target = "https://" + u.Host
+	}
+
+	for _, key := range sortedKeys(lb) {
+		switch strings.ToLower(key) {
+		case "servers", "passhostheader":
+		case "healthcheck":
+			hc, _ := lb[key].(map[string]interface{})
+			route.HealthCheck.Path, _ = lookup(hc, "path").(string)
+			if d, ok := traefikDuration(lookup(hc, "interval")); ok {
+				route.HealthCheck.IntervalSeconds = d
+			}
+			if d, ok := traefikDuration(lookup(hc, "timeout")); ok {
+				route.HealthCheck.TimeoutSeconds = d
+			}
+		default:
+			t.issue(t.cfg.line(append(path, "loadBalancer", key)...), context, "loadBalancer.%s is not translated", key)
+		}
+	}
+	return true
+}
+
+// middleware applies a middleware to a route, or reports it.
+func (t *traefikTranslator) middleware(route *models.ImportRoute, name, context string, line int) {
+	name, _, _ = strings.Cut(name, "@")
+	def, _ := t.middlewares[name].(map[string]interface{})
+	if def == nil {
+		t.issue(line, context, "middleware %s is not defined in this file", name)
+		return
+	}
+	context = context + " middleware " + name
+	path := []string{"http", "middlewares", name}
+
+	for _, kind := range sortedKeys(def) {
+		opts, _ := def[kind].(map[string]interface{})
+		at := t.cfg.line(append(path, kind)...)
+		switch strings.ToLower(kind) {
+		case "stripprefix":
+			prefixes, _ := lookup(opts, "prefixes").([]interface{})
+			if len(prefixes) == 1 && NormalizePath(fmt.Sprint(prefixes[0])) == route.Path && route.Path != "" {
+				route.StripPrefix = true
+			} else {
+				t.issue(at, context, "stripPrefix only translates when it strips the router's PathPrefix")
+			}
+		case "headers":
+			t.headers(route, opts, path, kind, context)
+		case "ipallowlist", "ipwhitelist":
+			ranges, _ := lookup(opts, "sourceRange").([]interface{})
+			for _, r := range ranges {
+				route.AllowCIDRs = append(route.AllowCIDRs, fmt.Sprint(r))
+			}
+			for _, key := range sortedKeys(opts) {
+				if !strings.EqualFold(key, "sourceRange") {
+					t.issue(t.cfg.line(append(path, kind, key)...), context, "%s.%s is not translated", kind, key)
+				}
+			}
+		case "basicauth":
+			users, _ := lookup(opts, "users").([]interface{})
+			for _, u := range users {
+				username, hash, _ := strings.Cut(fmt.Sprint(u), ":")
+				if !strings.HasPrefix(hash, "$2") {
+					t.issue(t.cfg.line(append(path, kind, "users")...), context, "password of %s is not a bcrypt hash; set it again with PUT /api/routes/:id/users/:username", username)
+					continue
+				}
+				route.BasicAuth = append(route.BasicAuth, models.BasicAuthUser{Username: username, PasswordHash: hash})
+			}
+			for _, key := range sortedKeys(opts) {
+				if !strings.EqualFold(key, "users") {
+					t.issue(t.cfg.line(append(path, kind, key)...), context, "%s.%s is not translated", kind, key)
+				}
+			}
+		default:
+			t.issue(at, context, "%s middlewares are not supported", kind)
+		}
+	}
+}
+
+// headers translates custom request and response headers; an empty value
+// removes the header, as in Traefik.
+func (t *traefikTranslator) headers(route *models.ImportRoute, opts map[string]interface{}, path []string, kind, context string) {
+	for _, key := range sortedKeys(opts) {
+		var ops *models.HeaderOps
+		switch strings.ToLower(key) {
+		case "customrequestheaders":
+			ops = &route.Headers.Request
+		case "customresponseheaders":
+			ops = &route.Headers.Response
+		default:
+			t.issue(t.cfg.line(append(path, kind, key)...), context, "headers.%s is not translated", key)
+			continue
+		}
+		values, _ := opts[key].(map[string]interface{})
+		for _, name := range sortedKeys(values) {
+			value := fmt.Sprint(values[name])
+			if value == "" {
+				ops.Delete = append(ops.Delete, name)
+				continue
+			}
+			if ops.Set == nil {
+				ops.Set = make(map[string]string)
+			}
+			ops.Set[name] = value
+		}
+	}
+}
+
+// parseTraefikRule reads rules made of Host matchers, optionally combined
+// with || and one PathPrefix or Path matcher joined with &&.
+func parseTraefikRule(rule string) (hosts []string, path string, err error) {
+	rule = strings.TrimSpace(rule)
+	if rule == "" {
+		return nil, "", fmt.Errorf("empty rule")
+	}
+	if strings.Contains(rule, "!") {
+		return nil, "", fmt.Errorf("negated matchers are not supported")
+	}
+	for _, term := range strings.Split(rule, "&&") {
+		var matchers []string
+		for _, m := range strings.Split(trimParens(term), "||") {
+			matchers = append(matchers, trimParens(m))
+		}
+		for _, m := range matchers {
+			sub := traefikMatcherRe.FindStringSubmatch(m)
+			if sub == nil {
+				return nil, "", fmt.Errorf("%s is not understood", m)
+			}
+			var args []string
+			for _, a := range traefikArgRe.FindAllStringSubmatch(sub[2], -1) {
+				args = append(args, a[1]+a[2])
+			}
+			if len(args) == 0 {
+				return nil, "", fmt.Errorf("%s has no argument", m)
+			}
+			switch sub[1] {
+			case "Host":
+				for _, h := range args {
+					hosts = append(hosts, strings.ToLower(h))
+				}
+			case "PathPrefix", "Path":
+				if path != "" || len(matchers) > 1 || len(args) > 1 {
+					return nil, "", fmt.Errorf("only one path matcher is supported")
+				}
+				if strings.ContainsAny(args[0], "{}") {
+					return nil, "", fmt.Errorf("path %s uses a regular expression", args[0])
+				}
+				path = NormalizePath(args[0])
+			default:
+				return nil, "", fmt.Errorf("%s matchers are not supported", sub[1])
+			}
+		}
+	}
+	return hosts, path, nil
+}
+
+// trimParens removes spaces and parentheses enclosing a whole expression.
+func trimParens(s string) string {
+	for {
+		s = strings.TrimSpace(s)
+		if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+			return s
+		}
+		depth := 0
+		for i, c := range s {
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				depth--
+			}
+			if depth == 0 && i < len(s)-1 {
+				return s
+			}
+		}
+		s = s[1 : len(s)-1]
+	}
+}
+
+// lookup finds a key case-insensitively, as Traefik reads its config.
+func lookup(m map[string]interface{}, key string) interface{} {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), n == float64(int(n))
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// traefikDuration reads a duration such as "10s" in whole seconds.
+func traefikDuration(v interface{}) (int, bool) {
+	if n, ok := toInt(v); ok {
+		return n, n > 0
+	}
+	s, _ := v.(string)
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Second {
+		return 0, false
+	}
+	return int(d / time.Second), true
+}
+
+// indexYAMLLines records the line of every mapping key under prefix.
+func indexYAMLLines(n *yaml.Node, prefix string, lines map[string]int) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			indexYAMLLines(c, prefix, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := strings.ToLower(n.Content[i].Value)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			lines[key] = n.Content[i].Line
+			indexYAMLLines(n.Content[i+1], key, lines)
+		}
+	}
+}
+
+// indexTOMLLines records the line of every table header and key. Inline
+// tables and multi-line values are attributed to the line of their key.
+func indexTOMLLines(data []byte, lines map[string]int) {
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if m := tomlTableRe.FindStringSubmatch(line); m != nil {
+			table = tomlKey(m[1])
+			parts := strings.Split(table, ".")
+			for i := range parts {
+				if parent := strings.Join(parts[:i+1], "."); lines[parent] == 0 {
+					lines[parent] = n
+				}
+			}
+			continue
+		}
+		if m := tomlKeyRe.FindStringSubmatch(line); m != nil {
+			key := tomlKey(m[1])
+			if table != "" {
+				key = table + "." + key
+			}
+			if _, ok := lines[key]; !ok {
+				lines[key] = n
+			}
+		}
+	}
+}
+
+// tomlKey normalizes a dotted TOML key, dropping quotes around its parts.
+func tomlKey(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		parts[i] = strings.ToLower(strings.Trim(strings.TrimSpace(p), `"'`))
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+const testTraefikYAML = `http:
+  routers:
+    shop:
+      rule: "Host(` + "`shop.test`" + `) || Host(` + "`www.shop.test`" + `)"
+      service: shop
+      entryPoints: [web]
+    api:
+      rule: "Host(` + "`shop.test`" + `) && PathPrefix(` + "`/api`" + `)"
+      service: api@file
+      middlewares: [strip, office, auth, compress]
+      priority: 10
+      tls:
+        certResolver: le
+    admin:
+      rule: "Host(` + "`admin.test`" + `) && Method(` + "`POST`" + `)"
+      service: shop
+  services:
+    shop:
+      loadBalancer:
+        servers:
+          - url: http://shop-web-1:8080
+          - url: http://shop-web-2:8080
+        healthCheck:
+          path: /health
+          interval: 10s
+    api:
+      loadBalancer:
+        servers:
+          - url: http://shop-api-1:3000/
+  middlewares:
+    strip:
+      stripPrefix:
+        prefixes: ["/api"]
+    office:
+      ipAllowList:
+        sourceRange: ["10.0.0.0/8"]
+    auth:
+      basicAuth:
+        users:
+          - "dev:$2y$05$abcdefghijklmnopqrstuu5Ke3JVe3VDY4R3i0K2Z5Dg5sT6C7OBW"
+          - "ops:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"
+    compress:
+      compress: {}
+tcp:
+  routers:
+    db:
+      rule: "HostSNI(` + "`*`" + `)"
+`
+
+const testTraefikTOML = `[http.routers.shop]
+  rule = "Host(` + "`shop.test`" + `) || Host(` + "`www.shop.test`" + `)"
+  service = "shop"
+
+[http.routers.api]
+  rule = "Host(` + "`shop.test`" + `) && PathPrefix(` + "`/api`" + `)"
+  service = "api@file"
+  middlewares = ["strip", "office", "auth", "compress"]
+  priority = 10
+  [http.routers.api.tls]
+    certResolver = "le"
+
+[http.routers.admin]
+  rule = "Host(` + "`admin.test`" + `) && Method(` + "`POST`" + `)"
+  service = "shop"
+
+[http.services.shop.loadBalancer]
+  [[http.services.shop.loadBalancer.servers]]
+    url = "http://shop-web-1:8080"
+  [[http.services.shop.loadBalancer.servers]]
+    url = "http://shop-web-2:8080"
+  [http.services.shop.loadBalancer.healthCheck]
+    path = "/health"
+    interval = "10s"
+
+[http.services.api.loadBalancer]
+  [[http.services.api.loadBalancer.servers]]
+    url = "http://shop-api-1:3000/"
+
+[http.middlewares.strip.stripPrefix]
+  prefixes = ["/api"]
+[http.middlewares.office.ipAllowList]
+  sourceRange = ["10.0.0.0/8"]
+[http.middlewares.auth.basicAuth]
+  users = ["dev:$2y$05$abcdefghijklmnopqrstuu5Ke3JVe3VDY4R3i0K2Z5Dg5sT6C7OBW", "ops:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"]
+[http.middlewares.compress.compress]
+
+[tcp.routers.db]
+  rule = "HostSNI(` + "`*`" + `)"
+`
+
+func TestParseTraefik(t *testing.T) {
+	tests := []struct {
+		format string
+		data   string
+		issues map[int]string
+	}{
+		{TraefikYAML, testTraefikYAML, map[int]string{
+			15: "Method matchers",
+			12: "TLS options",
+			20: "only the first of 2 servers",
+			39: "not a bcrypt hash",
+			43: "compress middlewares",
+			44: "TCP routers",
+		}},
+		{TraefikTOML, testTraefikTOML, map[int]string{
+			14: "Method matchers",
+			10: "TLS options",
+			18: "only the first of 2 servers",
+			35: "not a bcrypt hash",
+			36: "compress middlewares",
+			38: "TCP routers",
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := DetectTraefikFormat([]byte(tt.data)); got != tt.format {
+			t.Errorf("DetectTraefikFormat = %q, want %q", got, tt.format)
+		}
+		preview, err := ParseTraefik([]byte(tt.data), tt.format)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+
+		routes := preview.Document.Routes
+		if len(routes) != 2 {
+			t.Fatalf("%s: got %d routes: %+v", tt.format, len(routes), routes)
+		}
+		api, shop := routes[0], routes[1]
+		if api.Domain != "shop.test" || api.Path != "/api" || !api.StripPrefix || api.Target != "shop-api-1:3000" ||
+			api.Priority != 10 || api.TLSMode != models.TLSModeInternal || len(api.AllowCIDRs) != 1 || len(api.BasicAuth) != 1 {
+			t.Errorf("%s: api route = %+v", tt.format, api)
+		}
+		if shop.Domain != "shop.test" || strings.Join(shop.Aliases, ",") != "www.shop.test" || shop.Target != "shop-web-1:8080" ||
+			shop.HealthCheck.Path != "/health" || shop.HealthCheck.IntervalSeconds != 10 {
+			t.Errorf("%s: shop route = %+v", tt.format, shop)
+		}
+
+		for line, msg := range tt.issues {
+			found := false
+			for _, i := range preview.Issues {
+				if i.Line == line && strings.Contains(i.Message, msg) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s: no issue %q on line %d in %+v", tt.format, msg, line, preview.Issues)
+			}
+		}
+	}
+}
+
+func TestParseTraefikRule(t *testing.T) {
+	tests := []struct {
+		rule  string
+		hosts string
+		path  string
+		valid bool
+	}{
+		{"Host(`a.test`)", "a.test", "", true},
+		{"Host(`a.test`, `b.test`)", "a.test,b.test", "", true},
+		{"(Host(`a.test`) || Host(`B.test`)) && PathPrefix(`/api/`)", "a.test,b.test", "/api", true},
+		{"Host(\"a.test\") && Path(`/exact`)", "a.test", "/exact", true},
+		{"HostRegexp(`{sub:[a-z]+}.test`)", "", "", false},
+		{"Host(`a.test`) && !PathPrefix(`/admin`)", "", "", false},
+		{"Host(`a.test`) && (PathPrefix(`/a`) || PathPrefix(`/b`))", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		hosts, path, err := parseTraefikRule(tt.rule)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseTraefikRule(%q) error = %v", tt.rule, err)
+			continue
+		}
+		if tt.valid && (strings.Join(hosts, ",") != tt.hosts || path != tt.path) {
+			t.Errorf("parseTraefikRule(%q) = %v, %q", tt.rule, hosts, path)
+		}
+	}
+}
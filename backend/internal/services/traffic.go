@@ -0,0 +1,502 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"devproxy/internal/models"
+)
+
+// trafficLogger is the Caddy logger that receives the access logs.
+const trafficLogger = "devproxy_access"
+
+// maxTrafficLine caps a single access log line, which mostly consists of
+// headers. With body capture, bodies are not capped inside Caddy; they are
+// logged in full and only cut to the body limit here, so lines may be up to
+// maxTrafficBodyLine long. Longer lines are skipped.
+const (
+	maxTrafficLine     = 1 << 20
+	maxTrafficBodyLine = 64 << 20
+)
+
+var (
+	// trafficSinkAddress is the address Caddy sends access logs to. Capture
+	// is off when it is empty.
+	trafficSinkAddress string
+	trafficBufferSize  = 200
+	// trafficBodyLimit is the number of bytes kept of request and response
+	// bodies. Bodies are not captured when it is 0.
+	trafficBodyLimit int
+
+	trafficMu      sync.RWMutex
+	trafficBuffers = make(map[int64]*trafficRing)
+	trafficLastID  int64
+	trafficSubs    = make(map[chan models.TrafficEntry]struct{})
+)
+
+// ConfigureTraffic enables request capture. sinkAddress is where Caddy
+// reaches the listener started by StartTrafficSink, e.g. "api:9300".
+// bufferSize is the number of requests kept per route; 0 keeps the default.
+// bodyLimit is the number of bytes kept of each request and response body;
+// 0 leaves bodies out.
+func ConfigureTraffic(sinkAddress string, bufferSize, bodyLimit int) {
+	trafficSinkAddress = sinkAddress
+	if bufferSize > 0 {
+		trafficBufferSize = bufferSize
+	}
+	trafficBodyLimit = bodyLimit
+}
+
+// TrafficEnabled reports whether request capture is configured.
+func TrafficEnabled() bool {
+	return trafficSinkAddress != ""
+}
+
+// trafficBodiesEnabled reports whether request and response bodies are
+// captured.
+func trafficBodiesEnabled() bool {
+	return TrafficEnabled() && trafficBodyLimit > 0
+}
+
+// trafficBodyHandlers add the request and response bodies to the access log
+// entry of a request. Caddy cannot cap them: it buffers and logs both in
+// full, and trafficBodyLimit only caps what is kept here.
+func trafficBodyHandlers() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"handler": "log_append", "key": "request_body", "value": "{http.request.body}"},
+		{"handler": "log_append", "key": "response_body", "value": "{http.response.body}"},
+	}
+}
+
+// addTrafficLogging makes Caddy write the access logs of every server as
+// JSON lines to the traffic sink. soft_start keeps Caddy running while the
+// sink is unreachable.
+func addTrafficLogging(cfg *caddyConfig) {
+	cfg.Logging = &caddyLogging{Logs: map[string]*caddyLog{
+		"default": {Exclude: []string{"http.log.access." + trafficLogger}},
+		trafficLogger: {
+			Writer: map[string]interface{}{
+				"output":     "net",
+				"address":    trafficSinkAddress,
+				"soft_start": true,
+			},
+			Encoder: map[string]interface{}{"format": "json"},
+			Include: []string{"http.log.access." + trafficLogger},
+		},
+	}}
+	for _, srv := range cfg.Apps.HTTP.Servers {
+		srv.Logs = &caddyServerLogs{DefaultLoggerName: trafficLogger}
+	}
+}
+
+// StartTrafficSink accepts access log connections from Caddy on listen.
+// Connections from other hosts are closed.
+func StartTrafficSink(listen string) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Printf("Traffic capture disabled: %v", err)
+		return
+	}
+	log.Printf("Capturing traffic from Caddy access logs on %s", listen)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("Error accepting access log connection: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		// The backend shares the dev-proxy network with project containers,
+		// which must not inject entries.
+		if !IsCaddyPeer(conn.RemoteAddr().String()) {
+			log.Printf("Rejected access log connection from %s: not Caddy", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		go readAccessLogs(conn)
+	}
+}
+
+func readAccessLogs(conn net.Conn) {
+	defer conn.Close()
+	limit := maxTrafficLine
+	if trafficBodiesEnabled() {
+		limit = maxTrafficBodyLine
+	}
+	reader := bufio.NewReaderSize(conn, 64*1024)
+	for {
+		line, err := readTrafficLine(reader, limit)
+		if entry, ok := parseAccessLog(line); ok {
+			appliedStateMux.RLock()
+			entry.RouteID = matchTrafficRoute(appliedConfig, entry)
+			appliedStateMux.RUnlock()
+			recordTraffic(entry)
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading access logs: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// readTrafficLine reads one line of at most limit bytes. Longer lines are
+// discarded and returned as nil, so one huge request does not end the
+// connection.
+func readTrafficLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) > limit {
+			log.Printf("Skipping access log line longer than %d bytes", limit)
+			line, tooLong = nil, true
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// caddyAccessLog is the part of a Caddy access log entry DevProxy keeps.
+type caddyAccessLog struct {
+	Timestamp float64 `json:"ts"`
+	Message   string  `json:"msg"`
+	Request   struct {
+		RemoteIP string              `json:"remote_ip"`
+		ClientIP string              `json:"client_ip"`
+		Proto    string              `json:"proto"`
+		Method   string              `json:"method"`
+		Host     string              `json:"host"`
+		URI      string              `json:"uri"`
+		Headers  map[string][]string `json:"headers"`
+		TLS      *json.RawMessage    `json:"tls"`
+	} `json:"request"`
+	BytesRead    int64               `json:"bytes_read"`
+	Duration     float64             `json:"duration"`
+	Size         int64               `json:"size"`
+	Status       int                 `json:"status"`
+	RespHeaders  map[string][]string `json:"resp_headers"`
+	RequestBody  string              `json:"request_body"`
+	ResponseBody string              `json:"response_body"`
+}
+
+// parseAccessLog decodes one JSON access log line. Lines that are not
+// request logs are skipped.
+func parseAccessLog(line []byte) (models.TrafficEntry, bool) {
+	var l caddyAccessLog
+	if err := json.Unmarshal(line, &l); err != nil || l.Request.Method == "" {
+		return models.TrafficEntry{}, false
+	}
+
+	sec, frac := math.Modf(l.Timestamp)
+	e := models.TrafficEntry{
+		Time:            time.Unix(int64(sec), int64(frac*1e9)).UTC(),
+		Method:          l.Request.Method,
+		Host:            l.Request.Host,
+		URI:             l.Request.URI,
+		Proto:           l.Request.Proto,
+		TLS:             l.Request.TLS != nil,
+		RemoteIP:        l.Request.ClientIP,
+		Status:          l.Status,
+		Duration:        math.Round(l.Duration*1e6) / 1e3,
+		BytesRead:       l.BytesRead,
+		Size:            l.Size,
+		RequestHeaders:  l.Request.Headers,
+		ResponseHeaders: l.RespHeaders,
+	}
+	if e.RemoteIP == "" {
+		e.RemoteIP = l.Request.RemoteIP
+	}
+	if trafficBodyLimit > 0 {
+		e.RequestBody, e.RequestBodyTruncated = truncateBody(l.RequestBody, trafficBodyLimit)
+		e.ResponseBody, e.ResponseBodyTruncated = truncateBody(l.ResponseBody, trafficBodyLimit)
+	}
+	// Caddy logs 0 when the handler chain did not write a status.
+	if e.Status == 0 {
+		e.Status = 200
+	}
+	return e, true
+}
+
+// truncateBody cuts a body to limit bytes, dropping a rune split at the
+// cut.
+func truncateBody(body string, limit int) (string, bool) {
+	if len(body) <= limit {
+		return body, false
+	}
+	return strings.ToValidUTF8(body[:limit], ""), true
+}
+
+// matchTrafficRoute finds the route that handled a request by evaluating
+// the host and path matchers of the applied config like Caddy does.
+func matchTrafficRoute(cfg *caddyConfig, e models.TrafficEntry) int64 {
+	if cfg == nil {
+		return 0
+	}
+	server := caddyServerHTTP
+	if e.TLS {
+		server = caddyServerHTTPS
+	}
+	srv := cfg.Apps.HTTP.Servers[server]
+	if srv == nil {
+		return 0
+	}
+
+	host := strings.ToLower(e.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	reqPath := e.URI
+	if u, err := url.ParseRequestURI(e.URI); err == nil {
+		reqPath = u.Path
+	}
+
+	for _, site := range srv.Routes {
+		if !matchAny(site.Match, "host", func(p string) bool { return hostMatches(p, host) }) {
+			continue
+		}
+		for _, sub := range siteSubroutes(site) {
+			if !matchAny(sub.Match, "path", func(p string) bool { return caddyPathMatches(p, reqPath) }) {
+				continue
+			}
+			id, _ := strconv.ParseInt(strings.TrimPrefix(sub.ID, "devproxy-route-"), 10, 64)
+			return id
+		}
+		return 0
+	}
+	return 0
+}
+
+// matchAny reports whether a matcher set is empty or one of its values of
+// the given kind matches.
+func matchAny(sets []map[string]interface{}, kind string, match func(string) bool) bool {
+	if len(sets) == 0 {
+		return true
+	}
+	for _, set := range sets {
+		for _, v := range matcherValues(set[kind]) {
+			if match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matcherValues also accepts configs decoded from disk, where matcher
+// values are a []interface{}.
+func matcherValues(v interface{}) []string {
+	switch values := v.(type) {
+	case []string:
+		return values
+	case []interface{}:
+		var out []string
+		for _, s := range values {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// siteSubroutes returns the path routes of a site route.
+func siteSubroutes(site caddyRoute) []caddyRoute {
+	if len(site.Handle) == 0 {
+		return nil
+	}
+	switch routes := site.Handle[0]["routes"].(type) {
+	case []caddyRoute:
+		return routes
+	case nil:
+		return nil
+	default:
+		var decoded []caddyRoute
+		data, _ := json.Marshal(routes)
+		json.Unmarshal(data, &decoded)
+		return decoded
+	}
+}
+
+// hostMatches matches a host against a host matcher value. A leading "*"
+// label matches exactly one label.
+func hostMatches(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if rest, ok := strings.CutPrefix(pattern, "*."); ok {
+		label, parent, found := strings.Cut(host, ".")
+		return found && label != "" && parent == rest
+	}
+	return pattern == host
+}
+
+// caddyPathMatches implements Caddy's path matcher: case-insensitive, with
+// fast paths for prefix ("/api*"), suffix ("*.js") and substring ("*x*")
+// patterns and path.Match globbing otherwise.
+func caddyPathMatches(pattern, p string) bool {
+	pattern = strings.ToLower(pattern)
+	p = strings.ToLower(p)
+	switch strings.Count(pattern, "*") {
+	case 0:
+		return pattern == p
+	case 1:
+		if strings.HasSuffix(pattern, "*") {
+			return strings.HasPrefix(p, strings.TrimSuffix(pattern, "*"))
+		}
+		if strings.HasPrefix(pattern, "*") {
+			return strings.HasSuffix(p, strings.TrimPrefix(pattern, "*"))
+		}
+	case 2:
+		if strings.HasPrefix(pattern, "*") && strings.HasSuffix(pattern, "*") {
+			return strings.Contains(p, strings.Trim(pattern, "*"))
+		}
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+// trafficRing keeps the latest requests of a route.
+type trafficRing struct {
+	entries []models.TrafficEntry
+	next    int
+}
+
+func (r *trafficRing) add(e models.TrafficEntry, size int) {
+	if len(r.entries) < size {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+func recordTraffic(e models.TrafficEntry) models.TrafficEntry {
+	trafficMu.Lock()
+	defer trafficMu.Unlock()
+
+	trafficLastID++
+	e.ID = trafficLastID
+	ring := trafficBuffers[e.RouteID]
+	if ring == nil {
+		ring = &trafficRing{}
+		trafficBuffers[e.RouteID] = ring
+	}
+	ring.add(e, trafficBufferSize)
+
+	summary := trafficSummary(e)
+	for ch := range trafficSubs {
+		// The live tail is best effort; slow clients miss requests.
+		select {
+		case ch <- summary:
+		default:
+		}
+	}
+	return e
+}
+
+// trafficSummary strips the headers and bodies of an entry for lists and
+// the live tail.
+func trafficSummary(e models.TrafficEntry) models.TrafficEntry {
+	e.RequestHeaders = nil
+	e.ResponseHeaders = nil
+	e.RequestBody, e.RequestBodyTruncated = "", false
+	e.ResponseBody, e.ResponseBodyTruncated = "", false
+	return e
+}
+
+// TrafficFilter selects captured requests. Zero fields match everything.
+type TrafficFilter struct {
+	RouteID     *int64
+	StatusClass int // 2 for 2xx, 4 for 4xx, ...
+	Path        string
+}
+
+// Match reports whether an entry passes the filter. Path matches any
+// request URI containing it.
+func (f TrafficFilter) Match(e models.TrafficEntry) bool {
+	if f.RouteID != nil && e.RouteID != *f.RouteID {
+		return false
+	}
+	if f.StatusClass != 0 && e.Status/100 != f.StatusClass {
+		return false
+	}
+	return f.Path == "" || strings.Contains(e.URI, f.Path)
+}
+
+// ListTraffic returns up to limit captured requests matching the filter,
+// newest first and without headers.
+func ListTraffic(f TrafficFilter, limit int) []models.TrafficEntry {
+	trafficMu.RLock()
+	entries := []models.TrafficEntry{}
+	for _, ring := range trafficBuffers {
+		for _, e := range ring.entries {
+			if f.Match(e) {
+				entries = append(entries, trafficSummary(e))
+			}
+		}
+	}
+	trafficMu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// GetTrafficEntry returns a captured request with its headers.
+func GetTrafficEntry(id int64) (models.TrafficEntry, bool) {
+	trafficMu.RLock()
+	defer trafficMu.RUnlock()
+	for _, ring := range trafficBuffers {
+		for _, e := range ring.entries {
+			if e.ID == id {
+				return e, true
+			}
+		}
+	}
+	return models.TrafficEntry{}, false
+}
+
+// ClearTraffic drops the captured requests of a route, or of all routes if
+// routeID is nil.
+func ClearTraffic(routeID *int64) {
+	trafficMu.Lock()
+	defer trafficMu.Unlock()
+	if routeID == nil {
+		trafficBuffers = make(map[int64]*trafficRing)
+		return
+	}
+	delete(trafficBuffers, *routeID)
+}
+
+// SubscribeTraffic streams newly captured requests without headers. Call
+// cancel when done.
+func SubscribeTraffic() (<-chan models.TrafficEntry, func()) {
+	ch := make(chan models.TrafficEntry, 64)
+	trafficMu.Lock()
+	trafficSubs[ch] = struct{}{}
+	trafficMu.Unlock()
+
+	return ch, func() {
+		trafficMu.Lock()
+		delete(trafficSubs, ch)
+		trafficMu.Unlock()
+	}
+}
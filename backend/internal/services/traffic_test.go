@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"devproxy/internal/models"
+)
+
+func TestParseAccessLog(t *testing.T) {
+	line := []byte(`{"level":"info","ts":1700000000.5,"logger":"http.log.access.devproxy_access","msg":"handled request",` +
+		`"request":{"remote_ip":"172.18.0.1","client_ip":"172.18.0.1","proto":"HTTP/1.1","method":"GET","host":"app.test","uri":"/api/users?page=2",` +
+		`"headers":{"User-Agent":["curl/8.0"]}},"bytes_read":0,"duration":0.0123456,"size":42,"status":404,"resp_headers":{"Content-Type":["application/json"]}}`)
+
+	e, ok := parseAccessLog(line)
+	if !ok {
+		t.Fatal("parseAccessLog() rejected a request log")
+	}
+	if e.Method != "GET" || e.Host != "app.test" || e.URI != "/api/users?page=2" || e.Status != 404 || e.TLS {
+		t.Errorf("parseAccessLog() = %+v", e)
+	}
+	if e.Duration != 12.346 || e.Time.Unix() != 1700000000 || e.RequestHeaders["User-Agent"][0] != "curl/8.0" {
+		t.Errorf("duration = %v, time = %v, headers = %v", e.Duration, e.Time, e.RequestHeaders)
+	}
+
+	if _, ok := parseAccessLog([]byte(`{"level":"info","msg":"server running"}`)); ok {
+		t.Error("parseAccessLog() accepted a non-request log")
+	}
+}
+
+func TestMatchTrafficRoute(t *testing.T) {
+	cfg := buildCaddyConfig([]models.Route{
+		{ID: 1, Domain: "app.test", Target: "app:80", Enabled: true},
+		{ID: 2, Domain: "app.test", Path: "/api", Target: "api:80", Enabled: true},
+		{ID: 3, Domain: "*.shop.test", Target: "shop:80", Enabled: true},
+		{ID: 4, Domain: "secure.test", Target: "secure:80", TLSMode: models.TLSModeInternal, Enabled: true},
+	})
+
+	// Configs read back from disk decode matchers as []interface{}.
+	var decoded caddyConfig
+	data, _ := json.Marshal(cfg)
+	json.Unmarshal(data, &decoded)
+
+	tests := []struct {
+		host, uri string
+		tls       bool
+		want      int64
+	}{
+		{"app.test", "/", false, 1},
+		{"APP.test:80", "/api/users?x=1", false, 2},
+		{"app.test", "/api", false, 2},
+		{"app.test", "/apiary", false, 1},
+		{"a.shop.test", "/", false, 3},
+		{"a.b.shop.test", "/", false, 0},
+		{"secure.test", "/", true, 4},
+		{"secure.test", "/", false, 0},
+		{"other.test", "/", false, 0},
+	}
+	for _, c := range []*caddyConfig{cfg, &decoded} {
+		for _, tt := range tests {
+			e := models.TrafficEntry{Host: tt.host, URI: tt.uri, TLS: tt.tls}
+			if got := matchTrafficRoute(c, e); got != tt.want {
+				t.Errorf("matchTrafficRoute(%s%s, tls=%v) = %d, want %d", tt.host, tt.uri, tt.tls, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestTrafficBuffer(t *testing.T) {
+	defer ClearTraffic(nil)
+	size := trafficBufferSize
+	trafficBufferSize = 3
+	defer func() { trafficBufferSize = size }()
+
+	ch, cancel := SubscribeTraffic()
+	defer cancel()
+
+	for i, status := range []int{200, 404, 200, 500, 201} {
+		recordTraffic(models.TrafficEntry{RouteID: 1, Status: status, URI: "/item/" + string(rune('a'+i)),
+			RequestHeaders: map[string][]string{"Accept": {"*/*"}}})
+	}
+	recordTraffic(models.TrafficEntry{RouteID: 2, Status: 200, URI: "/"})
+
+	if e := <-ch; e.RequestHeaders != nil {
+		t.Error("live tail entries should not carry headers")
+	}
+
+	route := int64(1)
+	all := ListTraffic(TrafficFilter{RouteID: &route}, 0)
+	if len(all) != 3 || all[0].URI != "/item/e" || all[2].URI != "/item/c" {
+		t.Errorf("ring buffer kept %+v, want the last 3 requests newest first", all)
+	}
+	if got := ListTraffic(TrafficFilter{StatusClass: 2}, 0); len(got) != 3 {
+		t.Errorf("2xx filter returned %d requests, want 3", len(got))
+	}
+	if got := ListTraffic(TrafficFilter{Path: "/item/d"}, 0); len(got) != 1 || got[0].Status != 500 {
+		t.Errorf("path filter returned %+v", got)
+	}
+
+	full, ok := GetTrafficEntry(all[0].ID)
+	if !ok || full.RequestHeaders["Accept"][0] != "*/*" {
+		t.Errorf("GetTrafficEntry() = %+v, %v; want headers", full, ok)
+	}
+
+	ClearTraffic(&route)
+	if got := ListTraffic(TrafficFilter{}, 0); len(got) != 1 {
+		t.Errorf("after clearing route 1, %d requests left, want 1", len(got))
+	}
+}
+
+func TestTrafficBodies(t *testing.T) {
+	sink, limit := trafficSinkAddress, trafficBodyLimit
+	defer func() { trafficSinkAddress, trafficBodyLimit = sink, limit }()
+	trafficSinkAddress, trafficBodyLimit = "api:9300", 8
+
+	line := []byte(`{"msg":"handled request","request":{"method":"POST","host":"app.test","uri":"/login"},"status":200,` +
+		`"request_body":"{\"user\":\"dev\"}","response_body":"ok"}`)
+	e, ok := parseAccessLog(line)
+	if !ok || e.RequestBody != `{"user":` || !e.RequestBodyTruncated || e.ResponseBody != "ok" || e.ResponseBodyTruncated {
+		t.Errorf("parseAccessLog() bodies = %q (%v), %q (%v)", e.RequestBody, e.RequestBodyTruncated, e.ResponseBody, e.ResponseBodyTruncated)
+	}
+	if s := trafficSummary(e); s.RequestBody != "" || s.ResponseBody != "" {
+		t.Error("summaries should not carry bodies")
+	}
+	if body, cut := truncateBody("héllo", 2); body != "h" || !cut {
+		t.Errorf("truncateBody() = %q, %v; want the split rune dropped", body, cut)
+	}
+
+	handlers := routeHandlers(models.Route{ID: 1, Domain: "app.test", Target: "app:80"})
+	if len(handlers) != 3 || handlers[0]["handler"] != "log_append" || handlers[1]["value"] != "{http.response.body}" {
+		t.Errorf("routeHandlers() = %v, want body capture before the proxy", handlers)
+	}
+
+	trafficBodyLimit = 0
+	if e, _ := parseAccessLog(line); e.RequestBody != "" {
+		t.Error("bodies captured without a body limit")
+	}
+	if handlers := routeHandlers(models.Route{ID: 1, Domain: "app.test", Target: "app:80"}); len(handlers) != 1 {
+		t.Errorf("routeHandlers() = %v, want only the proxy", handlers)
+	}
+}
+
+func TestReadTrafficLine(t *testing.T) {
+	r := bufio.NewReaderSize(strings.NewReader(strings.Repeat("x", 40)+"\nshort\nlast"), 16)
+	var lines []string
+	for {
+		line, err := readTrafficLine(r, 32)
+		lines = append(lines, string(line))
+		if err != nil {
+			break
+		}
+	}
+	if want := []string{"", "short\n", "last"}; strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("readTrafficLine() lines = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"devproxy/internal/models"
+)
+
+// RouteError describes why a single route prevents a config from being
+// applied. RouteID is 0 when Caddy rejected the config as a whole.
+type RouteError struct {
+	RouteID int64  `json:"route_id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ApplyError is returned by ReloadCaddy when the config was not applied.
+// Caddy keeps serving the previous config in that case.
+type ApplyError struct {
+	Message    string       `json:"error"`
+	Errors     []RouteError `json:"errors"`
+	RolledBack bool         `json:"rolled_back"`
+	// Rejected is true when Caddy refused the config, false when it failed
+	// local validation and was never sent.
+	Rejected bool `json:"-"`
+}
+
+func (e *ApplyError) Error() string {
+	if len(e.Errors) == 0 {
+		return e.Message
+	}
+	details := make([]string, len(e.Errors))
+	for i, re := range e.Errors {
+		details[i] = re.Message
+		if re.Domain != "" {
+			details[i] = re.Domain + ": " + re.Message
+		}
+	}
+	return e.Message + ": " + strings.Join(details, "; ")
+}
+
+// FieldError is a problem with one field of a route submitted through the
+// API. ConflictRouteID names the route a hostname or path collides with.
+type FieldError struct {
+	Field           string `json:"field"`
+	Message         string `json:"message"`
+	ConflictRouteID int64  `json:"conflict_route_id,omitempty"`
+}
+
+// NormalizeRoute cleans user-supplied route fields and validates them,
+// including the TLD policy. Every field is checked, so all errors are
+// returned at once.
+func NormalizeRoute(r models.Route) (models.Route, []FieldError) {
+	var errs []FieldError
+	add := func(field string, err error) {
+		errs = append(errs, FieldError{Field: field, Message: err.Error()})
+	}
+
+	if domain, err := NormalizeHostname(r.Domain); err != nil {
+		add("domain", err)
+	} else if err := checkTLDPolicy(domain); err != nil {
+		add("domain", err)
+	} else {
+		r.Domain = domain
+	}
+
+	if aliases, err := NormalizeAliases(r.Domain, r.Aliases); err != nil {
+		add("aliases", err)
+	} else {
+		r.Aliases = aliases
+		for _, a := range aliases {
+			if err := checkTLDPolicy(a); err != nil {
+				add("aliases", err)
+				break
+			}
+		}
+	}
+
+	r.Path = NormalizePath(r.Path)
+	if err := ValidatePath(r.Path); err != nil {
+		add("path", err)
+	}
+
+	var err error
+	if r.TLSMode, err = NormalizeTLSMode(r.TLSMode); err != nil {
+		add("tls_mode", err)
+	}
+	if r.HealthCheck, err = NormalizeHealthCheck(r.HealthCheck); err != nil {
+		add("health_check", err)
+	}
+	if r.Headers, err = NormalizeHeaderRules(r.Headers); err != nil {
+		add("headers", err)
+	}
+	if r.AllowCIDRs, err = NormalizeCIDRs(r.AllowCIDRs); err != nil {
+		add("allow_cidrs", err)
+	}
+	normalized, field, err := NormalizeRouteKind(r)
+	if err != nil {
+		add(field, err)
+	} else {
+		r = normalized
+	}
+	return r, errs
+}
+
+// RouteConflicts checks a normalized route against the stored routes for
+// ambiguous paths on its domain and hostnames served by another domain.
+func RouteConflicts(r models.Route, all []models.Route) []FieldError {
+	var errs []FieldError
+	var pathConflict *PathConflictError
+	if err := FindPathConflict(r, all); errors.As(err, &pathConflict) {
+		errs = append(errs, FieldError{Field: "path", Message: err.Error(), ConflictRouteID: pathConflict.Conflict.ID})
+	}
+	var hostConflict *HostnameConflictError
+	if err := FindHostnameConflict(r, all); errors.As(err, &hostConflict) {
+		errs = append(errs, FieldError{Field: hostnameConflictField(r, hostConflict), Message: err.Error(), ConflictRouteID: hostConflict.Conflict.ID})
+	}
+	return errs
+}
+
+// hostnameConflictField is the field of r holding the conflicting hostname:
+// its domain or one of its aliases.
+func hostnameConflictField(r models.Route, conflict *HostnameConflictError) string {
+	if conflict.Hostname == r.Domain {
+		return "domain"
+	}
+	return "aliases"
+}
+
+// ValidateTarget checks that a route target is "host[:port]" or an http(s)
+// URL with a host and no path. Hosts are hostnames, which may contain
+// underscores as Docker container names do, or IP addresses.
+func ValidateTarget(target string) error {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return errors.New("target is required")
+	}
+
+	hostport := target
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil {
+			return fmt.Errorf("invalid target %q: %v", target, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid target %q: scheme must be http or https", target)
+		}
+		if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("invalid target %q: only scheme, host and port are allowed", target)
+		}
+		hostport = u.Host
+	}
+
+	host, port := hostport, ""
+	if h, p, err := net.SplitHostPort(hostport); err == nil {
+		host, port = h, p
+	}
+	if host == "" {
+		return fmt.Errorf("invalid target %q: missing host", target)
+	}
+	if net.ParseIP(host) == nil && !targetHostRe.MatchString(host) {
+		return fmt.Errorf("invalid target %q: bad host %q", target, host)
+	}
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid target %q: bad port %q", target, port)
+		}
+	}
+	return nil
+}
+
+// targetHostRe matches upstream hostnames and container names.
+var targetHostRe = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]{0,251}[A-Za-z0-9_])?$`)
+
+// ValidateRoutes checks a set of enabled routes before they are turned into
+// a Caddy config, and returns one error per offending route field.
+func ValidateRoutes(routes []models.Route) []RouteError {
+	var errs []RouteError
+	add := func(r models.Route, field string, err error) {
+		errs = append(errs, RouteError{
+			RouteID: r.ID,
+			Name:    r.Name,
+			Domain:  r.Domain,
+			Field:   field,
+			Message: err.Error(),
+		})
+	}
+
+	for i, r := range routes {
+		if _, err := NormalizeHostname(r.Domain); err != nil {
+			add(r, "domain", err)
+		}
+		if _, err := NormalizeAliases(r.Domain, r.Aliases); err != nil {
+			add(r, "aliases", err)
+		}
+		if err := ValidatePath(r.Path); err != nil {
+			add(r, "path", err)
+		}
+		if _, field, err := NormalizeRouteKind(r); err != nil {
+			add(r, field, err)
+		}
+		if _, err := NormalizeTLSMode(r.TLSMode); err != nil {
+			add(r, "tls_mode", err)
+		}
+		if _, err := NormalizeHealthCheck(r.HealthCheck); err != nil {
+			add(r, "health_check", err)
+		}
+		if _, err := NormalizeHeaderRules(r.Headers); err != nil {
+			add(r, "headers", err)
+		}
+		if _, err := NormalizeCIDRs(r.AllowCIDRs); err != nil {
+			add(r, "allow_cidrs", err)
+		}
+		// Compare against earlier routes only, so each conflicting pair is
+		// reported once.
+		if err := FindPathConflict(r, routes[:i]); err != nil {
+			add(r, "path", err)
+		}
+		var hostConflict *HostnameConflictError
+		if err := FindHostnameConflict(r, routes[:i]); errors.As(err, &hostConflict) {
+			add(r, hostnameConflictField(r, hostConflict), err)
+		}
+	}
+	return errs
+}
+
+// Caddy reports provisioning errors with the position of the failing route,
+// e.g. "server devproxy_http: setting up route handlers: route 2: ...".
+var (
+	caddyServerRouteRe = regexp.MustCompile(`server (\S+): setting up route handlers: route (\d+)`)
+	caddySubrouteRe    = regexp.MustCompile(`setting up subroutes: route (\d+)`)
+)
+
+// routeErrorsFromCaddy maps an error message from Caddy back to the routes
+// it refers to. If the position cannot be determined a single error without
+// a route is returned.
+func routeErrorsFromCaddy(cfg *caddyConfig, routes []models.Route, message string) []RouteError {
+	generic := []RouteError{{Message: message}}
+
+	m := caddyServerRouteRe.FindStringSubmatch(message)
+	if m == nil {
+		return generic
+	}
+	srv, ok := cfg.Apps.HTTP.Servers[m[1]]
+	siteIdx, _ := strconv.Atoi(m[2])
+	if !ok || siteIdx >= len(srv.Routes) {
+		return generic
+	}
+
+	subroutes, _ := srv.Routes[siteIdx].Handle[0]["routes"].([]caddyRoute)
+	if sm := caddySubrouteRe.FindStringSubmatch(message); sm != nil {
+		if idx, _ := strconv.Atoi(sm[1]); idx < len(subroutes) {
+			subroutes = subroutes[idx : idx+1]
+		}
+	}
+
+	byID := make(map[string]models.Route, len(routes))
+	for _, r := range routes {
+		byID[routeID(r.ID)] = r
+	}
+
+	var errs []RouteError
+	for _, sub := range subroutes {
+		if r, ok := byID[sub.ID]; ok {
+			errs = append(errs, RouteError{RouteID: r.ID, Name: r.Name, Domain: r.Domain, Message: message})
+		}
+	}
+	if len(errs) == 0 {
+		return generic
+	}
+	return errs
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"devproxy/internal/database"
+	"devproxy/internal/models"
+)
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		valid  bool
+	}{
+		{"app-web-1", true},
+		{"app-web-1:8080", true},
+		{"https://secure-web-1", true},
+		{"http://app-web-1:3000", true},
+		{"", false},
+		{"app-web-1:http", false},
+		{"app-web-1:70000", false},
+		{"ftp://files-1", false},
+		{"http://:8080", false},
+		{"proj_web_1:3000", true},
+		{"[::1]:8080", true},
+		{"http://app-web-1:3000/base", false},
+		{"app{\n}:80", false},
+		{"app web:80", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateTarget(tt.target)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateTarget(%q) = %v; want valid=%v", tt.target, err, tt.valid)
+		}
+	}
+}
+
+func TestNormalizeHostname(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{" App.Test. ", "app.test", false},
+		{"*.shop.test", "*.shop.test", false},
+		{"bücher.test", "xn--bcher-kva.test", false},
+		{"localhost", "localhost", false},
+		{"app.test {\n\trespond 200\n}", "", true},
+		{"app.test:8080", "", true},
+		{"-app.test", "", true},
+		{"app..test", "", true},
+		{"app_1.test", "", true},
+		{"a.*.test", "", true},
+		{"127.0.0.1", "", true},
+		{strings.Repeat("a", 64) + ".test", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeHostname(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("NormalizeHostname(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeRoute(t *testing.T) {
+	_, errs := NormalizeRoute(models.Route{Domain: "app.test}", Path: "/a b", Target: "app:http"})
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	if len(errs) != 3 || !fields["domain"] || !fields["path"] || !fields["target"] {
+		t.Errorf("NormalizeRoute() errors = %+v; want domain, path and target", errs)
+	}
+
+	ConfigureDomainPolicy([]string{"test", ".localhost"})
+	defer ConfigureDomainPolicy(nil)
+	if _, errs := NormalizeRoute(models.Route{Domain: "app.example", Aliases: []string{"app.localhost"}, Target: "app:80"}); len(errs) != 1 || errs[0].Field != "domain" {
+		t.Errorf("NormalizeRoute() errors = %+v; want a TLD policy error for the domain", errs)
+	}
+	if _, errs := NormalizeRoute(models.Route{Domain: "app.test", Aliases: []string{"app.localhost"}, Target: "app:80"}); len(errs) != 0 {
+		t.Errorf("NormalizeRoute() errors = %+v; want none", errs)
+	}
+}
+
+func TestRouteWarnings(t *testing.T) {
+	if w := RouteWarnings(models.Route{Domain: "app.test"}); len(w) != 0 {
+		t.Errorf("RouteWarnings(app.test) = %+v; want none", w)
+	}
+	w := RouteWarnings(models.Route{Domain: "app.test", Aliases: []string{"app.dev"}})
+	if len(w) != 1 || w[0].Field != "aliases" || !strings.Contains(w[0].Message, "plain HTTP") {
+		t.Errorf("RouteWarnings(app.dev over HTTP) = %+v", w)
+	}
+	w = RouteWarnings(models.Route{Domain: "app.dev", TLSMode: models.TLSModeInternal})
+	if len(w) != 1 || w[0].Field != "domain" || strings.Contains(w[0].Message, "plain HTTP") {
+		t.Errorf("RouteWarnings(app.dev over HTTPS) = %+v", w)
+	}
+}
+
+func TestRouteConflicts(t *testing.T) {
+	all := []models.Route{
+		{ID: 1, Domain: "app.test", Aliases: []string{"www.app.test"}},
+		{ID: 2, Domain: "api.test", Path: "/v1"},
+	}
+	errs := RouteConflicts(models.Route{Domain: "www.app.test"}, all)
+	if len(errs) != 1 || errs[0].Field != "domain" || errs[0].ConflictRouteID != 1 {
+		t.Errorf("RouteConflicts() = %+v; want a domain collision with route 1", errs)
+	}
+	errs = RouteConflicts(models.Route{Domain: "api.test", Path: "/v1"}, all)
+	if len(errs) != 1 || errs[0].Field != "path" || errs[0].ConflictRouteID != 2 {
+		t.Errorf("RouteConflicts() = %+v; want a path conflict with route 2", errs)
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	routes := []models.Route{
+		{ID: 1, Domain: "app.test", Target: "app-web-1:80"},
+		{ID: 2, Domain: "app.test", Target: "app-web-2:80"},
+		{ID: 3, Domain: "api.test", Target: "api-web-1:port"},
+	}
+
+	errs := ValidateRoutes(routes)
+	if len(errs) != 2 {
+		t.Fatalf("ValidateRoutes() = %+v; want 2 errors", errs)
+	}
+	if errs[0].RouteID != 2 || errs[0].Field != "path" {
+		t.Errorf("first error = %+v; want a path conflict on route 2", errs[0])
+	}
+	if errs[1].RouteID != 3 || errs[1].Field != "target" {
+		t.Errorf("second error = %+v; want a target error on route 3", errs[1])
+	}
+
+	errs = ValidateRoutes([]models.Route{
+		{ID: 1, Domain: "app.test", Aliases: []string{"www.app.test"}, Target: "app-web-1:80"},
+		{ID: 2, Domain: "www.app.test", Target: "www-web-1:80"},
+		{ID: 3, Domain: "other.test", Aliases: []string{"app.test"}, Target: "other-web-1:80"},
+	})
+	if len(errs) != 2 || errs[0].RouteID != 2 || errs[0].Field != "domain" || errs[1].RouteID != 3 || errs[1].Field != "aliases" {
+		t.Errorf("hostname conflicts = %+v; want domain on route 2 and aliases on route 3", errs)
+	}
+}
+
+func TestRouteErrorsFromCaddy(t *testing.T) {
+	routes := []models.Route{
+		{ID: 1, Domain: "a.test", Target: "a-web-1:80"},
+		{ID: 7, Domain: "b.test", Target: "b-web-1:80"},
+		{ID: 8, Domain: "b.test", Path: "/api", Target: "b-api-1:80"},
+	}
+	cfg := buildCaddyConfig(routes)
+
+	msg := "loading http app module: provision http: server devproxy_http: setting up route handlers: " +
+		"route 1: loading handler modules: position 0: provision http.handlers.subroute: " +
+		"setting up subroutes: route 0: bad upstream"
+	errs := routeErrorsFromCaddy(cfg, routes, msg)
+	if len(errs) != 1 || errs[0].RouteID != 8 {
+		t.Errorf("routeErrorsFromCaddy() = %+v; want route 8", errs)
+	}
+
+	errs = routeErrorsFromCaddy(cfg, routes, "unexpected failure")
+	if len(errs) != 1 || errs[0].RouteID != 0 {
+		t.Errorf("unlocated error should not name a route, got %+v", errs)
+	}
+}
+
+func TestReloadCaddyRollback(t *testing.T) {
+	var loads int
+	configFile := newTestEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/load" {
+			loads++
+		}
+		if bytes.Contains(body, []byte("broken-web-1")) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"upstream broken-web-1 rejected"}`))
+		}
+	})
+
+	good := models.Route{Name: "app", Domain: "app.test", Target: "app-web-1:80", Enabled: true}
+	if err := database.CreateRoute(&good); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ReloadCaddy("test"); err != nil {
+		t.Fatalf("first ReloadCaddy() error = %v", err)
+	}
+	before, _ := os.ReadFile(configFile)
+
+	bad := models.Route{Name: "broken", Domain: "*.broken.test", Target: "broken-web-1:80", Enabled: true}
+	if err := database.CreateRoute(&bad); err != nil {
+		t.Fatal(err)
+	}
+	loads = 0
+	_, _, err := ReloadCaddy("test")
+
+	var applyErr *ApplyError
+	if !errors.As(err, &applyErr) || !applyErr.Rejected || !applyErr.RolledBack {
+		t.Fatalf("ReloadCaddy() error = %#v; want a rolled back rejection", err)
+	}
+	if loads != 2 {
+		t.Errorf("got %d loads; want the candidate and the rollback", loads)
+	}
+	if after, _ := os.ReadFile(configFile); !bytes.Equal(before, after) {
+		t.Error("rejected config must not replace the file on disk")
+	}
+
+	bad.Target = "broken-web-1:http"
+	database.UpdateRoute("2", &bad)
+	_, _, err = ReloadCaddy("test")
+	if !errors.As(err, &applyErr) || applyErr.Rejected || len(applyErr.Errors) != 1 || applyErr.Errors[0].Field != "target" {
+		t.Errorf("ReloadCaddy() error = %#v; want a validation error for the target", err)
+	}
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"devproxy/internal/database"
+	"devproxy/internal/events"
+	"devproxy/internal/models"
+)
+
+// WebhookEventTypes are the events webhooks can subscribe to. A webhook
+// without events receives all of them.
+var WebhookEventTypes = []string{
+	events.HealthChanged,
+	events.RouteCreated,
+	events.RouteUpdated,
+	events.RouteDeleted,
+	events.RouteToggled,
+	events.RoutesChanged,
+	events.ApplySucceeded,
+	events.ApplyFailed,
+}
+
+// webhookTestEvent is sent by TestWebhook.
+const webhookTestEvent = "webhook.test"
+
+const webhookMaxAttempts = 5
+
+// webhookBackoff is the delay before the first retry; it doubles after
+// every failed attempt.
+var webhookBackoff = 2 * time.Second
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// NormalizeWebhook validates a webhook and cleans it in place.
+func NormalizeWebhook(w models.Webhook) (models.Webhook, error) {
+	w.Name = strings.TrimSpace(w.Name)
+	if w.Name == "" {
+		return w, errors.New("webhook name is required")
+	}
+
+	w.URL = strings.TrimSpace(w.URL)
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return w, fmt.Errorf("invalid webhook URL %q: must be an http or https URL", w.URL)
+	}
+
+	w.Format = strings.ToLower(strings.TrimSpace(w.Format))
+	switch w.Format {
+	case "":
+		w.Format = models.WebhookFormatGeneric
+	case models.WebhookFormatGeneric, models.WebhookFormatSlack, models.WebhookFormatNtfy:
+	default:
+		return w, fmt.Errorf("webhook format must be generic, slack or ntfy, not %q", w.Format)
+	}
+
+	known := make(map[string]bool, len(WebhookEventTypes))
+	for _, t := range WebhookEventTypes {
+		known[t] = true
+	}
+	seen := make(map[string]bool)
+	cleaned := []string{}
+	for _, t := range w.Events {
+		t = strings.TrimSpace(t)
+		if !known[t] {
+			return w, fmt.Errorf("unknown webhook event %q", t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			cleaned = append(cleaned, t)
+		}
+	}
+	w.Events = cleaned
+	return w, nil
+}
+
+// StartWebhooks delivers published events to the configured webhooks.
+func StartWebhooks() {
+	var lastID int64
+	for {
+		// The bus drops subscribers that fall behind; resubscribing from
+		// the last ID replays what was missed.
+		ch, cancel := events.Subscribe(lastID)
+		for e := range ch {
+			lastID = e.ID
+			dispatchWebhooks(e)
+		}
+		cancel()
+	}
+}
+
+func dispatchWebhooks(e events.Event) {
+	if e.Type == events.Reset {
+		log.Printf("Webhooks fell behind the event bus; events up to #%d were not delivered", e.ID)
+		return
+	}
+	hooks, err := database.GetAllWebhooks()
+	if err != nil {
+		log.Printf("Error loading webhooks: %v", err)
+		return
+	}
+	for _, hook := range hooks {
+		if hook.Enabled && webhookWants(hook, e.Type) {
+			go deliverWebhook(hook, e)
+		}
+	}
+}
+
+func webhookWants(hook models.Webhook, eventType string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, t := range hook.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverWebhook sends an event, retrying with exponential backoff on
+// network errors, 429 and 5xx responses. Every attempt is logged.
+func deliverWebhook(hook models.Webhook, e events.Event) {
+	delay := webhookBackoff
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		d := sendWebhook(hook, e, attempt)
+		if d.Success || !retryableDelivery(d) {
+			return
+		}
+		if attempt < webhookMaxAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	log.Printf("Webhook %q: giving up on event %d (%s) after %d attempts", hook.Name, e.ID, e.Type, webhookMaxAttempts)
+}
+
+// TestWebhook sends a test event once and returns the logged delivery.
+func TestWebhook(hook models.Webhook) models.WebhookDelivery {
+	data, _ := json.Marshal(map[string]string{"message": "Test notification from DevProxy"})
+	e := events.Event{Type: webhookTestEvent, Time: time.Now().UTC(), Data: data}
+	return sendWebhook(hook, e, 1)
+}
+
+func sendWebhook(hook models.Webhook, e events.Event, attempt int) models.WebhookDelivery {
+	d := models.WebhookDelivery{WebhookID: hook.ID, EventID: e.ID, EventType: e.Type, Attempt: attempt}
+
+	req, err := buildWebhookRequest(hook, e)
+	if err != nil {
+		d.Error = err.Error()
+	} else {
+		body, _ := req.GetBody()
+		payload, _ := io.ReadAll(body)
+		d.Payload = string(payload)
+
+		start := time.Now()
+		resp, err := webhookClient.Do(req)
+		d.Duration = time.Since(start).Milliseconds()
+		if err != nil {
+			d.Error = err.Error()
+		} else {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			d.StatusCode = resp.StatusCode
+			d.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+			if !d.Success {
+				d.Error = fmt.Sprintf("endpoint returned %s", resp.Status)
+			}
+		}
+	}
+
+	if err := database.CreateWebhookDelivery(&d); err != nil {
+		log.Printf("Error logging webhook delivery: %v", err)
+	}
+	return d
+}
+
+func retryableDelivery(d models.WebhookDelivery) bool {
+	return d.StatusCode == 0 || d.StatusCode == http.StatusTooManyRequests || d.StatusCode >= 500
+}
+
+// buildWebhookRequest renders an event in the webhook's format. The send
+// time and the body are signed with the webhook secret in
+// X-DevProxy-Signature as "sha256=<hex HMAC of timestamp.body>", with the
+// Unix timestamp in X-DevProxy-Timestamp, so receivers can reject replays.
+func buildWebhookRequest(hook models.Webhook, e events.Event) (*http.Request, error) {
+	title, message, alert := describeEvent(e)
+
+	var body []byte
+	contentType := "application/json"
+	header := http.Header{}
+	switch hook.Format {
+	case models.WebhookFormatSlack:
+		body, _ = json.Marshal(map[string]string{"text": "*" + title + "*\n" + message})
+	case models.WebhookFormatNtfy:
+		body = []byte(message)
+		contentType = "text/plain; charset=utf-8"
+		header.Set("Title", title)
+		if alert {
+			header.Set("Priority", "high")
+			header.Set("Tags", "warning")
+		} else {
+			header.Set("Tags", "white_check_mark")
+		}
+	default:
+		data := e.Data
+		if len(data) == 0 {
+			data = json.RawMessage("null")
+		}
+		body, _ = json.Marshal(map[string]interface{}{
+			"id":      e.ID,
+			"event":   e.Type,
+			"time":    e.Time,
+			"title":   title,
+			"message": message,
+			"data":    data,
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "DevProxy-Webhook")
+	req.Header.Set("X-DevProxy-Event", e.Type)
+	req.Header.Set("X-DevProxy-Delivery", strconv.FormatInt(e.ID, 10))
+	if hook.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-DevProxy-Timestamp", timestamp)
+		req.Header.Set("X-DevProxy-Signature", SignWebhook(hook.Secret, timestamp, body))
+	}
+	return req, nil
+}
+
+// SignWebhook returns the signature header value of a webhook body sent at
+// timestamp.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// describeEvent summarises an event for chat notifications. alert is true
+// for failures and routes turning unhealthy.
+func describeEvent(e events.Event) (title, message string, alert bool) {
+	switch e.Type {
+	case events.HealthChanged:
+		var s models.HealthStatus
+		json.Unmarshal(e.Data, &s)
+		if s.Healthy {
+			return s.Domain + " is healthy", fmt.Sprintf("%s (%s) is healthy again.", s.Domain, s.Target), false
+		}
+		reason := s.ErrorType
+		if s.Error != "" {
+			reason += ": " + s.Error
+		}
+		return s.Domain + " is unhealthy", fmt.Sprintf("%s (%s) is unhealthy (%s).", s.Domain, s.Target, reason), true
+
+	case events.RouteCreated, events.RouteUpdated, events.RouteDeleted, events.RouteToggled:
+		var r models.Route
+		json.Unmarshal(e.Data, &r)
+		action := strings.TrimPrefix(e.Type, "route.")
+		if e.Type == events.RouteToggled {
+			action = "disabled"
+			if r.Enabled {
+				action = "enabled"
+			}
+		}
+		return "Route " + action, fmt.Sprintf("Route %q (%s%s -> %s) was %s.", r.Name, r.Domain, r.Path, r.Target, action), false
+
+	case events.RoutesChanged:
+		var data map[string]interface{}
+		json.Unmarshal(e.Data, &data)
+		return "Routes changed", fmt.Sprintf("Routes changed (%v).", data["action"]), false
+
+	case events.ApplySucceeded:
+		var data map[string]string
+		json.Unmarshal(e.Data, &data)
+		message := data["message"]
+		if data["warning"] != "" {
+			message += " (" + data["warning"] + ")"
+		}
+		return "Proxy config applied", fmt.Sprintf("%s, by %s.", message, data["author"]), data["warning"] != ""
+
+	case events.ApplyFailed:
+		var data struct {
+			Author string       `json:"author"`
+			Error  string       `json:"error"`
+			Errors []RouteError `json:"errors"`
+		}
+		json.Unmarshal(e.Data, &data)
+		message := data.Error
+		if len(data.Errors) > 0 {
+			message += ": " + data.Errors[0].Message
+			if len(data.Errors) > 1 {
+				message += fmt.Sprintf(" (and %d more)", len(data.Errors)-1)
+			}
+		}
+		return "Applying the proxy config failed", fmt.Sprintf("%s, by %s.", message, data.Author), true
+	}
+
+	var data map[string]interface{}
+	json.Unmarshal(e.Data, &data)
+	if msg, ok := data["message"].(string); ok {
+		return "DevProxy", msg, false
+	}
+	return "DevProxy", e.Type, false
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"devproxy/internal/database"
+	"devproxy/internal/events"
+	"devproxy/internal/models"
+)
+
+func TestNormalizeWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    models.Webhook
+		wantErr bool
+	}{
+		{"defaults", models.Webhook{Name: " ci ", URL: "https://example.com/hook"}, false},
+		{"slack", models.Webhook{Name: "chat", URL: "https://hooks.slack.com/x", Format: "Slack"}, false},
+		{"missing name", models.Webhook{URL: "https://example.com"}, true},
+		{"bad scheme", models.Webhook{Name: "x", URL: "ftp://example.com"}, true},
+		{"no host", models.Webhook{Name: "x", URL: "http://"}, true},
+		{"bad format", models.Webhook{Name: "x", URL: "http://example.com", Format: "teams"}, true},
+		{"unknown event", models.Webhook{Name: "x", URL: "http://example.com", Events: []string{"route.renamed"}}, true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeWebhook(tt.hook)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.Format == "" {
+			t.Errorf("%s: format not defaulted", tt.name)
+		}
+	}
+
+	got, _ := NormalizeWebhook(tests[0].hook)
+	if got.Name != "ci" || got.Format != models.WebhookFormatGeneric {
+		t.Errorf("NormalizeWebhook() = %+v, want trimmed name and generic format", got)
+	}
+
+	got, _ = NormalizeWebhook(models.Webhook{Name: "x", URL: "http://example.com", Events: []string{events.ApplyFailed, events.ApplyFailed}})
+	if len(got.Events) != 1 {
+		t.Errorf("events = %v, want duplicates removed", got.Events)
+	}
+}
+
+func TestBuildWebhookRequest(t *testing.T) {
+	data, _ := json.Marshal(models.HealthStatus{Domain: "app.test", Target: "app:80", ErrorType: "connection_refused"})
+	e := events.Event{ID: 7, Type: events.HealthChanged, Time: time.Now().UTC(), Data: data}
+
+	req, err := buildWebhookRequest(models.Webhook{URL: "http://example.com", Format: models.WebhookFormatGeneric, Secret: "s3cret"}, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	timestamp := req.Header.Get("X-DevProxy-Timestamp")
+	if got, want := req.Header.Get("X-DevProxy-Signature"), SignWebhook("s3cret", timestamp, body); timestamp == "" || got != want {
+		t.Errorf("signature = %q at %q, want %q", got, timestamp, want)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte("1700000000.{}"))
+	if got, want := SignWebhook("s3cret", "1700000000", []byte("{}")), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("SignWebhook() = %q, want %q", got, want)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil || payload["event"] != events.HealthChanged || payload["title"] != "app.test is unhealthy" {
+		t.Errorf("generic payload = %s", body)
+	}
+
+	req, _ = buildWebhookRequest(models.Webhook{URL: "http://example.com", Format: models.WebhookFormatNtfy}, e)
+	if req.Header.Get("Title") != "app.test is unhealthy" || req.Header.Get("Priority") != "high" || req.Header.Get("X-DevProxy-Signature") != "" {
+		t.Errorf("ntfy headers = %v", req.Header)
+	}
+
+	req, _ = buildWebhookRequest(models.Webhook{URL: "http://example.com", Format: models.WebhookFormatSlack}, e)
+	body, _ = io.ReadAll(req.Body)
+	var slack map[string]string
+	if err := json.Unmarshal(body, &slack); err != nil || slack["text"] == "" {
+		t.Errorf("slack payload = %s", body)
+	}
+}
+
+func TestDeliverWebhookRetries(t *testing.T) {
+	newTestEnv(t, nil)
+
+	backoff := webhookBackoff
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = backoff }()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	hook := models.Webhook{Name: "test", URL: srv.URL, Format: models.WebhookFormatGeneric, Enabled: true}
+	if err := database.CreateWebhook(&hook); err != nil {
+		t.Fatal(err)
+	}
+	deliverWebhook(hook, events.Event{ID: 1, Type: events.ApplySucceeded, Time: time.Now()})
+
+	deliveries, err := database.GetWebhookDeliveries(strconv.FormatInt(hook.ID, 10), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 || !deliveries[0].Success || deliveries[0].Attempt != 3 || deliveries[2].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("deliveries = %+v, want two failures then a success", deliveries)
+	}
+}
@@ -7,11 +7,15 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"devproxy/internal/auth"
 	"devproxy/internal/config"
 	"devproxy/internal/database"
 	"devproxy/internal/handlers"
+	"devproxy/internal/metrics"
 	"devproxy/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -26,8 +30,11 @@ func main() {
 
 	// Configuration
 	dbPath := getEnv("DB_PATH", "/app/data/devproxy.db")
-	caddyfilePath := getEnv("CADDYFILE_PATH", "/app/data/Caddyfile")
+	caddyConfigPath := getEnv("CADDY_CONFIG_PATH", "/app/data/caddy.json")
+	caddyfilePath := getEnv("CADDYFILE_PATH", "")
 	caddyAPI := getEnv("CADDY_API", "http://caddy:2019")
+	dockerSocket := getEnv("DOCKER_SOCKET", "/var/run/docker.sock")
+	dockerNetwork := getEnv("DOCKER_NETWORK", "dev-proxy")
 
 	// Initialize database
 	if err := database.Init(dbPath); err != nil {
@@ -35,13 +42,64 @@ func main() {
 	}
 	defer database.Close()
 
+	// Authentication is enabled by setting an admin password
+	if err := auth.Init(os.Getenv("ADMIN_PASSWORD"), os.Getenv("ADMIN_PASSWORD_HASH")); err != nil {
+		log.Fatalf("Failed to initialize authentication: %v", err)
+	}
+	if auth.Enabled() {
+		log.Println("API authentication enabled")
+	}
+
+	// Request capture from Caddy's access logs; must be configured before
+	// the Caddy config is generated
+	trafficSink := getEnv("TRAFFIC_SINK_ADDRESS", "")
+	services.ConfigureTraffic(trafficSink, getEnvInt("TRAFFIC_BUFFER_SIZE"), getEnvInt("TRAFFIC_BODY_LIMIT"))
+
+	// Restrict the TLDs new routes may use, e.g. "test,localhost"
+	if tlds := getEnv("ALLOWED_TLDS", ""); tlds != "" {
+		services.ConfigureDomainPolicy(strings.Split(tlds, ","))
+	}
+
+	// Compose imports propose routes to containers on the proxy network and
+	// only read files by path below COMPOSE_BASE_DIR
+	services.ConfigureComposeImport(dockerNetwork, getEnv("COMPOSE_BASE_DIR", ""))
+
+	// Branded error pages, fetched by Caddy from a separate listener
+	errorPages := getEnv("ERROR_PAGES_ADDRESS", "")
+	services.ConfigureErrorPages(errorPages)
+
 	// Initialize Caddy service
-	services.InitCaddy(caddyfilePath, caddyAPI)
-	services.GenerateCaddyfile()
+	services.InitCaddy(caddyConfigPath, caddyfilePath, caddyAPI)
+	services.GenerateConfig()
 	services.SaveAppliedState()
 
-	// Start background health checker
+	// Start background health checker and history retention
+	services.ConfigureHealthHistory(
+		getEnvDuration("HEALTH_RAW_RETENTION"),
+		getEnvDuration("HEALTH_RETENTION"),
+		getEnvDuration("HEALTH_ROLLUP_INTERVAL"),
+	)
 	go services.StartHealthChecker()
+	go services.StartHealthHistoryCompactor()
+
+	// Deliver events to webhooks
+	go services.StartWebhooks()
+
+	if trafficSink != "" {
+		go services.StartTrafficSink(getEnv("TRAFFIC_LISTEN", ":9300"))
+	}
+	if errorPages != "" {
+		go startErrorPageServer(getEnv("ERROR_PAGES_LISTEN", ":9301"))
+	}
+
+	// Start Docker label discovery if the socket is mounted
+	if getEnv("DOCKER_DISCOVERY", "true") == "false" {
+		log.Println("Docker discovery disabled")
+	} else if _, err := os.Stat(dockerSocket); err != nil {
+		log.Printf("Docker socket %s not available, discovery disabled", dockerSocket)
+	} else {
+		go services.StartDockerDiscovery(dockerSocket, dockerNetwork)
+	}
 
 	// Setup router
 	r := gin.Default()
@@ -59,8 +117,72 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getEnvInt reads a positive integer; unset or invalid values return 0.
+func getEnvInt(key string) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Printf("Ignoring invalid %s: %q", key, value)
+		return 0
+	}
+	return n
+}
+
+// getEnvDuration reads a duration such as "24h" or "30d"; unset or invalid
+// values return 0.
+func getEnvDuration(key string) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	d, err := services.ParseDuration(value)
+	if err != nil {
+		log.Printf("Ignoring invalid %s: %v", key, err)
+		return 0
+	}
+	return d
+}
+
+// startErrorPageServer serves the error pages Caddy fetches. It has its own
+// listener, which only answers Caddy, because the pages show route details
+// without authentication and the api container is also on the dev-proxy
+// network.
+func startErrorPageServer(addr string) {
+	r := gin.New()
+	r.Use(gin.Recovery(), handlers.CaddyOnly())
+	r.GET("/error-page", handlers.ErrorPage)
+
+	log.Printf("Error page server listening on %s", addr)
+	if err := r.Run(addr); err != nil {
+		log.Printf("Error page server stopped: %v", err)
+	}
+}
+
 func setupRoutes(r *gin.Engine) {
-	api := r.Group("/api")
+	// Endpoints reachable without logging in
+	public := r.Group("/api")
+	{
+		public.GET("/auth/status", handlers.GetAuthStatus)
+		public.POST("/auth/login", handlers.Login)
+		public.POST("/auth/logout", handlers.Logout)
+
+		// Needed by the agent and browsers before they have credentials
+		public.GET("/tls/ca", handlers.GetRootCA)
+		public.GET("/agent/download/:os", handlers.DownloadAgent)
+
+		// Frontend Config
+		public.GET("/config", gin.WrapF(handlers.GetFrontendConfig))
+	}
+	r.GET("/login", handlers.LoginPage)
+
+	// Prometheus metrics; scrape with a read-only token when authentication
+	// is enabled
+	r.GET("/metrics", auth.Middleware(), gin.WrapH(metrics.Handler()))
+
+	api := r.Group("/api", auth.Middleware())
 	{
 		// Route management
 		api.GET("/routes", handlers.GetRoutes)
@@ -69,21 +191,73 @@ func setupRoutes(r *gin.Engine) {
 		api.PUT("/routes/:id", handlers.UpdateRoute)
 		api.DELETE("/routes/:id", handlers.DeleteRoute)
 		api.POST("/routes/:id/toggle", handlers.ToggleRoute)
+		api.PUT("/routes/:id/maintenance", handlers.SetRouteMaintenance)
+
+		// Route basic auth users
+		api.GET("/routes/:id/users", handlers.GetRouteUsers)
+		api.PUT("/routes/:id/users/:username", handlers.SetRouteUser)
+		api.DELETE("/routes/:id/users/:username", handlers.DeleteRouteUser)
+
+		// Request composer
+		api.POST("/routes/:id/compose", handlers.ComposeRequest)
+		api.GET("/compose/:id", handlers.GetComposeResult)
+		api.GET("/compose/:id/har", handlers.ExportComposeHAR)
+
+		// Projects
+		api.GET("/projects", handlers.GetProjects)
+		api.GET("/projects/:id", handlers.GetProject)
+		api.POST("/projects", handlers.CreateProject)
+		api.PUT("/projects/:id", handlers.UpdateProject)
+		api.DELETE("/projects/:id", handlers.DeleteProject)
+		api.POST("/projects/:id/routes", handlers.AssignProjectRoutes)
+		api.DELETE("/projects/:id/routes", handlers.DeleteProjectRoutes)
+		api.POST("/projects/:id/enable", handlers.EnableProject)
+		api.POST("/projects/:id/disable", handlers.DisableProject)
+		api.GET("/projects/:id/export", handlers.ExportProject)
 
 		// Health & Status
 		api.GET("/health", handlers.GetHealthStatus)
+		api.GET("/health/history", handlers.GetHealthHistory)
+		api.GET("/health/history/:id", handlers.GetRouteHealthHistory)
+		api.GET("/health/transitions", handlers.GetHealthTransitions)
 		api.GET("/applied-state", handlers.GetAppliedState)
 
+		// Event stream
+		api.GET("/events", handlers.StreamEvents)
+
+		// Traffic inspector
+		api.GET("/traffic", handlers.GetTraffic)
+		api.GET("/traffic/stream", handlers.StreamTraffic)
+		api.GET("/traffic/:id", handlers.GetTrafficEntry)
+		api.DELETE("/traffic", handlers.ClearTraffic)
+
 		// Proxy control
 		api.POST("/reload", handlers.ReloadCaddy)
 
+		// Revision history
+		api.GET("/revisions", handlers.GetRevisions)
+		api.GET("/revisions/:id", handlers.GetRevision)
+		api.GET("/revisions/:id/diff", handlers.DiffRevisions)
+		api.POST("/revisions/:id/rollback", handlers.RollbackRevision)
+
+		// Docker discovery
+		api.GET("/discovery", handlers.GetDiscoveryStatus)
+		api.POST("/discovery/sync", handlers.SyncDiscovery)
+
+		// Local HTTPS
+		api.GET("/tls/ca/info", handlers.GetRootCAInfo)
+
 		// Config import/export
 		api.GET("/export", handlers.ExportConfig)
+		api.GET("/export/caddyfile", handlers.ExportCaddyfile)
+		api.GET("/export/caddy-json", handlers.ExportCaddyJSON)
 		api.POST("/import", handlers.ImportConfig)
+		api.POST("/import/compose", handlers.PreviewComposeImport)
+		api.POST("/import/nginx", handlers.PreviewNginxImport)
+		api.POST("/import/traefik", handlers.PreviewTraefikImport)
 
 		// Host Agent
 		api.GET("/agent/info", handlers.GetAgentInfo)
-		api.GET("/agent/download/:os", handlers.DownloadAgent)
 		api.GET("/agent/version", handlers.GetAgentVersion)
 		api.POST("/agent/updates/check", handlers.CheckAgentUpdates)
 
@@ -91,8 +265,20 @@ func setupRoutes(r *gin.Engine) {
 		api.GET("/version", handlers.GetBackendVersion)
 		api.POST("/updates/check", handlers.CheckBackendUpdates)
 
-		// Frontend Config
-		api.GET("/config", gin.WrapF(handlers.GetFrontendConfig))
+		// API tokens
+		admin := api.Group("", auth.RequireScope(auth.ScopeAdmin))
+		admin.GET("/tokens", handlers.GetTokens)
+		admin.POST("/tokens", handlers.CreateToken)
+		admin.DELETE("/tokens/:id", handlers.DeleteToken)
+
+		// Webhooks
+		admin.GET("/webhooks", handlers.GetWebhooks)
+		admin.GET("/webhooks/:id", handlers.GetWebhook)
+		admin.POST("/webhooks", handlers.CreateWebhook)
+		admin.PUT("/webhooks/:id", handlers.UpdateWebhook)
+		admin.DELETE("/webhooks/:id", handlers.DeleteWebhook)
+		admin.POST("/webhooks/:id/test", handlers.TestWebhook)
+		admin.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
 	}
 }
 